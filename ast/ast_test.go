@@ -0,0 +1,50 @@
+package ast
+
+import (
+	"interpreter/token"
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	// This is a test function for the String method of the Program
+	// It builds the AST for "let myVar = anotherVar;" by hand and checks it is printed back correctly
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Token: token.Token{Type: token.LET, Literal: "let"},
+				Name: &Identifier{
+					Token: token.Token{Type: token.IDENT, Literal: "myVar"},
+					Value: "myVar",
+				},
+				Value: &Identifier{
+					Token: token.Token{Type: token.IDENT, Literal: "anotherVar"},
+					Value: "anotherVar",
+				},
+			},
+		},
+	}
+
+	if program.String() != "let myVar = anotherVar;" {
+		t.Errorf("program.String() wrong. got=%q", program.String())
+	}
+}
+
+func TestPos(t *testing.T) {
+	// This is a test function for the Pos method, which is the position of the token of the node
+	// A Program is where its first statement is, an empty one is nowhere
+	let := &LetStatement{
+		Token: token.Token{Type: token.LET, Literal: "let", Pos: 7},
+		Name:  &Identifier{Token: token.Token{Type: token.IDENT, Literal: "x", Pos: 11}, Value: "x"},
+	}
+	program := &Program{Statements: []Statement{let}}
+
+	if program.Pos() != 7 {
+		t.Errorf("program.Pos() wrong. want=7, got=%d", program.Pos())
+	}
+	if let.Name.Pos() != 11 {
+		t.Errorf("let.Name.Pos() wrong. want=11, got=%d", let.Name.Pos())
+	}
+	if (&Program{}).Pos() != token.NoPos {
+		t.Errorf("empty program has a position")
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"interpreter/compiler"
+	"interpreter/disasm"
+	"interpreter/lexer"
+	"interpreter/mkc"
+	"interpreter/optimizer"
+	"interpreter/parser"
+	"interpreter/token"
+	"interpreter/vm"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const usage = `usage:
+	monkey                     start the REPL
+	monkey [-O] build file.mk  compile file.mk to file.mkc
+	monkey [-O] [run] file.mk  run file.mk, using file.mkc if it is up to date
+	monkey [-O] [run] file.mkc run file.mkc, recompiling its source if it changed
+	monkey [-O] disasm file    print the bytecode of a source or a .mkc file
+
+-O optimizes the source before compiling it
+a .mkc file built with the other -O setting is compiled again, unless its source is missing
+`
+
+// runCLI runs the command given by the arguments, without the program name, and returns the exit code
+// The output of the scripts goes to stdout, the errors to stderr
+func runCLI(args []string, stdout, stderr io.Writer) int {
+	var err error
+
+	optimize := len(args) > 0 && args[0] == "-O"
+	if optimize {
+		args = args[1:]
+	}
+
+	switch {
+	case len(args) == 2 && args[0] == "build":
+		err = build(args[1], optimize)
+	case len(args) == 2 && args[0] == "run":
+		err = run(args[1], optimize, stdout)
+	case len(args) == 2 && args[0] == "disasm":
+		err = disassemble(args[1], optimize, stdout)
+	case len(args) == 1 && args[0] != "build" && args[0] != "run" && args[0] != "disasm":
+		err = run(args[0], optimize, stdout)
+	default:
+		io.WriteString(stderr, usage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// bytecodePath returns where the bytecode of a source file is stored
+// For example, the bytecode of "scripts/fib.mk" is "scripts/fib.mkc"
+func bytecodePath(source string) string {
+	return strings.TrimSuffix(source, filepath.Ext(source)) + ".mkc"
+}
+
+// compileSource compiles the source file at the given path into a File for it
+// With optimize, the AST is optimized before it is compiled
+func compileSource(path string, src []byte, optimize bool) (*mkc.File, error) {
+	fset := token.NewFileSet()
+	p := parser.New(lexer.New(string(src), lexer.WithFile(fset, path)))
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, fmt.Errorf("%s: parser errors:\n\t%s", path, strings.Join(p.Errors(), "\n\t"))
+	}
+	if optimize {
+		optimizer.Optimize(program)
+	}
+
+	c := compiler.New(compiler.WithFileSet(fset))
+	if err := c.Compile(program); err != nil {
+		return nil, fmt.Errorf("%s: compiler error: %w", path, err)
+	}
+
+	return &mkc.File{
+		// The source is recorded relative to the .mkc file, which is next to it
+		Source:     filepath.Base(path),
+		SourceHash: mkc.HashSource(src),
+		Optimized:  optimize,
+		Bytecode:   c.Bytecode(),
+	}, nil
+}
+
+// build compiles the source file and writes its bytecode next to it
+func build(path string, optimize bool) error {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	f, err := compileSource(path, src, optimize)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := mkc.Encode(&buf, f); err != nil {
+		return err
+	}
+	return os.WriteFile(bytecodePath(path), buf.Bytes(), 0o644)
+}
+
+// load returns the bytecode to run for a source or a .mkc file
+// For a source file, its .mkc file is used instead of compiling it when the recorded hash still matches
+// For a .mkc file, its source is compiled instead when it exists and no longer matches the recorded hash
+// Either way, a .mkc file built with another optimize setting is only used when its source is missing
+func load(path string, optimize bool) (*mkc.File, error) {
+	if filepath.Ext(path) == ".mkc" {
+		f, err := decodeFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		source := filepath.Join(filepath.Dir(path), f.Source)
+		src, err := os.ReadFile(source)
+		if errors.Is(err, os.ErrNotExist) {
+			// A .mkc file can be shipped without its source
+			return f, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if mkc.HashSource(src) == f.SourceHash && f.Optimized == optimize {
+			return f, nil
+		}
+		return compileSource(source, src, optimize)
+	}
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// A missing, stale or unreadable .mkc file just means the source is compiled again
+	if f, err := decodeFile(bytecodePath(path)); err == nil &&
+		f.Source == filepath.Base(path) && f.SourceHash == mkc.HashSource(src) && f.Optimized == optimize {
+		return f, nil
+	}
+	return compileSource(path, src, optimize)
+}
+
+func decodeFile(path string) (*mkc.File, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	f, err := mkc.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, nil
+}
+
+// run runs a source or a .mkc file on the stack vm
+func run(path string, optimize bool, stdout io.Writer) error {
+	f, err := load(path, optimize)
+	if err != nil {
+		return err
+	}
+
+	machine := vm.New(f.Bytecode)
+	machine.SetOutput(stdout)
+	if err := machine.Run(); err != nil {
+		return fmt.Errorf("%s: runtime error: %w", path, err)
+	}
+	return nil
+}
+
+// disassemble prints the bytecode of a source or a .mkc file, the same bytecode run would run
+// The lines of the source are printed along with the instructions, unless it is missing or changed
+func disassemble(path string, optimize bool, stdout io.Writer) error {
+	f, err := load(path, optimize)
+	if err != nil {
+		return err
+	}
+
+	var source string
+	src, err := os.ReadFile(filepath.Join(filepath.Dir(path), f.Source))
+	if err == nil && mkc.HashSource(src) == f.SourceHash {
+		source = string(src)
+	}
+
+	return disasm.Disassemble(stdout, f.Bytecode, source)
+}
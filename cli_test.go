@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"interpreter/mkc"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBuildAndRun(t *testing.T) {
+	// This is a test function for building a script and running it from its source or its .mkc file
+	dir := t.TempDir()
+	source := filepath.Join(dir, "fib.mk")
+	writeFile(t, source, `
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+puts(fib(15));`)
+
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{source}, "610\n"},
+		{[]string{"build", source}, ""},
+		{[]string{"run", source}, "610\n"},
+		{[]string{filepath.Join(dir, "fib.mkc")}, "610\n"},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		if code := runCLI(tt.args, &stdout, &stderr); code != 0 {
+			t.Fatalf("%v - exit code %d: %s", tt.args, code, stderr.String())
+		}
+		if stdout.String() != tt.expected {
+			t.Errorf("%v - wrong output. want=%q, got=%q", tt.args, tt.expected, stdout.String())
+		}
+	}
+}
+
+func TestStaleBytecode(t *testing.T) {
+	// This is a test function for choosing between the source and the .mkc file
+	// The .mkc file is written by hand so that its bytecode prints something else than its source
+	// That way the output tells which of the two was run
+	dir := t.TempDir()
+	source := filepath.Join(dir, "main.mk")
+	compiled := filepath.Join(dir, "main.mkc")
+
+	writeFile(t, source, `puts("source")`)
+	f, err := compileSource(source, []byte(`puts("bytecode")`), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.SourceHash = mkc.HashSource([]byte(`puts("source")`))
+
+	var buf bytes.Buffer
+	if err := mkc.Encode(&buf, f); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, compiled, buf.String())
+
+	tests := []struct {
+		name     string
+		source   string // The new content of the source, "" to remove it
+		path     string
+		expected string
+	}{
+		{"source matches", `puts("source")`, source, "bytecode\n"},
+		{"source matches, run the .mkc", `puts("source")`, compiled, "bytecode\n"},
+		{"source changed", `puts("changed")`, source, "changed\n"},
+		{"source changed, run the .mkc", `puts("changed")`, compiled, "changed\n"},
+		{"source removed", "", compiled, "bytecode\n"},
+	}
+
+	for _, tt := range tests {
+		if tt.source == "" {
+			os.Remove(source)
+		} else {
+			writeFile(t, source, tt.source)
+		}
+
+		var stdout, stderr bytes.Buffer
+		if code := runCLI([]string{tt.path}, &stdout, &stderr); code != 0 {
+			t.Fatalf("%s - exit code %d: %s", tt.name, code, stderr.String())
+		}
+		if stdout.String() != tt.expected {
+			t.Errorf("%s - wrong output. want=%q, got=%q", tt.name, tt.expected, stdout.String())
+		}
+	}
+}
+
+func TestCLIErrors(t *testing.T) {
+	// This is a test function for the errors reported by the CLI and its exit codes
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "parse.mk"), "let = 1;")
+	writeFile(t, filepath.Join(dir, "runtime.mk"), "1 / 0")
+	writeFile(t, filepath.Join(dir, "bad.mkc"), "MKC\x00\x00\x04 not bytecode")
+
+	tests := []struct {
+		args     []string
+		code     int
+		expected string
+	}{
+		{[]string{"build"}, 2, "usage:"},
+		{[]string{"a.mk", "b.mk"}, 2, "usage:"},
+		{[]string{filepath.Join(dir, "missing.mk")}, 1, "no such file or directory"},
+		{[]string{filepath.Join(dir, "parse.mk")}, 1, "parser errors:"},
+		{[]string{filepath.Join(dir, "runtime.mk")}, 1, "runtime error: division by zero"},
+		{[]string{filepath.Join(dir, "bad.mkc")}, 1, "corrupted .mkc file"},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		code := runCLI(tt.args, &stdout, &stderr)
+		if code != tt.code {
+			t.Errorf("%v - wrong exit code. want=%d, got=%d", tt.args, tt.code, code)
+		}
+		if !strings.Contains(stderr.String(), tt.expected) {
+			t.Errorf("%v - wrong error. want=%q in %q", tt.args, tt.expected, stderr.String())
+		}
+	}
+}
+
+func TestDisasm(t *testing.T) {
+	// This is a test function for the disasm command
+	// The lines of the source are only printed while the source still matches the bytecode
+	dir := t.TempDir()
+	source := filepath.Join(dir, "main.mk")
+	writeFile(t, source, "puts(1)")
+
+	tests := []struct {
+		args     []string
+		source   string // The new content of the source, "" to remove it
+		expected string
+	}{
+		{[]string{"disasm", source}, "puts(1)", "; line 1: puts(1)\n0000 OpGetBuiltin 1       ; puts\n"},
+		{[]string{"build", source}, "puts(1)", ""},
+		{[]string{"disasm", filepath.Join(dir, "main.mkc")}, "puts(1)", "; line 1: puts(1)\n"},
+		{[]string{"disasm", filepath.Join(dir, "main.mkc")}, "", "; line 1\n"},
+	}
+
+	for _, tt := range tests {
+		if tt.source == "" {
+			os.Remove(source)
+		}
+
+		var stdout, stderr bytes.Buffer
+		if code := runCLI(tt.args, &stdout, &stderr); code != 0 {
+			t.Fatalf("%v - exit code %d: %s", tt.args, code, stderr.String())
+		}
+		if !strings.Contains(stdout.String(), tt.expected) {
+			t.Errorf("%v - wrong output. want=%q in %q", tt.args, tt.expected, stdout.String())
+		}
+	}
+}
+
+func TestOptimizeFlag(t *testing.T) {
+	// This is a test function for the -O flag, which compiles the optimized source
+	// The optimized bytecode has the folded constant 7 and no call of double, so disasm tells which one is run
+	// A .mkc file built with the other setting is compiled again, and it is used as it is once its source is removed
+	dir := t.TempDir()
+	source := filepath.Join(dir, "main.mk")
+	compiled := filepath.Join(dir, "main.mkc")
+	writeFile(t, source, "let double = fn(x) { x * 2 }; puts(double(3) + 1)")
+
+	const optimized, plain = "OpConstant 2         ; 7", "OpGetGlobal"
+
+	tests := []struct {
+		args     []string
+		remove   bool // Whether to remove the source first
+		expected string
+		missing  string
+	}{
+		{[]string{source}, false, "7\n", ""},
+		{[]string{"-O", source}, false, "7\n", ""},
+		{[]string{"-O", "run", source}, false, "7\n", ""},
+		{[]string{"disasm", source}, false, plain, optimized},
+		{[]string{"-O", "disasm", source}, false, optimized, plain},
+
+		{[]string{"build", source}, false, "", ""},
+		{[]string{source}, false, "7\n", ""},
+		{[]string{"-O", source}, false, "7\n", ""},
+		{[]string{"-O", "disasm", source}, false, optimized, plain},
+		{[]string{"-O", "disasm", compiled}, false, optimized, plain},
+		{[]string{"disasm", compiled}, false, plain, optimized},
+
+		{[]string{"-O", "build", source}, false, "", ""},
+		{[]string{source}, false, "7\n", ""},
+		{[]string{"disasm", source}, false, plain, optimized},
+		{[]string{"disasm", compiled}, false, plain, optimized},
+		{[]string{"-O", "disasm", compiled}, false, optimized, plain},
+		{[]string{"disasm", compiled}, true, optimized, plain},
+		{[]string{compiled}, true, "7\n", ""},
+	}
+
+	for _, tt := range tests {
+		if tt.remove {
+			os.Remove(source)
+		}
+
+		var stdout, stderr bytes.Buffer
+		if code := runCLI(tt.args, &stdout, &stderr); code != 0 {
+			t.Fatalf("%v - exit code %d: %s", tt.args, code, stderr.String())
+		}
+		if !strings.Contains(stdout.String(), tt.expected) {
+			t.Errorf("%v - wrong output. want=%q in %q", tt.args, tt.expected, stdout.String())
+		}
+		if tt.missing != "" && strings.Contains(stdout.String(), tt.missing) {
+			t.Errorf("%v - wrong output. want no %q in %q", tt.args, tt.missing, stdout.String())
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// Instructions is a sequence of encoded instructions
+// Every instruction is an opcode byte followed by its operands, big-endian
+// For example, "OpConstant 65534" is encoded as the bytes OpConstant, 0xFF, 0xFE
+type Instructions []byte
+
+func (ins Instructions) String() string {
+	// Print one instruction per line, prefixed with its offset
+	// For example, "0000 OpConstant 1\n0003 OpPop\n"
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i += 1
+			continue
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+
+		fmt.Fprintf(&out, "%04d %s\n", i, ins.fmtInstruction(def, operands))
+
+		i += 1 + read
+	}
+
+	return out.String()
+}
+
+func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
+	operandCount := len(def.OperandWidths)
+
+	if len(operands) != operandCount {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n", len(operands), operandCount)
+	}
+
+	switch operandCount {
+	case 0:
+		return def.Name
+	case 1:
+		return fmt.Sprintf("%s %d", def.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
+	}
+
+	return fmt.Sprintf("ERROR: unhandled operandCount for %s\n", def.Name)
+}
+
+type Opcode byte
+
+const (
+	// OpConstant pushes the constant with the given index in the constant pool
+	OpConstant Opcode = iota
+	// OpPop pops the value on top of the stack, e.g. after an expression statement
+	OpPop
+
+	// The arithmetic operators pop two values and push the result, the right operand is on top
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpMod
+	OpPow
+
+	OpTrue
+	OpFalse
+	OpNull
+
+	// The comparisons pop two values and push true or false
+	// Every comparison has an opcode of its own, so the operands are always evaluated from left to right
+	OpEqual
+	OpNotEqual
+	OpGreaterThan
+	OpGreaterEqual
+	OpLessThan
+	OpLessEqual
+
+	// The prefix operators replace the value on top of the stack, "-x" and "!x"
+	OpMinus
+	OpBang
+
+	// OpJumpNotTruthy pops a value and jumps to the given offset if it is falsy
+	OpJumpNotTruthy
+	// OpJump always jumps to the given offset
+	OpJump
+
+	// The variables are stored in one of the 65536 globals, or in one of the 256 locals of the current function
+	// The set instructions pop the value they store
+	OpGetGlobal
+	OpSetGlobal
+	OpGetLocal
+	OpSetLocal
+	// OpGetBuiltin pushes the built-in function with the given index in object.Builtins
+	OpGetBuiltin
+	// OpGetFree pushes the free variable with the given index of the current closure, the value in its cell
+	OpGetFree
+	// OpCurrentClosure pushes the closure being run, so a function can call itself
+	OpCurrentClosure
+	// A local that a closure captures is kept in a cell, so the function and the closure share it
+	// OpGetCell and OpSetCell read and write the local with the given index through its cell
+	OpGetCell
+	OpSetCell
+	// OpCaptureLocal pushes the cell of the given local, putting the local in a new cell if it is not in one yet
+	// OpCaptureFree pushes the cell of the given free variable, for a closure created inside the current one
+	OpCaptureLocal
+	OpCaptureFree
+
+	// OpArray and OpHash build a collection from the given number of values on top of the stack
+	// For a hash, the number counts the keys and the values, so it is twice the number of pairs
+	OpArray
+	OpHash
+	// OpIndex pops an index and a collection, and pushes the element of the collection
+	OpIndex
+	// OpInterpolate joins the given number of values on top of the stack into a string
+	// A string is joined as it is, any other value as it is inspected
+	OpInterpolate
+
+	// OpCall calls the function below the given number of arguments on top of the stack
+	OpCall
+	// OpReturnValue returns the value on top of the stack from the current function
+	OpReturnValue
+	// OpReturn returns null from the current function
+	OpReturn
+	// OpClosure pushes a closure of the function constant with the given index
+	// The second operand is the number of free variables on top of the stack the closure captures
+	OpClosure
+
+	// OpIter replaces the value on top of the stack with an iterator over it, for a for loop
+	OpIter
+	// OpIterNext pushes the next element of the iterator on top of the stack
+	// When there is none, it pops the iterator and jumps to the given offset instead
+	OpIterNext
+)
+
+// Definition tells the name of an opcode and how many bytes every operand takes
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+	OpPop:      {"OpPop", []int{}},
+
+	OpAdd: {"OpAdd", []int{}},
+	OpSub: {"OpSub", []int{}},
+	OpMul: {"OpMul", []int{}},
+	OpDiv: {"OpDiv", []int{}},
+	OpMod: {"OpMod", []int{}},
+	OpPow: {"OpPow", []int{}},
+
+	OpTrue:  {"OpTrue", []int{}},
+	OpFalse: {"OpFalse", []int{}},
+	OpNull:  {"OpNull", []int{}},
+
+	OpEqual:        {"OpEqual", []int{}},
+	OpNotEqual:     {"OpNotEqual", []int{}},
+	OpGreaterThan:  {"OpGreaterThan", []int{}},
+	OpGreaterEqual: {"OpGreaterEqual", []int{}},
+	OpLessThan:     {"OpLessThan", []int{}},
+	OpLessEqual:    {"OpLessEqual", []int{}},
+
+	OpMinus: {"OpMinus", []int{}},
+	OpBang:  {"OpBang", []int{}},
+
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+	OpJump:          {"OpJump", []int{2}},
+
+	OpGetGlobal:      {"OpGetGlobal", []int{2}},
+	OpSetGlobal:      {"OpSetGlobal", []int{2}},
+	OpGetLocal:       {"OpGetLocal", []int{1}},
+	OpSetLocal:       {"OpSetLocal", []int{1}},
+	OpGetBuiltin:     {"OpGetBuiltin", []int{1}},
+	OpGetFree:        {"OpGetFree", []int{1}},
+	OpCurrentClosure: {"OpCurrentClosure", []int{}},
+	OpGetCell:        {"OpGetCell", []int{1}},
+	OpSetCell:        {"OpSetCell", []int{1}},
+	OpCaptureLocal:   {"OpCaptureLocal", []int{1}},
+	OpCaptureFree:    {"OpCaptureFree", []int{1}},
+
+	OpArray:       {"OpArray", []int{2}},
+	OpHash:        {"OpHash", []int{2}},
+	OpIndex:       {"OpIndex", []int{}},
+	OpInterpolate: {"OpInterpolate", []int{2}},
+
+	OpCall:        {"OpCall", []int{1}},
+	OpReturnValue: {"OpReturnValue", []int{}},
+	OpReturn:      {"OpReturn", []int{}},
+	OpClosure:     {"OpClosure", []int{2, 1}},
+
+	OpIter:     {"OpIter", []int{}},
+	OpIterNext: {"OpIterNext", []int{2}},
+}
+
+// Lookup returns the definition of the opcode
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+
+	return def, nil
+}
+
+// Make encodes an instruction
+// For example, Make(OpConstant, 65534) returns the bytes OpConstant, 0xFF, 0xFE
+// An undefined opcode gives an empty instruction
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	instructionLen := 1
+	for _, w := range def.OperandWidths {
+		instructionLen += w
+	}
+
+	instruction := make([]byte, instructionLen)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += width
+	}
+
+	return instruction
+}
+
+// ReadOperands decodes the operands of an instruction, the opposite of Make
+// It returns the operands and the number of bytes they took
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+
+		offset += width
+	}
+
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint8(ins Instructions) uint8 { return uint8(ins[0]) }
+
+// LineEntry tells that the instructions from Offset on were compiled from the given line of the source
+type LineEntry struct {
+	Offset int
+	Line   int
+}
+
+// LineTable maps the instructions of a function back to the lines of the source, in increasing order of Offset
+// Only the offsets where the line changes are recorded
+// For example, {{0, 1}, {9, 3}} maps the instructions before offset 9 to line 1 and the others to line 3
+type LineTable []LineEntry
+
+// Line returns the line the instruction at the offset was compiled from, or 0 if it is not known
+func (lt LineTable) Line(offset int) int {
+	i := sort.Search(len(lt), func(i int) bool { return lt[i].Offset > offset }) - 1
+	if i < 0 {
+		return 0
+	}
+	return lt[i].Line
+}
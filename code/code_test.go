@@ -0,0 +1,141 @@
+package code
+
+import "testing"
+
+func TestMake(t *testing.T) {
+	// This is a test function for encoding instructions
+	tests := []struct {
+		op       Opcode
+		operands []int
+		expected []byte
+	}{
+		{OpConstant, []int{65534}, []byte{byte(OpConstant), 255, 254}},
+		{OpAdd, []int{}, []byte{byte(OpAdd)}},
+		{OpGetLocal, []int{255}, []byte{byte(OpGetLocal), 255}},
+		{OpClosure, []int{65534, 255}, []byte{byte(OpClosure), 255, 254, 255}},
+		{OpIterNext, []int{258}, []byte{byte(OpIterNext), 1, 2}},
+		{Opcode(255), []int{1}, []byte{}},
+	}
+
+	for i, tt := range tests {
+		instruction := Make(tt.op, tt.operands...)
+
+		if len(instruction) != len(tt.expected) {
+			t.Errorf("tests[%d] - instruction has wrong length. expected=%d, got=%d",
+				i, len(tt.expected), len(instruction))
+			continue
+		}
+
+		for j, b := range tt.expected {
+			if instruction[j] != b {
+				t.Errorf("tests[%d] - wrong byte at pos %d. expected=%d, got=%d", i, j, b, instruction[j])
+			}
+		}
+	}
+}
+
+func TestInstructionsString(t *testing.T) {
+	// This is a test function for printing instructions, one per line with its offset
+	instructions := []Instructions{
+		Make(OpAdd),
+		Make(OpGetLocal, 1),
+		Make(OpConstant, 2),
+		Make(OpConstant, 65535),
+		Make(OpClosure, 65535, 255),
+		Make(OpLessEqual),
+		Make(OpIterNext, 7),
+	}
+
+	expected := `0000 OpAdd
+0001 OpGetLocal 1
+0003 OpConstant 2
+0006 OpConstant 65535
+0009 OpClosure 65535 255
+0013 OpLessEqual
+0014 OpIterNext 7
+`
+
+	concatted := Instructions{}
+	for _, ins := range instructions {
+		concatted = append(concatted, ins...)
+	}
+
+	if concatted.String() != expected {
+		t.Errorf("instructions wrongly formatted.\nwant=%q\ngot=%q", expected, concatted.String())
+	}
+}
+
+func TestReadOperands(t *testing.T) {
+	// This is a test function for decoding the operands of an instruction, the opposite of Make
+	tests := []struct {
+		op        Opcode
+		operands  []int
+		bytesRead int
+	}{
+		{OpConstant, []int{65535}, 2},
+		{OpGetLocal, []int{255}, 1},
+		{OpClosure, []int{65535, 255}, 3},
+	}
+
+	for i, tt := range tests {
+		instruction := Make(tt.op, tt.operands...)
+
+		def, err := Lookup(byte(tt.op))
+		if err != nil {
+			t.Fatalf("tests[%d] - definition not found: %q", i, err)
+		}
+
+		operandsRead, n := ReadOperands(def, instruction[1:])
+		if n != tt.bytesRead {
+			t.Fatalf("tests[%d] - n wrong. want=%d, got=%d", i, tt.bytesRead, n)
+		}
+
+		for j, want := range tt.operands {
+			if operandsRead[j] != want {
+				t.Errorf("tests[%d] - operand wrong. want=%d, got=%d", i, want, operandsRead[j])
+			}
+		}
+	}
+}
+
+func TestEveryOpcodeIsDefined(t *testing.T) {
+	// This is a test function for the definitions table
+	// Every opcode up to the last one must have a definition with a unique name
+	names := map[string]bool{}
+	for op := OpConstant; op <= OpIterNext; op++ {
+		def, err := Lookup(byte(op))
+		if err != nil {
+			t.Fatalf("opcode %d has no definition", op)
+		}
+		if names[def.Name] {
+			t.Errorf("opcode name %s is used twice", def.Name)
+		}
+		names[def.Name] = true
+	}
+}
+
+func TestLineTable(t *testing.T) {
+	// This is a test function for looking up the line of an instruction
+	lines := LineTable{{Offset: 0, Line: 1}, {Offset: 9, Line: 3}, {Offset: 12, Line: 4}}
+
+	tests := []struct {
+		offset   int
+		expected int
+	}{
+		{0, 1},
+		{8, 1},
+		{9, 3},
+		{11, 3},
+		{100, 4},
+	}
+
+	for i, tt := range tests {
+		if line := lines.Line(tt.offset); line != tt.expected {
+			t.Errorf("tests[%d] - wrong line. want=%d, got=%d", i, tt.expected, line)
+		}
+	}
+
+	if line := (LineTable{}).Line(0); line != 0 {
+		t.Errorf("empty table has line %d", line)
+	}
+}
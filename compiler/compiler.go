@@ -0,0 +1,850 @@
+package compiler
+
+import (
+	"fmt"
+	"interpreter/ast"
+	"interpreter/code"
+	"interpreter/object"
+	"interpreter/token"
+)
+
+// Compiler lowers an AST to bytecode for the vm package
+// For example, "1 + 2" is compiled to "OpConstant 0, OpConstant 1, OpAdd, OpPop" with the constants 1 and 2
+type Compiler struct {
+	constants []object.Object
+
+	symbolTable *SymbolTable
+
+	// Every function literal is compiled in a scope of its own, the main program is scopes[0]
+	scopes     []CompilationScope
+	scopeIndex int
+
+	// The FileSet the positions of the AST are resolved with, and the position of the node being compiled
+	// Without a FileSet, no lines are recorded
+	fset *token.FileSet
+	pos  token.Pos
+}
+
+// Option configures a Compiler created by New
+type Option func(*Compiler)
+
+// WithFileSet makes the Compiler record the line of the source every instruction was compiled from
+// The FileSet must be the one the lexer registered the source in, e.g. with lexer.WithFile
+func WithFileSet(fset *token.FileSet) Option {
+	return func(c *Compiler) {
+		c.fset = fset
+	}
+}
+
+// EmittedInstruction remembers an instruction that was emitted, so it can be looked at or removed again
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+// CompilationScope holds the instructions of the function being compiled
+type CompilationScope struct {
+	instructions        code.Instructions
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+	lines               code.LineTable
+
+	// The loops around the code being compiled, the innermost last
+	loops []*loop
+
+	// The locals of the function that a closure created in it captures, by index
+	captured map[int]bool
+}
+
+// loop is where a break or a continue of a loop jumps to
+// The end of the loop is not known while its body is compiled, so the breaks are patched afterwards
+type loop struct {
+	start  int
+	breaks []int
+}
+
+// Bytecode is what the compiler produces and the vm runs
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []object.Object
+	// The lines of the source the main program was compiled from, the functions have their own
+	Lines code.LineTable
+	// The names of the globals by index, so the vm can name a global that is read before it is set
+	GlobalNames []string
+}
+
+func New(opts ...Option) *Compiler {
+	mainScope := CompilationScope{
+		instructions:        code.Instructions{},
+		lastInstruction:     EmittedInstruction{},
+		previousInstruction: EmittedInstruction{},
+	}
+
+	symbolTable := NewSymbolTable()
+	for i, v := range object.Builtins {
+		symbolTable.DefineBuiltin(i, v.Name)
+	}
+
+	c := &Compiler{
+		constants:   []object.Object{},
+		symbolTable: symbolTable,
+		scopes:      []CompilationScope{mainScope},
+		scopeIndex:  0,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// NewWithState creates a Compiler that goes on from the globals and the constants of a previous one
+// A REPL uses it to compile every line in turn, keeping the bindings of the previous lines
+func NewWithState(s *SymbolTable, constants []object.Object, opts ...Option) *Compiler {
+	compiler := New(opts...)
+	compiler.symbolTable = s
+	compiler.constants = constants
+	return compiler
+}
+
+// SymbolTable returns the global symbols, to be passed on to NewWithState
+func (c *Compiler) SymbolTable() *SymbolTable {
+	return c.symbolTable
+}
+
+// The operators that map to a single opcode
+var infixOperators = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	"%":  code.OpMod,
+	"**": code.OpPow,
+	"==": code.OpEqual,
+	"!=": code.OpNotEqual,
+	">":  code.OpGreaterThan,
+	">=": code.OpGreaterEqual,
+	"<":  code.OpLessThan,
+	"<=": code.OpLessEqual,
+}
+
+// Compile compiles the node and everything below it
+// It returns an error for what can be told wrong before running the program, like an undefined variable
+func (c *Compiler) Compile(node ast.Node) error {
+	// The instructions of the node are mapped to its position, until a node inside it is compiled
+	// For example, the OpAdd of "a +\n b" is on the line of the +, the OpGetGlobal of b on the next one
+	if pos := node.Pos(); pos.IsValid() {
+		outer := c.pos
+		c.pos = pos
+		defer func() { c.pos = outer }()
+	}
+
+	switch node := node.(type) {
+	case *ast.Program:
+		c.symbolTable.Declare(declaredNames(node.Statements)...)
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+
+		// The constant indexes and the jump targets are 2-byte operands, so they can't go beyond 0xFFFF
+		// They are only checked once the program is compiled, as it can't be run anyway when they do
+		if len(c.constants) > 0xFFFF+1 {
+			return fmt.Errorf("too many constants")
+		}
+		if len(c.currentInstructions()) > 0xFFFF {
+			return fmt.Errorf("too many instructions")
+		}
+
+	case *ast.ExpressionStatement:
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+
+	case *ast.LetStatement:
+		return c.compileLetStatement(node)
+
+	case *ast.ReturnStatement:
+		if err := c.Compile(node.ReturnValue); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+
+	case *ast.WhileStatement:
+		return c.compileWhileStatement(node)
+
+	case *ast.ForStatement:
+		return c.compileForStatement(node)
+
+	case *ast.BranchStatement:
+		return c.compileBranchStatement(node)
+
+	case *ast.PrefixExpression:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+
+		switch node.Operator {
+		case "!":
+			c.emit(code.OpBang)
+		case "-":
+			c.emit(code.OpMinus)
+		default:
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+
+	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return c.compileLogicalExpression(node)
+		}
+
+		op, ok := infixOperators[node.Operator]
+		if !ok {
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		c.emit(op)
+
+	case *ast.IfExpression:
+		return c.compileIfExpression(node)
+
+	case *ast.IntegerLiteral:
+		integer := &object.Integer{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(integer))
+
+	case *ast.FloatLiteral:
+		float := &object.Float{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(float))
+
+	case *ast.StringLiteral:
+		str := &object.String{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(str))
+
+	case *ast.InterpolatedString:
+		return c.compileInterpolatedString(node)
+
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(node.Value)
+		if !ok {
+			return fmt.Errorf("identifier not found: %s", node.Value)
+		}
+		c.loadSymbol(symbol)
+
+	case *ast.ArrayLiteral:
+		if len(node.Elements) > 0xFFFF {
+			return fmt.Errorf("too many elements")
+		}
+		for _, el := range node.Elements {
+			if err := c.Compile(el); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+
+	case *ast.HashLiteral:
+		// The pairs are compiled in the order they are written, a key and then its value
+		if len(node.Pairs)*2 > 0xFFFF {
+			return fmt.Errorf("too many hash pairs")
+		}
+		for _, pair := range node.Pairs {
+			if err := c.Compile(pair.Key); err != nil {
+				return err
+			}
+			if err := c.Compile(pair.Value); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpHash, len(node.Pairs)*2)
+
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+
+	case *ast.FunctionLiteral:
+		return c.compileFunctionLiteral(node, "")
+
+	case *ast.CallExpression:
+		if len(node.Arguments) > 0xFF {
+			return fmt.Errorf("too many arguments")
+		}
+		if err := c.Compile(node.Function); err != nil {
+			return err
+		}
+
+		for _, a := range node.Arguments {
+			if err := c.Compile(a); err != nil {
+				return err
+			}
+		}
+
+		c.emit(code.OpCall, len(node.Arguments))
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileLetStatement(node *ast.LetStatement) error {
+	// A function is bound before its body is compiled, so it can refer to itself
+	// Any other value is compiled first, so in "let x = x + 1" the x on the right is the one bound before
+	var symbol Symbol
+
+	if fn, ok := node.Value.(*ast.FunctionLiteral); ok {
+		symbol = c.symbolTable.Define(node.Name.Value)
+		if err := c.compileFunctionLiteral(fn, node.Name.Value); err != nil {
+			return err
+		}
+	} else {
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		symbol = c.symbolTable.Define(node.Name.Value)
+	}
+
+	return c.storeSymbol(symbol)
+}
+
+func (c *Compiler) storeSymbol(symbol Symbol) error {
+	// Pop the value on top of the stack into the variable
+	if symbol.Scope == GlobalScope {
+		if symbol.Index > 0xFFFF {
+			return fmt.Errorf("too many global variables")
+		}
+		c.emit(code.OpSetGlobal, symbol.Index)
+	} else {
+		if symbol.Index > 0xFF {
+			return fmt.Errorf("too many local variables")
+		}
+		c.emit(code.OpSetLocal, symbol.Index)
+	}
+	return nil
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case BuiltinScope:
+		c.emit(code.OpGetBuiltin, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	case FunctionScope:
+		c.emit(code.OpCurrentClosure)
+	}
+}
+
+func (c *Compiler) compileLogicalExpression(node *ast.InfixExpression) error {
+	// && and || short-circuit, so the right side is only run when it decides the result
+	// Like in the evaluator, the result is always true or false
+	// For example, "a && b" is compiled to:
+	//   a; OpJumpNotTruthy false; b; OpJumpNotTruthy false; OpTrue; OpJump end; false: OpFalse; end:
+	if err := c.Compile(node.Left); err != nil {
+		return err
+	}
+
+	var toFalse, toTrue []int
+
+	if node.Operator == "&&" {
+		toFalse = append(toFalse, c.emit(code.OpJumpNotTruthy, 9999))
+	} else {
+		// The left side is falsy when the jump is taken, so the right side decides
+		rightPos := c.emit(code.OpJumpNotTruthy, 9999)
+		c.emit(code.OpTrue)
+		toTrue = append(toTrue, c.emit(code.OpJump, 9999))
+		c.changeOperand(rightPos, len(c.currentInstructions()))
+	}
+
+	if err := c.Compile(node.Right); err != nil {
+		return err
+	}
+	toFalse = append(toFalse, c.emit(code.OpJumpNotTruthy, 9999))
+	c.emit(code.OpTrue)
+	toTrue = append(toTrue, c.emit(code.OpJump, 9999))
+
+	for _, pos := range toFalse {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+	c.emit(code.OpFalse)
+
+	for _, pos := range toTrue {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileIfExpression(node *ast.IfExpression) error {
+	// The if expression leaves the value of the branch it takes on the stack, or null
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	// Emit an `OpJumpNotTruthy` with a bogus value, it is changed once the length of the consequence is known
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := c.compileBranch(node.Consequence); err != nil {
+		return err
+	}
+
+	jumpPos := c.emit(code.OpJump, 9999)
+
+	afterConsequencePos := len(c.currentInstructions())
+	c.changeOperand(jumpNotTruthyPos, afterConsequencePos)
+
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else {
+		if err := c.compileBranch(node.Alternative); err != nil {
+			return err
+		}
+	}
+
+	afterAlternativePos := len(c.currentInstructions())
+	c.changeOperand(jumpPos, afterAlternativePos)
+
+	return nil
+}
+
+func (c *Compiler) compileBranch(block *ast.BlockStatement) error {
+	// Compile a branch of an if so that it leaves exactly one value on the stack
+	// The value of the last expression statement is kept, a branch ending otherwise gives null
+	if err := c.Compile(block); err != nil {
+		return err
+	}
+
+	if endsWithExpression(block) {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
+
+	return nil
+}
+
+// declaredNames returns the names the statements bind, including the ones in their blocks, as a block has no scope of its own
+// The function literals are left out, as the names bound in them are in a scope of their own
+// For example, for "let a = 1; if (a) { let b = 2 }; for (c in d) { }" the names are a, b and c
+func declaredNames(statements []ast.Statement) []string {
+	var names []string
+
+	for _, statement := range statements {
+		switch s := statement.(type) {
+		case *ast.LetStatement:
+			names = append(names, s.Name.Value)
+		case *ast.WhileStatement:
+			names = append(names, declaredNames(s.Body.Statements)...)
+		case *ast.ForStatement:
+			names = append(names, s.Variable.Value)
+			names = append(names, declaredNames(s.Body.Statements)...)
+		case *ast.ExpressionStatement:
+			if ifExpression, ok := s.Expression.(*ast.IfExpression); ok {
+				names = append(names, declaredNames(ifExpression.Consequence.Statements)...)
+				if ifExpression.Alternative != nil {
+					names = append(names, declaredNames(ifExpression.Alternative.Statements)...)
+				}
+			}
+		}
+	}
+
+	return names
+}
+
+func endsWithExpression(block *ast.BlockStatement) bool {
+	if len(block.Statements) == 0 {
+		return false
+	}
+	_, ok := block.Statements[len(block.Statements)-1].(*ast.ExpressionStatement)
+	return ok
+}
+
+func (c *Compiler) compileInterpolatedString(node *ast.InterpolatedString) error {
+	// Push the texts and the values of the expressions in order and join them
+	// The empty texts are left out, so "${x}" only joins the value of x
+	parts := 0
+
+	for i, e := range node.Expressions {
+		if node.Texts[i] != "" {
+			c.emit(code.OpConstant, c.addConstant(&object.String{Value: node.Texts[i]}))
+			parts += 1
+		}
+
+		if err := c.Compile(e); err != nil {
+			return err
+		}
+		parts += 1
+	}
+
+	if last := node.Texts[len(node.Texts)-1]; last != "" {
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: last}))
+		parts += 1
+	}
+
+	if parts > 0xFFFF {
+		return fmt.Errorf("too many parts in an interpolated string")
+	}
+	c.emit(code.OpInterpolate, parts)
+
+	return nil
+}
+
+func (c *Compiler) compileWhileStatement(node *ast.WhileStatement) error {
+	// start: condition; OpJumpNotTruthy end; body; OpJump start; end:
+	// A continue jumps back to the condition and a break to the end
+	start := len(c.currentInstructions())
+
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+	exitPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := c.compileLoopBody(start, node.Body); err != nil {
+		return err
+	}
+	c.emit(code.OpJump, start)
+
+	end := len(c.currentInstructions())
+	c.changeOperand(exitPos, end)
+	c.patchBreaks(end)
+	c.emitLoopResult()
+
+	return nil
+}
+
+func (c *Compiler) compileForStatement(node *ast.ForStatement) error {
+	// iterable; OpIter; start: OpIterNext end; store the variable; body; OpJump start; OpPop; end:
+	// The iterator stays on the stack while the loop runs
+	// A break jumps to the OpPop that removes it, OpIterNext removes it itself when the loop is done
+	if err := c.Compile(node.Iterable); err != nil {
+		return err
+	}
+	c.emit(code.OpIter)
+
+	start := len(c.currentInstructions())
+	nextPos := c.emit(code.OpIterNext, 9999)
+
+	symbol := c.symbolTable.Define(node.Variable.Value)
+	if err := c.storeSymbol(symbol); err != nil {
+		return err
+	}
+
+	if err := c.compileLoopBody(start, node.Body); err != nil {
+		return err
+	}
+	c.emit(code.OpJump, start)
+
+	c.patchBreaks(len(c.currentInstructions()))
+	c.emit(code.OpPop)
+
+	c.changeOperand(nextPos, len(c.currentInstructions()))
+	c.emitLoopResult()
+
+	return nil
+}
+
+func (c *Compiler) compileLoopBody(start int, body *ast.BlockStatement) error {
+	// The values of the statements of the body are all popped, a loop has no value
+	scope := &c.scopes[c.scopeIndex]
+	scope.loops = append(scope.loops, &loop{start: start})
+
+	return c.Compile(body)
+}
+
+func (c *Compiler) emitLoopResult() {
+	// The value popped last is the result of the program, so a loop pops a null after it
+	// Otherwise the result would be whatever the loop popped last, like its condition or its iterator
+	// A function doesn't need it, its body returns null when it ends with a loop
+	if c.scopeIndex == 0 {
+		c.emit(code.OpNull)
+		c.emit(code.OpPop)
+	}
+}
+
+func (c *Compiler) patchBreaks(target int) {
+	// Point the breaks of the innermost loop to the target and leave the loop
+	scope := &c.scopes[c.scopeIndex]
+	innermost := scope.loops[len(scope.loops)-1]
+	scope.loops = scope.loops[:len(scope.loops)-1]
+
+	for _, pos := range innermost.breaks {
+		c.changeOperand(pos, target)
+	}
+}
+
+func (c *Compiler) compileBranchStatement(node *ast.BranchStatement) error {
+	scope := &c.scopes[c.scopeIndex]
+	if len(scope.loops) == 0 {
+		return fmt.Errorf("%s is not in a loop", node.Token.Literal)
+	}
+	innermost := scope.loops[len(scope.loops)-1]
+
+	if node.Token.Literal == "break" {
+		innermost.breaks = append(innermost.breaks, c.emit(code.OpJump, 9999))
+	} else {
+		c.emit(code.OpJump, innermost.start)
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral, name string) error {
+	// Compile the body in a scope of its own and push a closure of it
+	// The free variables the body uses are pushed first, so the closure can capture them
+	c.enterScope()
+
+	if name != "" {
+		c.symbolTable.DefineFunctionName(name)
+	}
+
+	for _, p := range node.Parameters {
+		c.symbolTable.Define(p.Value)
+	}
+	c.symbolTable.Declare(declaredNames(node.Body.Statements)...)
+
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+
+	// The value of the last expression statement is returned, a body ending otherwise returns null
+	if endsWithExpression(node.Body) {
+		c.replaceLastPopWithReturn()
+	}
+	if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpReturn)
+	}
+	c.useCells()
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.numDefinitions
+	localNames := c.symbolTable.names
+	lines := c.scopes[c.scopeIndex].lines
+	instructions := c.leaveScope()
+
+	if numLocals > 0xFF {
+		return fmt.Errorf("too many local variables")
+	}
+	if len(freeSymbols) > 0xFF {
+		return fmt.Errorf("too many free variables")
+	}
+	if len(instructions) > 0xFFFF {
+		return fmt.Errorf("too many instructions")
+	}
+
+	for _, s := range freeSymbols {
+		c.captureSymbol(s)
+	}
+
+	compiledFn := &object.CompiledFunction{
+		Instructions:  instructions,
+		NumLocals:     numLocals,
+		NumParameters: len(node.Parameters),
+		Lines:         lines,
+		LocalNames:    localNames,
+	}
+
+	fnIndex := c.addConstant(compiledFn)
+	c.emit(code.OpClosure, fnIndex, len(freeSymbols))
+
+	return nil
+}
+
+func (c *Compiler) captureSymbol(s Symbol) {
+	// Push the cell of a variable the closure captures, so the closure shares the variable instead of copying its value
+	// A local only gets a cell when it is captured, the closure being run is captured as it is
+	switch s.Scope {
+	case LocalScope:
+		scope := &c.scopes[c.scopeIndex]
+		if scope.captured == nil {
+			scope.captured = map[int]bool{}
+		}
+		scope.captured[s.Index] = true
+		c.emit(code.OpCaptureLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpCaptureFree, s.Index)
+	default:
+		c.loadSymbol(s)
+	}
+}
+
+func (c *Compiler) useCells() {
+	// Read and write the captured locals through their cells, once the whole body is compiled
+	// It is only known then which locals are captured, as a closure can use a local bound after it
+	// For example, in "let x = 0; let g = fn() { x }; x += 1;" both stores to x become an OpSetCell
+	scope := &c.scopes[c.scopeIndex]
+	if len(scope.captured) == 0 {
+		return
+	}
+	ins := scope.instructions
+
+	for ip := 0; ip < len(ins); {
+		def, _ := code.Lookup(ins[ip])
+		operands, read := code.ReadOperands(def, ins[ip+1:])
+
+		switch op := code.Opcode(ins[ip]); {
+		case op == code.OpGetLocal && scope.captured[operands[0]]:
+			ins[ip] = byte(code.OpGetCell)
+		case op == code.OpSetLocal && scope.captured[operands[0]]:
+			ins[ip] = byte(code.OpSetCell)
+		}
+
+		ip += 1 + read
+	}
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	// Append an instruction to the current scope and return its position
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+
+	c.setLastInstruction(op, pos)
+	c.addLine(pos)
+
+	return pos
+}
+
+func (c *Compiler) addLine(offset int) {
+	// Record the line of the node being compiled, if it is not the line of the previous instruction
+	if c.fset == nil {
+		return
+	}
+
+	line := c.fset.Position(c.pos).Line
+	lines := c.scopes[c.scopeIndex].lines
+	if len(lines) > 0 && lines[len(lines)-1].Line == line {
+		return
+	}
+
+	c.scopes[c.scopeIndex].lines = append(lines, code.LineEntry{Offset: offset, Line: line})
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	posNewInstruction := len(c.currentInstructions())
+	updatedInstructions := append(c.currentInstructions(), ins...)
+
+	c.scopes[c.scopeIndex].instructions = updatedInstructions
+
+	return posNewInstruction
+}
+
+func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
+	previous := c.scopes[c.scopeIndex].lastInstruction
+	last := EmittedInstruction{Opcode: op, Position: pos}
+
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = last
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastPop() {
+	last := c.scopes[c.scopeIndex].lastInstruction
+	previous := c.scopes[c.scopeIndex].previousInstruction
+
+	old := c.currentInstructions()
+	new := old[:last.Position]
+
+	c.scopes[c.scopeIndex].instructions = new
+	c.scopes[c.scopeIndex].lastInstruction = previous
+
+	// Forget the line of the removed instruction, if it started a new line
+	lines := c.scopes[c.scopeIndex].lines
+	if len(lines) > 0 && lines[len(lines)-1].Offset >= last.Position {
+		c.scopes[c.scopeIndex].lines = lines[:len(lines)-1]
+	}
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+
+	for i := 0; i < len(newInstruction); i++ {
+		ins[pos+i] = newInstruction[i]
+	}
+}
+
+func (c *Compiler) replaceLastPopWithReturn() {
+	lastPos := c.scopes[c.scopeIndex].lastInstruction.Position
+	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
+
+	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+}
+
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	// Change the operand of the instruction at the position, e.g. the target of a jump once it is known
+	op := code.Opcode(c.currentInstructions()[opPos])
+	newInstruction := code.Make(op, operand)
+
+	c.replaceInstruction(opPos, newInstruction)
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) enterScope() {
+	scope := CompilationScope{
+		instructions:        code.Instructions{},
+		lastInstruction:     EmittedInstruction{},
+		previousInstruction: EmittedInstruction{},
+	}
+	c.scopes = append(c.scopes, scope)
+	c.scopeIndex++
+
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() code.Instructions {
+	instructions := c.currentInstructions()
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+
+	c.symbolTable = c.symbolTable.Outer
+
+	return instructions
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Constants:    c.constants,
+		Lines:        c.scopes[c.scopeIndex].lines,
+		GlobalNames:  c.symbolTable.names,
+	}
+}
@@ -0,0 +1,696 @@
+package compiler
+
+import (
+	"fmt"
+	"interpreter/ast"
+	"interpreter/code"
+	"interpreter/lexer"
+	"interpreter/object"
+	"interpreter/parser"
+	"interpreter/token"
+	"strings"
+	"testing"
+)
+
+type compilerTestCase struct {
+	input                string
+	expectedConstants    []interface{}
+	expectedInstructions []code.Instructions
+}
+
+func TestArithmetic(t *testing.T) {
+	// This is a test function for compiling operators
+	// The comparisons keep the order of their operands
+	tests := []compilerTestCase{
+		{
+			input:             "1 + 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "1 < 2.5; -1",
+			expectedConstants: []interface{}{1, 2.5, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpLessThan),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpMinus),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "!true == false",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpBang),
+				code.Make(code.OpFalse),
+				code.Make(code.OpEqual),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestLogicalOperators(t *testing.T) {
+	// This is a test function for compiling && and ||, which jump over the right side
+	tests := []compilerTestCase{
+		{
+			input:             "true && false",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 12),
+				// 0004
+				code.Make(code.OpFalse),
+				// 0005
+				code.Make(code.OpJumpNotTruthy, 12),
+				// 0008
+				code.Make(code.OpTrue),
+				// 0009
+				code.Make(code.OpJump, 13),
+				// 0012
+				code.Make(code.OpFalse),
+				// 0013
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "true || false",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 8),
+				// 0004
+				code.Make(code.OpTrue),
+				// 0005
+				code.Make(code.OpJump, 17),
+				// 0008
+				code.Make(code.OpFalse),
+				// 0009
+				code.Make(code.OpJumpNotTruthy, 16),
+				// 0012
+				code.Make(code.OpTrue),
+				// 0013
+				code.Make(code.OpJump, 17),
+				// 0016
+				code.Make(code.OpFalse),
+				// 0017
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	// This is a test function for compiling if expressions
+	// A missing else branch, or a branch that doesn't end with an expression, gives null
+	tests := []compilerTestCase{
+		{
+			input:             "if (true) { 10 }; 3333;",
+			expectedConstants: []interface{}{10, 3333},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 10),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpJump, 11),
+				// 0010
+				code.Make(code.OpNull),
+				// 0011
+				code.Make(code.OpPop),
+				// 0012
+				code.Make(code.OpConstant, 1),
+				// 0015
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "if (true) { let a = 1; } else { 20 }",
+			expectedConstants: []interface{}{1, 20},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 14),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpSetGlobal, 0),
+				// 0010
+				code.Make(code.OpNull),
+				// 0011
+				code.Make(code.OpJump, 17),
+				// 0014
+				code.Make(code.OpConstant, 1),
+				// 0017
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestGlobalLetStatements(t *testing.T) {
+	// This is a test function for compiling globals
+	// Binding a name again stores to the same global, after the new value is computed from the old one
+	tests := []compilerTestCase{
+		{
+			input:             "let one = 1; let two = one; two;",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpGetGlobal, 1),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "let i = 0; let i = i + 1;",
+			expectedConstants: []interface{}{0, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpSetGlobal, 0),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestCollectionsAndStrings(t *testing.T) {
+	// This is a test function for compiling arrays, hashes, index expressions and interpolated strings
+	tests := []compilerTestCase{
+		{
+			input:             `[1, 2][0]`,
+			expectedConstants: []interface{}{1, 2, 0},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpIndex),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `{"a": 1}`,
+			expectedConstants: []interface{}{"a", 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpHash, 2),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `"${1} is ${2}!"`,
+			expectedConstants: []interface{}{1, " is ", 2, "!"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpInterpolate, 4),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestFunctions(t *testing.T) {
+	// This is a test function for compiling function literals and calls
+	// The value of the last expression statement is returned, otherwise null
+	tests := []compilerTestCase{
+		{
+			input: "fn(a) { a + 1 }(2)",
+			expectedConstants: []interface{}{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+				2,
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpCall, 1),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "fn() { let a = 1; }",
+			expectedConstants: []interface{}{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpReturn),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: "fn() { return len; }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetBuiltin, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestClosures(t *testing.T) {
+	// This is a test function for compiling free variables and functions that call themselves
+	tests := []compilerTestCase{
+		{
+			input: "fn(a) { fn(b) { a + b } }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpCaptureLocal, 0),
+					code.Make(code.OpClosure, 0, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			// A captured local is read and written through its cell, also before the closure is created
+			input: "fn() { let x = 0; let g = fn() { x }; x += 1; g() }",
+			expectedConstants: []interface{}{
+				0,
+				[]code.Instructions{
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpReturnValue),
+				},
+				1,
+				[]code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSetCell, 0),
+					code.Make(code.OpCaptureLocal, 0),
+					code.Make(code.OpClosure, 1, 1),
+					code.Make(code.OpSetLocal, 1),
+					code.Make(code.OpGetCell, 0),
+					code.Make(code.OpConstant, 2),
+					code.Make(code.OpAdd),
+					code.Make(code.OpSetCell, 0),
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpCall, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 3, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			// A closure created in a closure passes on the cell of the free variable
+			input: "fn(a) { fn() { fn() { a } } }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpCaptureFree, 0),
+					code.Make(code.OpClosure, 0, 1),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpCaptureLocal, 0),
+					code.Make(code.OpClosure, 1, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			// A global bound after the function that uses it gets its index when it is first used
+			input: "let f = fn() { g() }; let g = fn() { 1 };",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetGlobal, 1),
+					code.Make(code.OpCall, 0),
+					code.Make(code.OpReturnValue),
+				},
+				1,
+				[]code.Instructions{
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpSetGlobal, 1),
+			},
+		},
+		{
+			input: "let f = fn() { let g = fn() { g() }; g };",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpCurrentClosure),
+					code.Make(code.OpCall, 0),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpClosure, 0, 0),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpSetGlobal, 0),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestLoops(t *testing.T) {
+	// This is a test function for compiling loops
+	// A break jumps past the loop, a continue back to its start
+	// A loop of the main program ends with a null popped, the result of the program when it is the last statement
+	tests := []compilerTestCase{
+		{
+			input:             "while (true) { break; continue; }",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 13),
+				// 0004
+				code.Make(code.OpJump, 13),
+				// 0007
+				code.Make(code.OpJump, 0),
+				// 0010
+				code.Make(code.OpJump, 0),
+				// 0013
+				code.Make(code.OpNull),
+				// 0014
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "for (x in [1]) { if (x) { break } }",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpArray, 1),
+				// 0006
+				code.Make(code.OpIter),
+				// 0007
+				code.Make(code.OpIterNext, 32),
+				// 0010
+				code.Make(code.OpSetGlobal, 0),
+				// 0013
+				code.Make(code.OpGetGlobal, 0),
+				// 0016
+				code.Make(code.OpJumpNotTruthy, 26),
+				// 0019
+				code.Make(code.OpJump, 31),
+				// 0022
+				code.Make(code.OpNull),
+				// 0023
+				code.Make(code.OpJump, 27),
+				// 0026
+				code.Make(code.OpNull),
+				// 0027
+				code.Make(code.OpPop),
+				// 0028
+				code.Make(code.OpJump, 7),
+				// 0031
+				code.Make(code.OpPop),
+				// 0032
+				code.Make(code.OpNull),
+				// 0033
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestCompilerErrors(t *testing.T) {
+	// This is a test function for the errors found while compiling
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x", "identifier not found: x"},
+		{"let f = fn() { y }", "identifier not found: y"},
+		// A name bound later is resolved, and only fails at run time if it is read before it is set
+		{"let f = fn() { g() }; let h = 1;", "identifier not found: g"},
+		// The operands of the instructions have a fixed width, so the programs too large for them are rejected
+		{"[" + repeatList("true", 0xFFFF+1) + "]", "too many elements"},
+		{"{" + repeatList("true: true", 0x8000) + "}", "too many hash pairs"},
+		{"let f = fn() {}; f(" + repeatList("true", 0xFF+1) + ")", "too many arguments"},
+		{strings.Repeat("1;", 0xFFFF+2), "too many constants"},
+		{strings.Repeat("true;", 0x8000), "too many instructions"},
+		{"fn() {" + strings.Repeat("true;", 0x8000+1) + "}", "too many instructions"},
+		{manyLocals(0xFF + 1), "too many local variables"},
+		{manyFreeVariables(0xFF + 1), "too many free variables"},
+	}
+
+	for i, tt := range tests {
+		compiler := New()
+		err := compiler.Compile(parse(tt.input))
+		if err == nil {
+			t.Errorf("tests[%d] - expected compiler error but got none", i)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("tests[%d] - wrong error. expected=%q, got=%q", i, tt.expected, err.Error())
+		}
+	}
+}
+
+// repeatList returns n times the item, separated by commas
+func repeatList(item string, n int) string {
+	return strings.TrimSuffix(strings.Repeat(item+", ", n), ", ")
+}
+
+// varName returns a distinct variable name for every number, made of letters only, e.g. "vab" for 1
+func varName(i int) string {
+	return fmt.Sprintf("v%c%c", 'a'+i/26, 'a'+i%26)
+}
+
+// manyLocals returns a function defining n local variables
+func manyLocals(n int) string {
+	var lets strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&lets, "let %s = 1;", varName(i))
+	}
+	return "fn() {" + lets.String() + "}"
+}
+
+// manyFreeVariables returns a closure using n variables of the functions around it
+// They are split between two functions, as a function can't have more than 256 local variables
+func manyFreeVariables(n int) string {
+	var outer, middle, uses strings.Builder
+	for i := 0; i < n; i++ {
+		name := varName(i)
+		if i%2 == 0 {
+			fmt.Fprintf(&outer, "let %s = %d;", name, i)
+		} else {
+			fmt.Fprintf(&middle, "let %s = %d;", name, i)
+		}
+		fmt.Fprintf(&uses, "%s;", name)
+	}
+	return fmt.Sprintf("fn() { %s fn() { %s fn() { %s } } }", outer.String(), middle.String(), uses.String())
+}
+
+func runCompilerTests(t *testing.T, tests []compilerTestCase) {
+	t.Helper()
+
+	for i, tt := range tests {
+		program := parse(tt.input)
+
+		compiler := New()
+		if err := compiler.Compile(program); err != nil {
+			t.Fatalf("tests[%d] - compiler error: %s", i, err)
+		}
+
+		bytecode := compiler.Bytecode()
+
+		testInstructions(t, i, tt.expectedInstructions, bytecode.Instructions)
+		testConstants(t, i, tt.expectedConstants, bytecode.Constants)
+	}
+}
+
+func parse(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
+
+func concatInstructions(s []code.Instructions) code.Instructions {
+	out := code.Instructions{}
+
+	for _, ins := range s {
+		out = append(out, ins...)
+	}
+
+	return out
+}
+
+func testInstructions(t *testing.T, i int, expected []code.Instructions, actual code.Instructions) {
+	t.Helper()
+
+	concatted := concatInstructions(expected)
+	if actual.String() != concatted.String() {
+		t.Errorf("tests[%d] - wrong instructions.\nwant=\n%s\ngot=\n%s", i, concatted, actual)
+	}
+}
+
+func testConstants(t *testing.T, i int, expected []interface{}, actual []object.Object) {
+	t.Helper()
+
+	if len(expected) != len(actual) {
+		t.Errorf("tests[%d] - wrong number of constants. want=%d, got=%d", i, len(expected), len(actual))
+		return
+	}
+
+	for j, constant := range expected {
+		switch constant := constant.(type) {
+		case int:
+			result, ok := actual[j].(*object.Integer)
+			if !ok || result.Value != int64(constant) {
+				t.Errorf("tests[%d] - constant %d is not Integer %d. got=%+v", i, j, constant, actual[j])
+			}
+		case float64:
+			result, ok := actual[j].(*object.Float)
+			if !ok || result.Value != constant {
+				t.Errorf("tests[%d] - constant %d is not Float %g. got=%+v", i, j, constant, actual[j])
+			}
+		case string:
+			result, ok := actual[j].(*object.String)
+			if !ok || result.Value != constant {
+				t.Errorf("tests[%d] - constant %d is not String %q. got=%+v", i, j, constant, actual[j])
+			}
+		case []code.Instructions:
+			fn, ok := actual[j].(*object.CompiledFunction)
+			if !ok {
+				t.Errorf("tests[%d] - constant %d is not a function. got=%T", i, j, actual[j])
+				continue
+			}
+			testInstructions(t, i, constant, fn.Instructions)
+		}
+	}
+}
+
+func TestLines(t *testing.T) {
+	// This is a test function for the line tables of the main program and of the functions
+	// The + is on line 2, so the OpAdd is too, while the 2 after it is on line 3
+	input := "let a = 1;\nlet b = a +\n  2;\nlet f = fn() {\n  a\n};"
+
+	fset := token.NewFileSet()
+	p := parser.New(lexer.New(input, lexer.WithFile(fset, "test.mk")))
+	program := p.ParseProgram()
+
+	compiler := New(WithFileSet(fset))
+	if err := compiler.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	bytecode := compiler.Bytecode()
+
+	// 0000 OpConstant 0, 0003 OpSetGlobal 0, 0006 OpGetGlobal 0, 0009 OpConstant 1, 0012 OpAdd, 0013 OpSetGlobal 1,
+	// 0016 OpClosure 2 0, 0020 OpSetGlobal 2
+	expected := code.LineTable{
+		{Offset: 0, Line: 1},
+		{Offset: 6, Line: 2},
+		{Offset: 9, Line: 3},
+		{Offset: 12, Line: 2},
+		{Offset: 16, Line: 4},
+	}
+	testLines(t, "main", expected, bytecode.Lines)
+
+	fn := bytecode.Constants[2].(*object.CompiledFunction)
+	testLines(t, "fn", code.LineTable{{Offset: 0, Line: 5}}, fn.Lines)
+
+	// Without a FileSet, no lines are recorded
+	compiler = New()
+	if err := compiler.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	if len(compiler.Bytecode().Lines) != 0 {
+		t.Errorf("lines recorded without a FileSet. got=%v", compiler.Bytecode().Lines)
+	}
+}
+
+func testLines(t *testing.T, name string, expected, actual code.LineTable) {
+	t.Helper()
+
+	if len(actual) != len(expected) {
+		t.Errorf("%s - wrong line table. want=%v, got=%v", name, expected, actual)
+		return
+	}
+	for i := range expected {
+		if actual[i] != expected[i] {
+			t.Errorf("%s - wrong line table. want=%v, got=%v", name, expected, actual)
+			return
+		}
+	}
+}
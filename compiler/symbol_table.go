@@ -0,0 +1,132 @@
+package compiler
+
+type SymbolScope string
+
+const (
+	GlobalScope   SymbolScope = "GLOBAL"
+	LocalScope    SymbolScope = "LOCAL"
+	BuiltinScope  SymbolScope = "BUILTIN"
+	FreeScope     SymbolScope = "FREE"
+	FunctionScope SymbolScope = "FUNCTION"
+)
+
+// Symbol is a name the compiler resolved, with where its value is stored
+// For example, the first global of a program is Symbol{Name: "x", Scope: GlobalScope, Index: 0}
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+}
+
+// SymbolTable maps the names of a scope to symbols
+// Every function body gets a new SymbolTable enclosed by the one of the code around it
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store          map[string]Symbol
+	numDefinitions int
+	// The names of the globals or the locals defined in this scope, by their index
+	names []string
+	// The names bound anywhere in this scope, by a let or a for loop, including the ones not defined yet
+	declared map[string]bool
+
+	// The symbols of the outer scopes that the function uses, in the order they were first used
+	FreeSymbols []Symbol
+}
+
+func NewSymbolTable() *SymbolTable {
+	s := make(map[string]Symbol)
+	return &SymbolTable{store: s}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// Define binds the name in this scope, as a global at the top level and as a local in a function
+// Defining a name again in the same scope reuses its slot, like "let" binds the same name again in the evaluator
+// For example, in "let i = 0; let i = i + 1;" both statements store to the same global
+func (s *SymbolTable) Define(name string) Symbol {
+	scope := GlobalScope
+	if s.Outer != nil {
+		scope = LocalScope
+	}
+
+	if symbol, ok := s.store[name]; ok && symbol.Scope == scope {
+		return symbol
+	}
+
+	symbol := Symbol{Name: name, Index: s.numDefinitions, Scope: scope}
+	s.store[name] = symbol
+	s.numDefinitions++
+	s.names = append(s.names, name)
+	return symbol
+}
+
+// Declare records names that are bound somewhere in this scope, before the statements binding them are compiled
+// A name used before its let statement is then defined ahead of time, so it can be resolved
+// For example, in "let f = fn() { g() }; let g = fn() { 1 };" f calls the global g, which is set once f runs
+func (s *SymbolTable) Declare(names ...string) {
+	if s.declared == nil {
+		s.declared = map[string]bool{}
+	}
+	for _, name := range names {
+		s.declared[name] = true
+	}
+}
+
+// DefineBuiltin binds the name of a built-in function to its index in object.Builtins
+func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
+	symbol := Symbol{Name: name, Index: index, Scope: BuiltinScope}
+	s.store[name] = symbol
+	return symbol
+}
+
+// DefineFunctionName binds the name a function literal is assigned to, so the function can call itself
+// For example, in "let fib = fn(n) { fib(n - 1) }" the inner fib refers to the closure being run
+func (s *SymbolTable) DefineFunctionName(name string) Symbol {
+	symbol := Symbol{Name: name, Index: 0, Scope: FunctionScope}
+	s.store[name] = symbol
+	return symbol
+}
+
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Index: len(s.FreeSymbols) - 1, Scope: FreeScope}
+	s.store[original.Name] = symbol
+	return symbol
+}
+
+// Resolve looks the name up in this scope and then in the outer ones
+// A local of an outer function becomes a free variable of this one, captured when the closure is created
+// A name that is not defined yet is defined in the outermost scope that declares it
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	obj, ok := s.store[name]
+	if !ok && s.Outer != nil {
+		obj, ok = s.Outer.Resolve(name)
+		if !ok {
+			return s.resolveDeclared(name)
+		}
+
+		if obj.Scope == GlobalScope || obj.Scope == BuiltinScope {
+			return obj, ok
+		}
+
+		free := s.defineFree(obj)
+		return free, true
+	}
+	if !ok {
+		return s.resolveDeclared(name)
+	}
+	return obj, ok
+}
+
+func (s *SymbolTable) resolveDeclared(name string) (Symbol, bool) {
+	if !s.declared[name] {
+		return Symbol{}, false
+	}
+	return s.Define(name), true
+}
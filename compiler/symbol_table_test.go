@@ -0,0 +1,121 @@
+package compiler
+
+import "testing"
+
+func TestDefine(t *testing.T) {
+	// This is a test function for defining globals and locals
+	expected := map[string]Symbol{
+		"a": {Name: "a", Scope: GlobalScope, Index: 0},
+		"b": {Name: "b", Scope: GlobalScope, Index: 1},
+		"c": {Name: "c", Scope: LocalScope, Index: 0},
+		"d": {Name: "d", Scope: LocalScope, Index: 1},
+	}
+
+	global := NewSymbolTable()
+	if a := global.Define("a"); a != expected["a"] {
+		t.Errorf("expected a=%+v, got=%+v", expected["a"], a)
+	}
+	if b := global.Define("b"); b != expected["b"] {
+		t.Errorf("expected b=%+v, got=%+v", expected["b"], b)
+	}
+	// Defining a name again reuses its slot
+	if a := global.Define("a"); a != expected["a"] {
+		t.Errorf("expected a=%+v, got=%+v", expected["a"], a)
+	}
+
+	local := NewEnclosedSymbolTable(global)
+	if c := local.Define("c"); c != expected["c"] {
+		t.Errorf("expected c=%+v, got=%+v", expected["c"], c)
+	}
+	if d := local.Define("d"); d != expected["d"] {
+		t.Errorf("expected d=%+v, got=%+v", expected["d"], d)
+	}
+	if local.numDefinitions != 2 {
+		t.Errorf("wrong number of locals. want=2, got=%d", local.numDefinitions)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	// This is a test function for resolving names through nested scopes
+	// A local of an outer function becomes a free variable, a global or a built-in stays what it is
+	global := NewSymbolTable()
+	global.Define("a")
+	global.DefineBuiltin(0, "len")
+
+	firstLocal := NewEnclosedSymbolTable(global)
+	firstLocal.Define("b")
+
+	secondLocal := NewEnclosedSymbolTable(firstLocal)
+	secondLocal.DefineFunctionName("f")
+	secondLocal.Define("c")
+
+	tests := []struct {
+		name     string
+		expected Symbol
+	}{
+		{"a", Symbol{Name: "a", Scope: GlobalScope, Index: 0}},
+		{"len", Symbol{Name: "len", Scope: BuiltinScope, Index: 0}},
+		{"b", Symbol{Name: "b", Scope: FreeScope, Index: 0}},
+		{"c", Symbol{Name: "c", Scope: LocalScope, Index: 0}},
+		{"f", Symbol{Name: "f", Scope: FunctionScope, Index: 0}},
+	}
+
+	for i, tt := range tests {
+		result, ok := secondLocal.Resolve(tt.name)
+		if !ok {
+			t.Errorf("tests[%d] - name %s not resolvable", i, tt.name)
+			continue
+		}
+		if result != tt.expected {
+			t.Errorf("tests[%d] - expected %s to resolve to %+v, got=%+v", i, tt.name, tt.expected, result)
+		}
+	}
+
+	if len(secondLocal.FreeSymbols) != 1 || secondLocal.FreeSymbols[0] != (Symbol{Name: "b", Scope: LocalScope, Index: 0}) {
+		t.Errorf("wrong free symbols. got=%+v", secondLocal.FreeSymbols)
+	}
+
+	if _, ok := secondLocal.Resolve("x"); ok {
+		t.Errorf("name x resolved, but was never defined")
+	}
+}
+
+func TestResolveDeclared(t *testing.T) {
+	// This is a test function for resolving names that are declared but not defined yet
+	// The name is defined in the outermost scope declaring it, and becomes a free variable of the inner ones
+	global := NewSymbolTable()
+	global.Declare("g")
+
+	firstLocal := NewEnclosedSymbolTable(global)
+	firstLocal.Define("a")
+	firstLocal.Declare("h", "g")
+
+	secondLocal := NewEnclosedSymbolTable(firstLocal)
+
+	tests := []struct {
+		name     string
+		expected Symbol
+	}{
+		{"g", Symbol{Name: "g", Scope: GlobalScope, Index: 0}},
+		{"h", Symbol{Name: "h", Scope: FreeScope, Index: 0}},
+	}
+
+	for i, tt := range tests {
+		result, ok := secondLocal.Resolve(tt.name)
+		if !ok {
+			t.Errorf("tests[%d] - name %s not resolvable", i, tt.name)
+			continue
+		}
+		if result != tt.expected {
+			t.Errorf("tests[%d] - expected %s to resolve to %+v, got=%+v", i, tt.name, tt.expected, result)
+		}
+	}
+
+	if h, ok := firstLocal.Resolve("h"); !ok || h != (Symbol{Name: "h", Scope: LocalScope, Index: 1}) {
+		t.Errorf("h not defined as a local of the declaring scope. got=%+v", h)
+	}
+
+	if _, ok := secondLocal.Resolve("x"); ok {
+		t.Errorf("name x resolved, but was never declared")
+	}
+}
@@ -0,0 +1,135 @@
+// Package disasm prints compiled Monkey bytecode in a human-readable form
+package disasm
+
+import (
+	"bytes"
+	"fmt"
+	"interpreter/code"
+	"interpreter/compiler"
+	"interpreter/object"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Disassemble writes the instructions of the main program, followed by those of every function in the constants
+// Every instruction is printed with its offset, its opcode and its operands,
+// and a comment telling what the operand refers to, e.g. the value of a constant or the name of a built-in function
+// When the line tables are known, the line the instructions were compiled from is printed before them
+// The text of the line is printed too when the source is given, it may be empty
+//
+// For example, "let x = 1 + 2;" is disassembled as:
+//
+//	== main ==
+//	; line 1: let x = 1 + 2;
+//	0000 OpConstant 0         ; 1
+//	0003 OpConstant 1         ; 2
+//	0006 OpAdd
+//	0007 OpSetGlobal 0
+func Disassemble(w io.Writer, bytecode *compiler.Bytecode, source string) error {
+	d := &disassembler{constants: bytecode.Constants}
+	if source != "" {
+		d.source = strings.Split(source, "\n")
+	}
+
+	d.function("main", bytecode.Instructions, bytecode.Lines)
+	for i, constant := range bytecode.Constants {
+		fn, ok := constant.(*object.CompiledFunction)
+		if !ok {
+			continue
+		}
+
+		title := fmt.Sprintf("fn %d (%s, %s)", i, plural(fn.NumParameters, "parameter"), plural(fn.NumLocals, "local"))
+		d.out.WriteString("\n")
+		d.function(title, fn.Instructions, fn.Lines)
+	}
+
+	_, err := w.Write(d.out.Bytes())
+	return err
+}
+
+type disassembler struct {
+	out       bytes.Buffer
+	constants []object.Object
+	source    []string
+}
+
+func (d *disassembler) function(title string, ins code.Instructions, lines code.LineTable) {
+	fmt.Fprintf(&d.out, "== %s ==\n", title)
+
+	line := 0
+	for offset := 0; offset < len(ins); {
+		if l := lines.Line(offset); l != 0 && l != line {
+			line = l
+			d.line(line)
+		}
+
+		def, err := code.Lookup(ins[offset])
+		if err != nil {
+			fmt.Fprintf(&d.out, "%04d ERROR: %s\n", offset, err)
+			offset += 1
+			continue
+		}
+		operands, read := code.ReadOperands(def, ins[offset+1:])
+
+		text := def.Name
+		for _, o := range operands {
+			text += " " + strconv.Itoa(o)
+		}
+		if comment := d.comment(code.Opcode(ins[offset]), operands); comment != "" {
+			text = fmt.Sprintf("%-20s ; %s", text, comment)
+		}
+		fmt.Fprintf(&d.out, "%04d %s\n", offset, text)
+
+		offset += 1 + read
+	}
+}
+
+func (d *disassembler) line(line int) {
+	if line > len(d.source) {
+		fmt.Fprintf(&d.out, "; line %d\n", line)
+		return
+	}
+	fmt.Fprintf(&d.out, "; line %d: %s\n", line, strings.TrimSpace(d.source[line-1]))
+}
+
+// comment tells what the operands of an instruction refer to, or returns "" if there is nothing to tell
+func (d *disassembler) comment(op code.Opcode, operands []int) string {
+	switch op {
+	case code.OpConstant:
+		return d.constant(operands[0])
+	case code.OpClosure:
+		return fmt.Sprintf("fn %d, %s", operands[0], plural(operands[1], "free variable"))
+	case code.OpGetBuiltin:
+		if operands[0] < len(object.Builtins) {
+			return object.Builtins[operands[0]].Name
+		}
+	case code.OpJump, code.OpJumpNotTruthy, code.OpIterNext:
+		return fmt.Sprintf("-> %04d", operands[0])
+	}
+	return ""
+}
+
+func (d *disassembler) constant(index int) string {
+	if index >= len(d.constants) {
+		return "unknown constant"
+	}
+
+	// Strings are quoted, so "1" is not mistaken for 1
+	switch constant := d.constants[index].(type) {
+	case *object.String:
+		return strconv.Quote(constant.Value)
+	case *object.CompiledFunction:
+		return fmt.Sprintf("fn %d", index)
+	default:
+		return constant.Inspect()
+	}
+}
+
+// plural returns the count followed by the word, e.g. "1 local" or "2 locals"
+func plural(n int, word string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, word)
+	}
+	return fmt.Sprintf("%d %ss", n, word)
+}
@@ -0,0 +1,114 @@
+package disasm
+
+import (
+	"bytes"
+	"interpreter/compiler"
+	"interpreter/lexer"
+	"interpreter/parser"
+	"interpreter/token"
+	"testing"
+)
+
+func compile(t *testing.T, input string, fset *token.FileSet) *compiler.Bytecode {
+	t.Helper()
+
+	var opts []lexer.Option
+	var compilerOpts []compiler.Option
+	if fset != nil {
+		opts = append(opts, lexer.WithFile(fset, "test.mk"))
+		compilerOpts = append(compilerOpts, compiler.WithFileSet(fset))
+	}
+
+	p := parser.New(lexer.New(input, opts...))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	c := compiler.New(compilerOpts...)
+	if err := c.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	return c.Bytecode()
+}
+
+func TestDisassemble(t *testing.T) {
+	// This is a test function for the text of the disassembly
+	// The same input is disassembled with the source, with the line tables only, and with neither
+	input := "let x = 1 + 2;\nlet f = fn(a) {\n  puts(a, \"x\")\n};\nif (x > 2) { f(x) }"
+
+	tests := []struct {
+		name     string
+		fset     *token.FileSet
+		source   string
+		expected string
+	}{
+		{
+			"source",
+			token.NewFileSet(),
+			input,
+			`== main ==
+; line 1: let x = 1 + 2;
+0000 OpConstant 0         ; 1
+0003 OpConstant 1         ; 2
+0006 OpAdd
+0007 OpSetGlobal 0
+; line 2: let f = fn(a) {
+0010 OpClosure 3 0        ; fn 3, 0 free variables
+0014 OpSetGlobal 1
+; line 5: if (x > 2) { f(x) }
+0017 OpGetGlobal 0
+0020 OpConstant 4         ; 2
+0023 OpGreaterThan
+0024 OpJumpNotTruthy 38   ; -> 0038
+0027 OpGetGlobal 1
+0030 OpGetGlobal 0
+0033 OpCall 1
+0035 OpJump 39            ; -> 0039
+0038 OpNull
+0039 OpPop
+
+== fn 3 (1 parameter, 1 local) ==
+; line 3: puts(a, "x")
+0000 OpGetBuiltin 1       ; puts
+0002 OpGetLocal 0
+0004 OpConstant 2         ; "x"
+0007 OpCall 2
+0009 OpReturnValue
+`,
+		},
+		{
+			"lines only",
+			token.NewFileSet(),
+			"",
+			`== main ==
+; line 1
+0000 OpConstant 0         ; 1
+`,
+		},
+		{
+			"no lines",
+			nil,
+			"",
+			`== main ==
+0000 OpConstant 0         ; 1
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if err := Disassemble(&out, compile(t, input, tt.fset), tt.source); err != nil {
+			t.Fatalf("%s - disassemble error: %s", tt.name, err)
+		}
+
+		// Only the start is compared when the expected text is short, the rest is the same as with the source
+		got := out.String()
+		if len(got) > len(tt.expected) && tt.name != "source" {
+			got = got[:len(tt.expected)]
+		}
+		if got != tt.expected {
+			t.Errorf("%s - wrong disassembly.\nwant=\n%s\ngot=\n%s", tt.name, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,698 @@
+package evaluator
+
+import (
+	"bytes"
+	"interpreter/lexer"
+	"interpreter/object"
+	"interpreter/parser"
+	"testing"
+)
+
+func TestEvalIntegerExpression(t *testing.T) {
+	// This is a test function for evaluating integer arithmetic
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"5", 5},
+		{"10", 10},
+		{"-5", -5},
+		{"-10", -10},
+		{"5 + 5 + 5 + 5 - 10", 10},
+		{"2 * 2 * 2 * 2 * 2", 32},
+		{"-50 + 100 + -50", 0},
+		{"5 * 2 + 10", 20},
+		{"5 + 2 * 10", 25},
+		{"20 + 2 * -10", 0},
+		{"50 / 2 * 2 + 10", 60},
+		{"2 * (5 + 10)", 30},
+		{"3 * 3 * 3 + 10", 37},
+		{"3 * (3 * 3) + 10", 37},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"10 % 3", 1},
+		{"-7 % 3", -1},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"3 ** 0", 1},
+		{"-2 ** 2", -4},
+	}
+
+	for i, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, i, evaluated, tt.expected)
+	}
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	// This is a test function for float arithmetic, including integers mixed with floats
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"1.5", 1.5},
+		{"-2.5", -2.5},
+		{"1.5 + 1.5", 3},
+		{"1 + 0.5", 1.5},
+		{"0.5 * 4", 2},
+		{"1 / 4.0", 0.25},
+		{"1.5e3 - 500", 1000},
+		{"0x10 * 0.5", 8},
+		{"7.5 % 2", 1.5},
+		{"2 ** -1", 0.5},
+		{"4 ** 0.5", 2},
+	}
+
+	for i, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		result, ok := evaluated.(*object.Float)
+		if !ok {
+			t.Errorf("tests[%d] - object is not Float. got=%T (%+v)", i, evaluated, evaluated)
+			continue
+		}
+		if result.Value != tt.expected {
+			t.Errorf("tests[%d] - object has wrong value. expected=%g, got=%g", i, tt.expected, result.Value)
+		}
+	}
+}
+
+func TestEvalBooleanExpression(t *testing.T) {
+	// This is a test function for evaluating comparisons and boolean literals
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true", true},
+		{"false", false},
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 < 1", false},
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"1 == 2", false},
+		{"1 != 2", true},
+		{"true == true", true},
+		{"false == false", true},
+		{"true == false", false},
+		{"true != false", true},
+		{"(1 < 2) == true", true},
+		{"(1 > 2) == true", false},
+		{"1.5 < 2", true},
+		{"2.0 == 2", true},
+		{"0.1 + 0.2 > 0.3", true},
+		{"1 <= 1", true},
+		{"2 <= 1", false},
+		{"1 >= 1", true},
+		{"1 >= 2", false},
+		{"1.5 >= 1", true},
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 && 0", true},
+		{"1 < 2 && 2 < 3", true},
+		{"false && undefined", false},
+		{"true || undefined", true},
+	}
+
+	for i, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, i, evaluated, tt.expected)
+	}
+}
+
+func TestBangOperator(t *testing.T) {
+	// This is a test function for the ! prefix operator and the truthiness rules
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"!true", false},
+		{"!false", true},
+		{"!5", false},
+		{"!!true", true},
+		{"!!false", false},
+		{"!!5", true},
+	}
+
+	for i, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, i, evaluated, tt.expected)
+	}
+}
+
+func TestIfElseExpressions(t *testing.T) {
+	// This is a test function for evaluating if expressions
+	// An expected value of nil means the expression evaluates to null
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"if (true) { 10 }", 10},
+		{"if (false) { 10 }", nil},
+		{"if (1) { 10 }", 10},
+		{"if (1 < 2) { 10 }", 10},
+		{"if (1 > 2) { 10 }", nil},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if (1 < 2) { 10 } else { 20 }", 10},
+	}
+
+	for i, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, i, evaluated, int64(integer))
+		} else {
+			testNullObject(t, i, evaluated)
+		}
+	}
+}
+
+func TestReturnStatements(t *testing.T) {
+	// This is a test function for return statements, including ones nested in blocks
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"return 10;", 10},
+		{"return 10; 9;", 10},
+		{"return 2 * 5; 9;", 10},
+		{"9; return 2 * 5; 9;", 10},
+		{`if (10 > 1) {
+			if (10 > 1) {
+				return 10;
+			}
+			return 1;
+		}`, 10},
+		{"let f = fn(x) { return x; x + 10; }; f(10);", 10},
+		{"let f = fn(x) { let result = x + 10; return result; return 10; }; f(10);", 20},
+	}
+
+	for i, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, i, evaluated, tt.expected)
+	}
+}
+
+func TestErrorHandling(t *testing.T) {
+	// This is a test function for the errors produced instead of values
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"5 + true;", "type mismatch: INTEGER + BOOLEAN"},
+		{"5 + true; 5;", "type mismatch: INTEGER + BOOLEAN"},
+		{"-true", "unknown operator: -BOOLEAN"},
+		{"true + false;", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"5; true + false; 5", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"if (10 > 1) { true + false; }", "unknown operator: BOOLEAN + BOOLEAN"},
+		{`if (10 > 1) {
+			if (10 > 1) {
+				return true + false;
+			}
+			return 1;
+		}`, "unknown operator: BOOLEAN + BOOLEAN"},
+		{"5 < 10 > 5", "type mismatch: BOOLEAN > INTEGER"},
+		{"foobar", "identifier not found: foobar"},
+		{`"Hello" - "World"`, "unknown operator: STRING - STRING"},
+		{`"Hello" + 1`, "type mismatch: STRING + INTEGER"},
+		{"10 / 0", "division by zero"},
+		{"1.5 / 0", "division by zero"},
+		{"5 % 0", "division by zero"},
+		{"true && undefined", "identifier not found: undefined"},
+		{`"a" <= "b"`, "unknown operator: STRING <= STRING"},
+		{"1.5 + true", "type mismatch: FLOAT + BOOLEAN"},
+		{"let x = 5; x(1)", "not a function: INTEGER"},
+		{"fn(x, y) { x + y }(1)", "wrong number of arguments: want=2, got=1"},
+		{`"a ${b} c"`, "identifier not found: b"},
+		{"[1, 2, 3][3]", "index out of range: 3 (length 3)"},
+		{"[1, 2, 3][-1]", "index out of range: -1 (length 3)"},
+		{`[1, 2, 3]["a"]`, "index operator not supported: ARRAY[STRING]"},
+		{"5[0]", "index operator not supported: INTEGER[INTEGER]"},
+		{`{"name": "Monkey"}[fn(x) { x }]`, "unusable as hash key: FUNCTION"},
+		{`{[1]: 2}`, "unusable as hash key: ARRAY"},
+		{`{1.5: 2}`, "unusable as hash key: FLOAT"},
+		{`[1, x, 3]`, "identifier not found: x"},
+		{`{"a": x}`, "identifier not found: x"},
+		{"let f = fn() { f() }; f()", "stack overflow"},
+		{"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(2000)", "stack overflow"},
+	}
+
+	for i, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("tests[%d] - no error object returned. got=%T(%+v)", i, evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("tests[%d] - wrong error message. expected=%q, got=%q",
+				i, tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestLetStatements(t *testing.T) {
+	// This is a test function for binding values with let statements
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = 5; a;", 5},
+		{"let a = 5 * 5; a;", 25},
+		{"let a = 5; let b = a; b;", 5},
+		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+		// A compound assignment binds the name like a let of the operation
+		{"let a = 5; a += 1; a;", 6},
+		{"let a = 5; a -= 2 * 2; a;", 1},
+		{"let a = 5; a *= a; a /= 5; a;", 5},
+		{"let i = 0; let sum = 0; while (i < 4) { i += 1; sum += i; } sum;", 10},
+	}
+
+	for i, tt := range tests {
+		testIntegerObject(t, i, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestFunctionObject(t *testing.T) {
+	// This is a test function for evaluating a function literal into a Function object
+	input := "fn(x) { x + 2; };"
+
+	evaluated := testEval(input)
+	fn, ok := evaluated.(*object.Function)
+	if !ok {
+		t.Fatalf("object is not *object.Function. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if len(fn.Parameters) != 1 {
+		t.Fatalf("function has wrong parameters. Parameters=%+v", fn.Parameters)
+	}
+
+	if fn.Parameters[0].String() != "x" {
+		t.Fatalf("parameter is not 'x'. got=%q", fn.Parameters[0])
+	}
+
+	expectedBody := "(x + 2)"
+
+	if fn.Body.String() != expectedBody {
+		t.Fatalf("body is not %q. got=%q", expectedBody, fn.Body.String())
+	}
+}
+
+func TestFunctionApplication(t *testing.T) {
+	// This is a test function for calling functions
+	// The first case is the program from TestNextToken in the lexer
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let add = fn(x, y) { x + y; }; add(5, 10)", 15},
+		{"let five = 5; let ten = 10; let add = fn(x, y) { x + y; }; let result = add(five, ten); result", 15},
+		{"let identity = fn(x) { x; }; identity(5);", 5},
+		{"let identity = fn(x) { return x; }; identity(5);", 5},
+		{"let double = fn(x) { x * 2; }; double(5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"fn(x) { x; }(5)", 5},
+		{"let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(5)", 120},
+		{"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(1000)", 1000},
+	}
+
+	for i, tt := range tests {
+		testIntegerObject(t, i, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestClosures(t *testing.T) {
+	// This is a test function for closures capturing the environment they were defined in
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`
+let newAdder = fn(x) {
+	fn(y) { x + y };
+};
+let addTwo = newAdder(2);
+addTwo(2);`, 4},
+		{`
+let x = 100;
+let f = fn() { x };
+let g = fn() { let x = 1; f() };
+g();`, 100},
+		{`
+let compose = fn(f, g) { fn(x) { f(g(x)) } };
+let inc = fn(x) { x + 1 };
+let double = fn(x) { x * 2 };
+compose(inc, double)(5);`, 11},
+	}
+
+	for i, tt := range tests {
+		testIntegerObject(t, i, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestStringExpressions(t *testing.T) {
+	// This is a test function for string literals, concatenation and comparison
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"Hello World!"`, "Hello World!"},
+		{`"Hello" + " " + "World!"`, "Hello World!"},
+		{`let greet = fn(name) { "Hello, " + name }; greet("größe")`, "Hello, größe"},
+		{`"a\tb"`, "a\tb"},
+		{`"abc" == "abc"`, true},
+		{`"abc" == "abd"`, false},
+		{`"abc" != "abd"`, true},
+		{`let name = "Monkey"; let age = 4; "Hello ${name}, you are ${age + 1}"`, "Hello Monkey, you are 5"},
+		{`"${1.5 * 2} ${true} ${"in ${1 < 2}"}"`, "3.0 true in true"},
+		{`let f = fn(x) { "<${x}>" }; "${f(f("a"))}" + "${"}"}"`, "<<a>>}"},
+		{`"\${x}"`, "${x}"},
+	}
+
+	for i, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("tests[%d] - object is not String. got=%T (%+v)", i, evaluated, evaluated)
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("tests[%d] - String has wrong value. expected=%q, got=%q", i, expected, str.Value)
+			}
+		case bool:
+			testBooleanObject(t, i, evaluated, expected)
+		}
+	}
+}
+
+func TestArrayLiterals(t *testing.T) {
+	// This is a test function for evaluating array literals
+	evaluated := testEval("[1, 2 * 2, 3 + 3]")
+
+	result, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if len(result.Elements) != 3 {
+		t.Fatalf("array has wrong number of elements. got=%d", len(result.Elements))
+	}
+
+	testIntegerObject(t, 0, result.Elements[0], 1)
+	testIntegerObject(t, 1, result.Elements[1], 4)
+	testIntegerObject(t, 2, result.Elements[2], 6)
+}
+
+func TestIndexExpressions(t *testing.T) {
+	// This is a test function for indexing arrays and hashes
+	// A key that is missing from a hash gives null
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][1 + 1]", 3},
+		{"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
+		{"let myArray = [1, 2, 3]; let i = myArray[0]; myArray[i]", 2},
+		{"[[1, 2], [3, 4]][1][0]", 3},
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`let key = "foo"; {"foo": 5}[key]`, 5},
+		{`{}["foo"]`, nil},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+		{`{false: 5}[false]`, 5},
+		{`{"a": 1, "a": 2}["a"]`, 2},
+	}
+
+	for i, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if expected, ok := tt.expected.(int); ok {
+			testIntegerObject(t, i, evaluated, int64(expected))
+		} else if evaluated != NULL {
+			t.Errorf("tests[%d] - object is not NULL. got=%T (%+v)", i, evaluated, evaluated)
+		}
+	}
+}
+
+func TestHashLiterals(t *testing.T) {
+	// This is a test function for evaluating hash literals
+	// Equal keys of different objects are the same key, and the pairs are printed in the order they were written
+	input := `let two = "two";
+{
+	"one": 10 - 9,
+	two: 1 + 1,
+	"thr" + "ee": 6 / 2,
+	4: 4,
+	true: 5,
+	false: 6
+}`
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Hash)
+	if !ok {
+		t.Fatalf("object is not Hash. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := map[object.HashKey]int64{
+		(&object.String{Value: "one"}).HashKey():   1,
+		(&object.String{Value: "two"}).HashKey():   2,
+		(&object.String{Value: "three"}).HashKey(): 3,
+		(&object.Integer{Value: 4}).HashKey():      4,
+		TRUE.HashKey():                             5,
+		FALSE.HashKey():                            6,
+	}
+
+	if len(result.Pairs) != len(expected) {
+		t.Fatalf("hash has wrong number of pairs. got=%d", len(result.Pairs))
+	}
+
+	for key, value := range expected {
+		pair, ok := result.Pairs[key]
+		if !ok {
+			t.Errorf("no pair for given key in Pairs")
+			continue
+		}
+		testIntegerObject(t, 0, pair.Value, value)
+	}
+
+	if result.Inspect() != "{one: 1, two: 2, three: 3, 4: 4, true: 5, false: 6}" {
+		t.Errorf("hash inspected wrong. got=%q", result.Inspect())
+	}
+}
+
+func TestLoops(t *testing.T) {
+	// This is a test function for while and for loops with break, continue and return
+	// A string is an expected error message
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 0; while (i < 10) { let i = i + 1; } i", 10},
+		{"let i = 0; while (false) { let i = i + 1; } i", 0},
+		{"let i = 0; while (true) { let i = i + 1; if (i == 5) { break; } } i", 5},
+		{"let i = 0; let sum = 0; while (i < 10) { let i = i + 1; if (i % 2 == 0) { continue; } let sum = sum + i; } sum", 25},
+		{"let sum = 0; for (x in [1, 2, 3, 4]) { let sum = sum + x; } sum", 10},
+		{"let sum = 0; for (x in [1, 2, 3, 4]) { if (x == 3) { break } let sum = sum + x; } sum", 3},
+		{`let n = 0; for (c in "größe") { if (c == "ö") { continue } let n = n + 1 } n`, 4},
+		{`let sum = 0; for (k in {1: "a", 2: "b", 3: "c"}) { let sum = sum + k } sum`, 6},
+		{"let sum = 0; for (x in [[1, 2], [3, 4]]) { for (y in x) { if (y == 2) { break } let sum = sum + y } } sum", 8},
+		{"let find = fn(xs, v) { let i = 0; for (x in xs) { if (x == v) { return i; } let i = i + 1; } -1 }; find([5, 6, 7], 7)", 2},
+		{"let find = fn(xs, v) { let i = 0; for (x in xs) { if (x == v) { return i; } let i = i + 1; } -1 }; find([5, 6, 7], 8)", -1},
+		{"let f = fn() { while (true) { while (true) { return 42; } } }; f() + 1", 43},
+		{"let f = fn() { let i = 0; while (i < 3) { let i = i + 1 } }; f()", nil},
+		{"let i = 0; while (i < 100000) { let i = i + 1; } i", 100000},
+		{"for (x in 5) { x }", "cannot iterate over INTEGER"},
+		{"while (y) { 1 }", "identifier not found: y"},
+		{"for (x in [1, 2]) { x + true }", "type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for i, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, i, evaluated, int64(expected))
+		case nil:
+			if evaluated != NULL {
+				t.Errorf("tests[%d] - object is not NULL. got=%T (%+v)", i, evaluated, evaluated)
+			}
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("tests[%d] - no error object returned. got=%T (%+v)", i, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("tests[%d] - wrong error message. expected=%q, got=%q", i, expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	// This is a test function for the built-in functions
+	// A string is an expected error message, unless it is the expected value of str or type
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len("größe")`, 5},
+		{`len([1, 2, 3])`, 3},
+		{`len({"a": 1})`, 1},
+		{`len(1)`, "argument to `len` not supported, got INTEGER"},
+		{`len("one", "two")`, "wrong number of arguments to `len`: want=1, got=2"},
+		{`first([1, 2, 3])`, 1},
+		{`first([])`, nil},
+		{`first(1)`, "argument to `first` must be ARRAY, got INTEGER"},
+		{`last([1, 2, 3])`, 3},
+		{`last([])`, nil},
+		{`last()`, "wrong number of arguments to `last`: want=1, got=0"},
+		{`rest([1, 2, 3])`, []int64{2, 3}},
+		{`rest([1])`, []int64{}},
+		{`rest([])`, nil},
+		{`let a = [1, 2]; rest(a); a`, []int64{1, 2}},
+		{`push([], 1)`, []int64{1}},
+		{`let a = [1]; push(a, 2); a`, []int64{1}},
+		{`push(1, 1)`, "argument to `push` must be ARRAY, got INTEGER"},
+		{`push([1])`, "wrong number of arguments to `push`: want=2, got=1"},
+		{`type(1)`, "INTEGER"},
+		{`type("a" + "b")`, "STRING"},
+		{`type(len)`, "BUILTIN"},
+		{`type(fn() {})`, "FUNCTION"},
+		{`str(1.0)`, "1.0"},
+		{`str([1, "a", true])`, "[1, a, true]"},
+		{`str("a")`, "a"},
+		{`int(42)`, 42},
+		{`int(-2.5)`, -2},
+		{`int(-9.2e18)`, -9200000000000000000},
+		{`int(1e30)`, "could not convert 1e+30 to integer"},
+		{`int(-1e30)`, "could not convert -1e+30 to integer"},
+		{`int(9223372036854775807.0)`, "could not convert 9.223372036854776e+18 to integer"},
+		{`int(1e308 * 10)`, "could not convert +Inf to integer"},
+		{`int(1e308 * 10 - 1e308 * 10)`, "could not convert NaN to integer"},
+		{`int(true)`, 1},
+		{`int(" 010 ")`, 10},
+		{`int("0xFF")`, 255},
+		{`int("1_000")`, 1000},
+		{`int("0_10")`, 10},
+		{`int("-0b11")`, -3},
+		{`int("1__0")`, `could not parse "1__0" as integer`},
+		{`int("abc")`, `could not parse "abc" as integer`},
+		{`int([])`, "argument to `int` not supported, got ARRAY"},
+		{`let len = fn(x) { 42 }; len("a")`, 42},
+		{`let map = fn(arr, f) { if (len(arr) == 0) { [] } else { push(map(rest(arr), f), f(first(arr))) } }; map([1, 2, 3], fn(x) { x * 2 })`, []int64{6, 4, 2}},
+	}
+
+	for i, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, i, evaluated, int64(expected))
+		case nil:
+			if evaluated != NULL {
+				t.Errorf("tests[%d] - object is not NULL. got=%T (%+v)", i, evaluated, evaluated)
+			}
+		case string:
+			switch result := evaluated.(type) {
+			case *object.Error:
+				if result.Message != expected {
+					t.Errorf("tests[%d] - wrong error message. expected=%q, got=%q", i, expected, result.Message)
+				}
+			case *object.String:
+				if result.Value != expected {
+					t.Errorf("tests[%d] - String has wrong value. expected=%q, got=%q", i, expected, result.Value)
+				}
+			default:
+				t.Errorf("tests[%d] - object is not Error or String. got=%T (%+v)", i, evaluated, evaluated)
+			}
+		case []int64:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("tests[%d] - object is not Array. got=%T (%+v)", i, evaluated, evaluated)
+				continue
+			}
+			if len(array.Elements) != len(expected) {
+				t.Errorf("tests[%d] - wrong number of elements. expected=%d, got=%d", i, len(expected), len(array.Elements))
+				continue
+			}
+			for j, el := range expected {
+				testIntegerObject(t, i, array.Elements[j], el)
+			}
+		}
+	}
+}
+
+func TestPuts(t *testing.T) {
+	// This is a test function for the output of puts
+	// It goes to the output of the environment, also when puts is called inside a function
+	var out bytes.Buffer
+
+	l := lexer.New(`let say = fn(x) { puts(x, "${x}!") }; say(1); puts([1, "a"], {"b": 2.0})`)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	env := object.NewEnvironment()
+	env.SetOutput(&out)
+
+	evaluated := Eval(program, env)
+
+	if out.String() != "1\n1!\n[1, a]\n{b: 2.0}\n" {
+		t.Errorf("output wrong. got=%q", out.String())
+	}
+	if evaluated != NULL {
+		t.Errorf("puts did not return NULL. got=%T (%+v)", evaluated, evaluated)
+	}
+}
+
+func testEval(input string) object.Object {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	env := object.NewEnvironment()
+
+	return Eval(program, env)
+}
+
+func testIntegerObject(t *testing.T, i int, obj object.Object, expected int64) {
+	t.Helper()
+
+	result, ok := obj.(*object.Integer)
+	if !ok {
+		t.Errorf("tests[%d] - object is not Integer. got=%T (%+v)", i, obj, obj)
+		return
+	}
+	if result.Value != expected {
+		t.Errorf("tests[%d] - object has wrong value. expected=%d, got=%d", i, expected, result.Value)
+	}
+}
+
+func testBooleanObject(t *testing.T, i int, obj object.Object, expected bool) {
+	t.Helper()
+
+	result, ok := obj.(*object.Boolean)
+	if !ok {
+		t.Errorf("tests[%d] - object is not Boolean. got=%T (%+v)", i, obj, obj)
+		return
+	}
+	if result.Value != expected {
+		t.Errorf("tests[%d] - object has wrong value. expected=%t, got=%t", i, expected, result.Value)
+	}
+}
+
+func testNullObject(t *testing.T, i int, obj object.Object) {
+	t.Helper()
+
+	if obj != NULL {
+		t.Errorf("tests[%d] - object is not NULL. got=%T (%+v)", i, obj, obj)
+	}
+}
@@ -0,0 +1,55 @@
+package lexer
+
+import (
+	"fmt"
+	"interpreter/token"
+)
+
+// ErrorCode tells what kind of lexical error occurred
+type ErrorCode int
+
+const (
+	UnexpectedCharacter ErrorCode = iota + 1
+	UnterminatedString
+	InvalidEscape
+	BadNumber
+	UnterminatedComment
+)
+
+var errorCodeNames = map[ErrorCode]string{
+	UnexpectedCharacter: "unexpected character",
+	UnterminatedString:  "unterminated string",
+	InvalidEscape:       "invalid escape",
+	BadNumber:           "bad number",
+	UnterminatedComment: "unterminated comment",
+}
+
+func (c ErrorCode) String() string {
+	if name, ok := errorCodeNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("ErrorCode(%d)", int(c))
+}
+
+// Error describes why the lexer returned an ILLEGAL token
+// For example, for the input "let x = @;" the error is at 1:9 with the code UnexpectedCharacter
+// The position is already resolved, so it includes the name of the file
+type Error struct {
+	Pos  token.Position
+	Code ErrorCode
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+// Errors returns the errors found in the input read so far, in the order they occurred
+func (l *Lexer) Errors() []*Error {
+	return l.errors
+}
+
+func (l *Lexer) error(pos token.Pos, code ErrorCode, format string, a ...interface{}) {
+	err := &Error{Pos: l.file.Position(pos), Code: code, Msg: fmt.Sprintf(format, a...)}
+	l.errors = append(l.errors, err)
+}
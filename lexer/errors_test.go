@@ -0,0 +1,120 @@
+package lexer
+
+import (
+	"interpreter/token"
+	"testing"
+)
+
+func TestErrors(t *testing.T) {
+	// This is a test function for the errors explaining the ILLEGAL tokens
+	// It checks the position, the code and the message of every error the lexer reports
+	type expectedError struct {
+		pos  token.Position
+		code ErrorCode
+		msg  string
+	}
+
+	tests := []struct {
+		input    string
+		expected []expectedError
+	}{
+		{"let x = 5;", nil},
+		{"let x = @;", []expectedError{
+			{token.Position{Offset: 8, Line: 1, Column: 9}, UnexpectedCharacter, `unexpected character "@"`},
+		}},
+		{"a @#$? b", []expectedError{
+			{token.Position{Offset: 2, Line: 1, Column: 3}, UnexpectedCharacter, `unexpected character "@#$?"`},
+		}},
+		{"a\n  \xff\xfe€ & b", []expectedError{
+			{token.Position{Offset: 4, Line: 2, Column: 3}, UnexpectedCharacter, `unexpected character "\xff\xfe€"`},
+			{token.Position{Offset: 10, Line: 2, Column: 9}, UnexpectedCharacter, `unexpected character "&"`},
+		}},
+		{`x = "abc`, []expectedError{
+			{token.Position{Offset: 4, Line: 1, Column: 5}, UnterminatedString, "string literal not terminated"},
+		}},
+		{"`abc", []expectedError{
+			{token.Position{Offset: 0, Line: 1, Column: 1}, UnterminatedString, "raw string literal not terminated"},
+		}},
+		{`"a\qb\zc"`, []expectedError{
+			{token.Position{Offset: 2, Line: 1, Column: 3}, InvalidEscape, "unknown escape sequence"},
+			{token.Position{Offset: 5, Line: 1, Column: 6}, InvalidEscape, "unknown escape sequence"},
+		}},
+		{"1 + 0x;\n1e", []expectedError{
+			{token.Position{Offset: 4, Line: 1, Column: 5}, BadNumber, `malformed number "0x"`},
+			{token.Position{Offset: 8, Line: 2, Column: 1}, BadNumber, `malformed number "1e"`},
+		}},
+		{`x = "a ${ "b ${c`, []expectedError{
+			{token.Position{Offset: 4, Line: 1, Column: 5}, UnterminatedString, "string literal not terminated"},
+		}},
+		{`"a ${b} c`, []expectedError{
+			{token.Position{Offset: 0, Line: 1, Column: 1}, UnterminatedString, "string literal not terminated"},
+		}},
+		{"1 /* never /* closed */", []expectedError{
+			{token.Position{Offset: 2, Line: 1, Column: 3}, UnterminatedComment, "comment not terminated"},
+		}},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+		for range l.All() {
+		}
+
+		errors := l.Errors()
+		if len(errors) != len(tt.expected) {
+			t.Fatalf("tests[%d] - wrong number of errors. expected=%d, got=%d (%v)",
+				i, len(tt.expected), len(errors), errors)
+		}
+
+		for j, e := range tt.expected {
+			err := errors[j]
+			if err.Pos != e.pos {
+				t.Errorf("tests[%d][%d] - pos wrong. expected=%+v, got=%+v", i, j, e.pos, err.Pos)
+			}
+			if err.Code != e.code {
+				t.Errorf("tests[%d][%d] - code wrong. expected=%s, got=%s", i, j, e.code, err.Code)
+			}
+			if err.Msg != e.msg {
+				t.Errorf("tests[%d][%d] - msg wrong. expected=%q, got=%q", i, j, e.msg, err.Msg)
+			}
+		}
+	}
+}
+
+func TestErrorGroupsInvalidBytes(t *testing.T) {
+	// This is a test function for the recovery after unexpected characters
+	// The whole run is a single ILLEGAL token, and lexing goes on normally right after it
+	input := "let x = 5 @@@@ + 1;"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "5"},
+		{token.ILLEGAL, "@@@@"},
+		{token.PLUS, "+"},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+
+	if len(l.Errors()) != 1 {
+		t.Errorf("wrong number of errors. expected=1, got=%d", len(l.Errors()))
+	}
+}
@@ -1,111 +1,456 @@
 package lexer
 
-import "interpreter/token"
+import (
+	"bytes"
+	"interpreter/token"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
 
 type Lexer struct {
 	// The position is used when we want to check identifiers or numbers
-	input        string
+	// The positions are byte offsets from the beginning of the input
+	// When reading from an io.Reader, input only holds a window of it, starting at the offset base
+	input        []byte
+	base         int
 	position     int
 	readPosition int
-	ch           byte
+	ch           rune
+
+	// The reader the input comes from, nil when the whole input was given as a string or was read to its end
+	// Only the bytes from start, where the current token begins, are kept in the window
+	reader io.Reader
+	start  int
+	err    error
+
+	// The whole input when it was given as a string, so the literals are sliced out of it without copying them
+	text string
+
+	// The source file the input is registered as, the lexer records where its lines start
+	// It is used to attach a position to every token
+	file *token.File
+
+	// Whether comments are returned as COMMENT tokens instead of being skipped
+	emitComments bool
+
+	// The interpolated strings being read, one for every "${" whose "}" was not read yet, the innermost last
+	// After a string part the next token is the "${" that ends it,
+	// and after the "}" that closes the embedded expression the string is read on
+	interpolations     []interpolation
+	afterPart          bool
+	afterInterpolation bool
+
+	// Whether semicolons are inserted at the ends of lines, and whether the last token allows one
+	autoSemicolons bool
+	insertSemi     bool
+
+	// The errors that explain the ILLEGAL tokens returned so far
+	errors []*Error
+}
+
+// interpolation is an expression embedded in a string, e.g. the "${name}" in "Hello ${name}!"
+type interpolation struct {
+	// The opening quote of the string, where an unterminated string is reported
+	quote token.Pos
+	// The number of "{" in the expression that are not closed yet, so the "}" of "${fn() { 1 }()}" are told apart
+	depth int
+}
+
+// Option configures a Lexer created by New
+type Option func(*Lexer)
+
+// WithComments makes the Lexer return comments as COMMENT tokens
+// A formatter or a documentation tool can use them to keep the comments of a program
+func WithComments() Option {
+	return func(l *Lexer) {
+		l.emitComments = true
+	}
+}
+
+// WithAutoSemicolons makes the Lexer insert a SEMICOLON at a newline, or at the end of the input,
+// when the last token of the line can end a statement, the way Go does
+// Those tokens are an identifier, a literal, true, false, return, break, continue, ")", "}" or "]"
+// For example, "let x = 5\nx" is lexed like "let x = 5;\nx;"
+// An inserted semicolon has the literal "\n" and no width, so it can be told apart from a ";" in the input
+func WithAutoSemicolons() Option {
+	return func(l *Lexer) {
+		l.autoSemicolons = true
+	}
+}
+
+// WithFile registers the input as a source file with the given name in the FileSet
+// The positions of the tokens can then be resolved by the FileSet, along with those of the other files of a program
+// Without this option, the input is registered as an unnamed file in a FileSet of its own
+func WithFile(fset *token.FileSet, filename string) Option {
+	return func(l *Lexer) {
+		l.file = fset.AddFile(filename)
+	}
+}
+
+func (l *Lexer) init(opts []Option) {
+	for _, opt := range opts {
+		opt(l)
+	}
+	if l.file == nil {
+		l.file = token.NewFileSet().AddFile("")
+	}
+	l.readChar()
+}
+
+// File returns the source file the input is registered as
+// For example, l.File().Position(tok.Pos) gives the line and column of a token
+func (l *Lexer) File() *token.File {
+	return l.file
 }
 
-func New(input string) *Lexer {
+func New(input string, opts ...Option) *Lexer {
 	// Create a new Lexer instance with the input string
 	// and initialize the position and readPosition to 0
 	// For example, if the input is "let x = 5;", set the position and readPosition to 0
 	// and read the first character
-	l := &Lexer{input: input}
-	l.readChar()
+	l := &Lexer{input: []byte(input), text: input}
+	l.init(opts)
 	return l
 }
 
+// bufferSize is the size of the window of a Lexer created by NewReader, until a token longer than it is read
+const bufferSize = 4096
+
+// maxEmptyReads is how many reads in a row may return no data and no error before the reader is given up on,
+// like in bufio, as such a reader would otherwise keep the lexer waiting forever
+const maxEmptyReads = 100
+
+// NewReader creates a Lexer reading its input from r
+// The input is read in chunks as the tokens are needed, so it never has to be in memory as a whole
+// The tokens are the same as the ones New returns for the same input
+func NewReader(r io.Reader, opts ...Option) *Lexer {
+	l := &Lexer{reader: r, input: make([]byte, 0, bufferSize)}
+	l.init(opts)
+	return l
+}
+
+// Err returns the first error, other than io.EOF, that occurred reading the input of NewReader
+// The lexer treats such an error like the end of the input
+func (l *Lexer) Err() error {
+	return l.err
+}
+
+func (l *Lexer) fill(n int) {
+	// Make sure the window holds at least n bytes after the readPosition, unless the input ends before
+	// The bytes before the start of the current token are dropped, which keeps the window small
+	// For example, for a 1GB input made of short tokens the window never grows much beyond bufferSize
+	if l.reader == nil {
+		return
+	}
+
+	empty := 0
+	for l.readPosition-l.base+n > len(l.input) {
+		if l.err != nil {
+			return
+		}
+
+		if len(l.input) == cap(l.input) {
+			l.makeRoom()
+		}
+
+		read, err := l.reader.Read(l.input[len(l.input):cap(l.input)])
+		l.input = l.input[:len(l.input)+read]
+		if read > 0 {
+			empty = 0
+		}
+
+		if err == io.EOF {
+			l.reader = nil
+			return
+		} else if err != nil {
+			l.err = err
+			return
+		}
+
+		if read == 0 {
+			empty++
+			if empty == maxEmptyReads {
+				l.err = io.ErrNoProgress
+				return
+			}
+		}
+	}
+}
+
+func (l *Lexer) makeRoom() {
+	// Make room at the end of the full window, by dropping the bytes before the current token or by growing it
+	// The bytes are only moved when at least half of them are dropped, and the window doubles otherwise,
+	// so a token of any length is read in a time proportional to its length
+	if drop := l.start - l.base; drop > 0 && drop >= len(l.input)/2 {
+		kept := copy(l.input, l.input[drop:])
+		l.input = l.input[:kept]
+		l.base = l.start
+		return
+	}
+
+	grown := make([]byte, len(l.input), 2*cap(l.input))
+	copy(grown, l.input)
+	l.input = grown
+}
+
+func (l *Lexer) slice(from, to int) string {
+	// Return the input between the two offsets, which must still be in the window
+	if l.text != "" {
+		return l.text[from:to]
+	}
+	return string(l.input[from-l.base : to-l.base])
+}
+
+func (l *Lexer) startToken() {
+	// Mark the current character as the start of a token, the window must keep everything from here
+	l.start = l.position
+}
+
+type operator struct {
+	literal   string
+	tokenType token.TokenType
+}
+
+// operatorTable lists every operator and delimiter the lexer knows
+// Adding an operator, however many characters long, only needs a new entry here
+var operatorTable = []operator{
+	{"=", token.ASSIGN},
+	{"+", token.PLUS},
+	{"-", token.MINUS},
+	{"!", token.BANG},
+	{"*", token.ASTERISK},
+	{"/", token.SLASH},
+	{"%", token.PERCENT},
+	{"**", token.POWER},
+	{"==", token.EQ},
+	{"!=", token.NOT_EQ},
+	{"<", token.LT},
+	{">", token.GT},
+	{"<=", token.LT_EQ},
+	{">=", token.GT_EQ},
+	{"&&", token.AND},
+	{"||", token.OR},
+	{"+=", token.PLUS_ASSIGN},
+	{"-=", token.MINUS_ASSIGN},
+	{"*=", token.ASTERISK_ASSIGN},
+	{"/=", token.SLASH_ASSIGN},
+	{",", token.COMMA},
+	{";", token.SEMICOLON},
+	{":", token.COLON},
+	{"(", token.LPAREN},
+	{")", token.RPAREN},
+	{"{", token.LBRACE},
+	{"}", token.RBRACE},
+	{"[", token.LBRACKET},
+	{"]", token.RBRACKET},
+}
+
+// operators groups the operatorTable by the first character, the longest operators first
+// For example, operators['*'] is "**", "*=" and then "*"
+// Every operator is ASCII, so an array indexed by the first byte is enough
+var operators [utf8.RuneSelf][]operator
+
+func init() {
+	for _, op := range operatorTable {
+		first := op.literal[0]
+		operators[first] = append(operators[first], op)
+	}
+	for _, ops := range operators {
+		sort.SliceStable(ops, func(i, j int) bool {
+			return len(ops[i].literal) > len(ops[j].literal)
+		})
+	}
+}
+
 func (l *Lexer) readChar() {
 	// Read the next character from the input string
 	// and update the position and readPosition
 	// For example, if the input is "let x = 5;", read the characters one by one
 	// and update the position and readPosition accordingly
-	if l.readPosition >= len(l.input) {
+	// The input is UTF-8, so a character may take more than one byte
+	// For example, in "größe" the "ö" takes two bytes and readPosition advances by two
+	if l.ch == '\n' {
+		// The character we are leaving is a newline, so the next one starts a new line
+		l.file.AddLine(l.readPosition)
+	}
+
+	l.fill(utf8.UTFMax)
+
+	if l.readPosition-l.base >= len(l.input) {
 		// EOF (end of file) reached
+		// Stay on the offset just past the last character, so every EOF token gets the same position
 		l.ch = 0
-	} else {
-		l.ch = l.input[l.readPosition]
+		l.position = l.readPosition
+		return
 	}
+
+	r, width := utf8.DecodeRune(l.input[l.readPosition-l.base:])
+	l.ch = r
 	l.position = l.readPosition
-	l.readPosition += 1
+	l.readPosition += width
+}
+
+func (l *Lexer) currentPosition() token.Pos {
+	// Return the position of the current character
+	// For example, in "let x\n= 5;" the position of "=" resolves to line 2, column 1
+	return l.file.Pos(l.position)
 }
 
-func (l *Lexer) peekChar() byte {
+func (l *Lexer) peekChar() rune {
 	// Peek the next character without advancing the read position
 	// For example, if the input is "let x = 5;", peek the next character after reading "let"
 	// and return the character ' ' (space) without advancing the read position
-	if l.readPosition >= len(l.input) {
+	l.fill(utf8.UTFMax)
+
+	if l.readPosition-l.base >= len(l.input) {
 		// EOF (end of file) reached
 		return 0
 	}
-	return l.input[l.readPosition]
+	r, _ := utf8.DecodeRune(l.input[l.readPosition-l.base:])
+	return r
 }
 
 func (l *Lexer) NextToken() token.Token {
+	// Read the next token and remember whether a newline after it ends the statement
+	// A comment doesn't change that, so "x // note\n" still gets a semicolon after the x
+	// No semicolon is inserted inside an expression embedded in a string
+	tok := l.nextToken()
+	if l.autoSemicolons && tok.Type != token.COMMENT {
+		l.insertSemi = endsStatement(tok) && len(l.interpolations) == 0
+	}
+	return tok
+}
+
+func endsStatement(tok token.Token) bool {
+	// Report whether a semicolon is inserted at a newline after the token
+	// An inserted semicolon itself never asks for another one
+	switch tok.Type {
+	case token.RETURN, token.BREAK, token.CONTINUE, token.TRUE, token.FALSE, token.RPAREN, token.RBRACE, token.RBRACKET:
+		return true
+	case token.SEMICOLON:
+		return false
+	}
+	return tok.Type.IsLiteral()
+}
+
+func (l *Lexer) insertedSemicolon() token.Token {
+	// Return a semicolon inserted at the current character, which is a newline, the end of the input or a comment
+	// The character is left for the next token
+	pos := l.currentPosition()
+	return token.Token{Type: token.SEMICOLON, Literal: "\n", Pos: pos, End: pos}
+}
+
+func (l *Lexer) rewind(offset int) {
+	// Go back to the character at the offset, which must still be in the window
+	// Clearing the current character keeps readChar from recording a line twice
+	l.ch = 0
+	l.readPosition = offset
+	l.readChar()
+}
+
+func (l *Lexer) nextToken() token.Token {
 	// Read the next token from the input string
 	// For example, if the input is "let x = 5;", return the tokens for "let", "x", "=", "5", ";"
 	// In this case, the tokens would be: LET, IDENT, ASSIGN, INT, SEMICOLON
-	// The tokens are created using the newToken function
-	// and the token type is determined based on the character read
+	// Operators and delimiters are looked up in the operator table,
+	// the other token types are determined based on the character read
 	var tok token.Token
 
+	// An interpolated string is split into string parts and the tokens of the embedded expressions
+	// For example, "a ${x} b" is lexed as STRING_PART "a ", INTERP_START, IDENT x, INTERP_END and STRING " b"
+	if l.afterPart {
+		l.afterPart = false
+		l.startToken()
+		pos := l.currentPosition()
+		l.readChar()
+		l.readChar()
+		return token.Token{Type: token.INTERP_START, Literal: "${", Pos: pos, End: l.currentPosition()}
+	}
+	if l.afterInterpolation {
+		l.afterInterpolation = false
+		l.startToken()
+		pos := l.currentPosition()
+		quote := l.interpolations[len(l.interpolations)-1].quote
+		l.interpolations = l.interpolations[:len(l.interpolations)-1]
+		tok.Type, tok.Literal = l.readString(l.position, quote)
+		tok.Pos, tok.End = pos, l.currentPosition()
+		return tok
+	}
+
 	l.skipWhitespace()
 
+	// Comments are skipped like whitespace, unless the lexer was asked to keep them
+	// An unterminated block comment is always returned, as an ILLEGAL token
+	// When a semicolon is due, a newline or the end of the input inserts it before anything else
+	for {
+		if l.insertSemi && (l.ch == '\n' || l.ch == 0) {
+			return l.insertedSemicolon()
+		}
+		if l.ch != '/' || l.peekChar() != '/' && l.peekChar() != '*' {
+			break
+		}
+
+		l.startToken()
+		pos := l.currentPosition()
+		position := l.position
+		tok.Type, tok.Literal = l.readComment()
+
+		// A comment that runs to the end of a line, or spans lines, counts as a newline
+		// The semicolon is inserted where the comment starts, and the comment is read again by the next call
+		// For example, "x // note" gives x, the inserted semicolon and then the comment
+		if l.insertSemi && (tok.Literal[1] == '/' || l.ch == 0 || strings.Contains(tok.Literal, "\n")) {
+			l.rewind(position)
+			return l.insertedSemicolon()
+		}
+
+		if tok.Type == token.ILLEGAL {
+			l.error(pos, UnterminatedComment, "comment not terminated")
+		}
+		if l.emitComments || tok.Type == token.ILLEGAL {
+			tok.Pos, tok.End = pos, l.currentPosition()
+			return tok
+		}
+		l.skipWhitespace()
+	}
+
+	// Remember where the token starts, the end position is set once it is read
+	l.startToken()
+	pos := l.currentPosition()
+
 	switch l.ch {
-	case '=':
-		// Check if the next character is also '=' for the EQ token
-		if l.peekChar() == '=' {
-			// Keep the current character to a variable, so we can use the character even after advancing to the next character
-			ch := l.ch
-			l.readChar()
-			tok = token.Token{Type: token.EQ, Literal: string(ch) + string(l.ch)}
-		} else {
-			tok = newToken(token.ASSIGN, l.ch)
-		}
-	case '+':
-		tok = newToken(token.PLUS, l.ch)
-	case '-':
-		tok = newToken(token.MINUS, l.ch)
-	case '!':
-		// Check if the next character is also '=' for the NOT_EQ token
-		if l.peekChar() == '=' {
-			// Keep the current character to a variable, so we can use the character even after advancing to the next character
-			ch := l.ch
-			l.readChar()
-			tok = token.Token{Type: token.NOT_EQ, Literal: string(ch) + string(l.ch)}
-		} else {
-			tok = newToken(token.BANG, l.ch)
-		}
-	case '/':
-		tok = newToken(token.SLASH, l.ch)
-	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
-	case '<':
-		tok = newToken(token.LT, l.ch)
-	case '>':
-		tok = newToken(token.GT, l.ch)
-	case ';':
-		tok = newToken(token.SEMICOLON, l.ch)
-	case '(':
-		tok = newToken(token.LPAREN, l.ch)
-	case ')':
-		tok = newToken(token.RPAREN, l.ch)
-	case ',':
-		tok = newToken(token.COMMA, l.ch)
-	case '{':
-		tok = newToken(token.LBRACE, l.ch)
-	case '}':
-		tok = newToken(token.RBRACE, l.ch)
+	case '"':
+		// Read the string and set the token type to STRING
+		// The escape sequences are resolved, so the literal of "a\tb" is a, a tab and b
+		position := l.position
+		l.readChar()
+		tok.Type, tok.Literal = l.readString(position, pos)
+		tok.Pos, tok.End = pos, l.currentPosition()
+		return tok
+	case '`':
+		// Read the raw string and set the token type to STRING
+		// Nothing is escaped in a raw string, and it may span several lines
+		tok.Type, tok.Literal = l.readRawString()
+		tok.Pos, tok.End = pos, l.currentPosition()
+		return tok
 	case 0:
+		// The input ended inside an expression embedded in a string, the outermost string is not terminated
+		if len(l.interpolations) > 0 {
+			l.error(l.interpolations[0].quote, UnterminatedString, "string literal not terminated")
+			l.interpolations = nil
+		}
 		tok.Literal = ""
 		tok.Type = token.EOF
 	default:
-		if isLetter(l.ch) {
+		if op, ok := l.readOperator(); ok {
+			tok = op
+			if len(l.interpolations) > 0 {
+				l.matchBrace(&tok)
+			}
+		} else if isLetter(l.ch) {
 			// Read the identifier
 			tok.Literal = l.readIdentifier()
 
@@ -113,28 +458,82 @@ func (l *Lexer) NextToken() token.Token {
 			// and set the token type accordingly
 			// For example, if the identifier is "fn", set the token type to FUNCTION
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Pos, tok.End = pos, l.currentPosition()
 			return tok
 		} else if isDigit(l.ch) {
-			// Read the number and set the token type to INT
-			// For example, if the number is "123", set the token type to INT
-			tok.Literal = l.readNumber()
-			tok.Type = token.INT
+			// Read the number and set the token type to INT or FLOAT
+			// For example, if the number is "123", set the token type to INT, and if it is "1.5e-3", set it to FLOAT
+			tok.Type, tok.Literal = l.readNumber()
+			if tok.Type == token.ILLEGAL {
+				l.error(pos, BadNumber, "malformed number %q", tok.Literal)
+			}
+			tok.Pos, tok.End = pos, l.currentPosition()
 			return tok
 		} else {
-			tok = newToken(token.ILLEGAL, l.ch)
+			// A run of unexpected characters is returned as a single ILLEGAL token with a single error
+			// The raw bytes are used as the literal, so invalid UTF-8 is reported as it appears in the input
+			tok = token.Token{Type: token.ILLEGAL, Literal: l.readUnexpected()}
+			l.error(pos, UnexpectedCharacter, "unexpected character %q", tok.Literal)
+			tok.Pos, tok.End = pos, l.currentPosition()
+			return tok
 		}
 	}
 
 	// Read the next character to advance the position
 	// For example, if the input is "let x = 5;", after reading "let", advance to the next character ' '
 	l.readChar()
+	tok.Pos, tok.End = pos, l.currentPosition()
 	return tok
 }
 
-func newToken(tokenType token.TokenType, ch byte) token.Token {
-	// Create a new token with the given type and literal value
-	// For example, if the token type is ASSIGN and the character is '=', create a token with type ASSIGN and literal '='
-	return token.Token{Type: tokenType, Literal: string(ch)}
+func (l *Lexer) readOperator() (token.Token, bool) {
+	// Look up the operator or delimiter starting at the current character
+	// The candidates are tried longest first, so for "**" the POWER token is returned, not two ASTERISK tokens
+	// The current character is left on the last character of the operator
+	if l.ch >= utf8.RuneSelf {
+		return token.Token{}, false
+	}
+
+	l.fill(utf8.UTFMax)
+
+	for _, op := range operators[l.ch] {
+		if bytes.HasPrefix(l.input[l.position-l.base:], []byte(op.literal)) {
+			for i := 1; i < len(op.literal); i++ {
+				l.readChar()
+			}
+			return token.Token{Type: op.tokenType, Literal: op.literal}, true
+		}
+	}
+	return token.Token{}, false
+}
+
+func (l *Lexer) matchBrace(tok *token.Token) {
+	// Keep track of the braces of an expression embedded in a string
+	// The "}" that matches the "${" is an INTERP_END, after which the string is read on
+	// For example, in "${ {"a": 1}["a"] }" the first "}" closes the hash and the second one the interpolation
+	in := &l.interpolations[len(l.interpolations)-1]
+	switch {
+	case tok.Type == token.LBRACE:
+		in.depth += 1
+	case tok.Type == token.RBRACE && in.depth > 0:
+		in.depth -= 1
+	case tok.Type == token.RBRACE:
+		tok.Type = token.INTERP_END
+		l.afterInterpolation = true
+	}
+}
+
+func (l *Lexer) readUnexpected() string {
+	// Read the run of characters starting at the current one that can't start any token
+	// For example, for the input "@#$ x" return "@#$" and stop on the space
+	position := l.position
+	for {
+		l.readChar()
+		if l.ch == 0 || l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' ||
+			l.ch == '"' || l.ch == '`' || isLetter(l.ch) || isDigit(l.ch) || l.ch < utf8.RuneSelf && len(operators[l.ch]) > 0 {
+			return l.slice(position, l.position)
+		}
+	}
 }
 
 func (l *Lexer) readIdentifier() string {
@@ -144,33 +543,263 @@ func (l *Lexer) readIdentifier() string {
 	for isLetter(l.ch) {
 		l.readChar()
 	}
-	return l.input[position:l.position]
+	return l.slice(position, l.position)
 }
 
-func (l *Lexer) readNumber() string {
+func (l *Lexer) readNumber() (token.TokenType, string) {
 	// Read the number from the input string
 	// For example, if the number is "123", return "123"
+	// Integers may have a 0x, 0o or 0b prefix, and floats a fraction and an exponent
+	// The digits may be separated by underscores, e.g. "1_000_000"
+	// A malformed number, like "0x", "1e" or "0b102", is returned as a single ILLEGAL token
 	position := l.position
-	for isDigit(l.ch) {
+	var tokenType token.TokenType = token.INT
+	valid := true
+
+	if l.ch == '0' && isBasePrefix(l.peekChar()) {
+		var isValidDigit func(rune) bool
+		switch l.peekChar() {
+		case 'x', 'X':
+			isValidDigit = isHexDigit
+		case 'o', 'O':
+			isValidDigit = isOctalDigit
+		default:
+			isValidDigit = isBinaryDigit
+		}
+
+		// Skip the prefix
 		l.readChar()
+		l.readChar()
+		valid = l.readDigits(isValidDigit)
+	} else {
+		valid = l.readDigits(isDigit)
+
+		if l.ch == '.' {
+			tokenType = token.FLOAT
+			l.readChar()
+			valid = l.readDigits(isDigit) && valid
+		}
+
+		if l.ch == 'e' || l.ch == 'E' {
+			tokenType = token.FLOAT
+			l.readChar()
+			if l.ch == '+' || l.ch == '-' {
+				l.readChar()
+			}
+			valid = l.readDigits(isDigit) && valid
+		}
+	}
+
+	// A number must not run into an identifier, e.g. "12ab" or the "2" in "0b102"
+	for isLetter(l.ch) || isDigit(l.ch) {
+		valid = false
+		l.readChar()
+	}
+
+	if !valid {
+		return token.ILLEGAL, l.slice(position, l.position)
 	}
-	return l.input[position:l.position]
+	return tokenType, l.slice(position, l.position)
 }
 
-func isLetter(ch byte) bool {
-	// Check if the character is a letter (a-z, A-Z) or an underscore (_)
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+func (l *Lexer) readDigits(isValidDigit func(rune) bool) bool {
+	// Read a run of digits and underscores and report whether it is well-formed
+	// It must not be empty, and every underscore must sit between two digits
+	// For example, "1_000" is well-formed, while "", "1_", "1__0" are not
+	position := l.position
+	for isValidDigit(l.ch) || l.ch == '_' {
+		l.readChar()
+	}
+	digits := l.slice(position, l.position)
+
+	return digits != "" && digits[0] != '_' && digits[len(digits)-1] != '_' &&
+		!strings.Contains(digits, "__")
+}
+
+func (l *Lexer) readString(position int, quote token.Pos) (token.TokenType, string) {
+	// Read a double-quoted string from the current character up to the closing quote, and return its value with the escapes resolved
+	// The part being read starts at the offset position, on the opening quote or just after the "}" of an interpolation
+	// A string that is not closed on the same line, or that contains an unknown escape,
+	// is returned as an ILLEGAL token whose literal is the string as it appears in the input
+	// For example, for the input "\"abc" the token is ILLEGAL with the literal "\"abc"
+	// A "${" ends the part read so far, which is returned as a STRING_PART, and is left for the next token
+	valid := true
+	var out strings.Builder
+
+	for ; ; l.readChar() {
+		switch l.ch {
+		case '"':
+			l.readChar()
+			if !valid {
+				return token.ILLEGAL, l.slice(position, l.position)
+			}
+			return token.STRING, out.String()
+		case 0, '\n':
+			// Unterminated string, the newline is left for the next token
+			l.error(quote, UnterminatedString, "string literal not terminated")
+			return token.ILLEGAL, l.slice(position, l.position)
+		case '\\':
+			escapePos := l.currentPosition()
+			l.readChar()
+			if r, ok := l.readEscape(); ok {
+				out.WriteRune(r)
+			} else if l.ch == 0 || l.ch == '\n' {
+				l.error(quote, UnterminatedString, "string literal not terminated")
+				return token.ILLEGAL, l.slice(position, l.position)
+			} else {
+				l.error(escapePos, InvalidEscape, "unknown escape sequence")
+				valid = false
+			}
+		case '$':
+			if l.peekChar() == '{' {
+				l.interpolations = append(l.interpolations, interpolation{quote: quote})
+				l.afterPart = true
+				if !valid {
+					return token.ILLEGAL, l.slice(position, l.position)
+				}
+				return token.STRING_PART, out.String()
+			}
+			out.WriteRune(l.ch)
+		default:
+			out.WriteString(l.slice(l.position, l.readPosition))
+		}
+	}
 }
 
-func isDigit(ch byte) bool {
+func (l *Lexer) readEscape() (rune, bool) {
+	// Resolve the escape sequence whose first character, after the backslash, is the current character
+	// For example, for "\u{1F600}" the current character is 'u' and the result is the rune U+1F600
+	switch l.ch {
+	case 'n':
+		return '\n', true
+	case 't':
+		return '\t', true
+	case '"':
+		return '"', true
+	case '\\':
+		return '\\', true
+	case '$':
+		// So "\${" is a dollar sign and a brace, not an interpolation
+		return '$', true
+	case 'u':
+		if l.peekChar() != '{' {
+			return 0, false
+		}
+		l.readChar()
+
+		position := l.readPosition
+		for isHexDigit(l.peekChar()) {
+			l.readChar()
+		}
+		digits := l.slice(position, l.readPosition)
+
+		if l.peekChar() != '}' || len(digits) == 0 || len(digits) > 6 {
+			return 0, false
+		}
+		l.readChar()
+
+		code, _ := strconv.ParseUint(digits, 16, 32)
+		r := rune(code)
+		if !utf8.ValidRune(r) {
+			return 0, false
+		}
+		return r, true
+	default:
+		return 0, false
+	}
+}
+
+func (l *Lexer) readRawString() (token.TokenType, string) {
+	// Read a backtick-quoted raw string, including both backticks, and return its content as it is
+	// A raw string that is not closed before the end of the input is returned as an ILLEGAL token
+	position := l.position
+	pos := l.currentPosition()
+
+	for {
+		l.readChar()
+
+		switch l.ch {
+		case '`':
+			l.readChar()
+			return token.STRING, l.slice(position+1, l.position-1)
+		case 0:
+			l.error(pos, UnterminatedString, "raw string literal not terminated")
+			return token.ILLEGAL, l.slice(position, l.position)
+		}
+	}
+}
+
+func (l *Lexer) readComment() (token.TokenType, string) {
+	// Read a comment starting at the current character and return it including its delimiters
+	// A line comment runs until the end of the line, the newline itself is not part of it
+	// Block comments can be nested, so "/* a /* b */ c */" is a single comment
+	position := l.position
+
+	if l.peekChar() == '/' {
+		for l.ch != '\n' && l.ch != 0 {
+			l.readChar()
+		}
+		return token.COMMENT, l.slice(position, l.position)
+	}
+
+	// Skip the opening "/*"
+	l.readChar()
+	l.readChar()
+
+	depth := 1
+	for depth > 0 {
+		switch {
+		case l.ch == 0:
+			return token.ILLEGAL, l.slice(position, l.position)
+		case l.ch == '/' && l.peekChar() == '*':
+			depth += 1
+			l.readChar()
+		case l.ch == '*' && l.peekChar() == '/':
+			depth -= 1
+			l.readChar()
+		}
+		l.readChar()
+	}
+
+	return token.COMMENT, l.slice(position, l.position)
+}
+
+func isLetter(ch rune) bool {
+	// Check if the character is a letter or an underscore (_)
+	// Any Unicode letter is accepted, so "größe" and "名前" are valid identifiers
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' ||
+		ch >= utf8.RuneSelf && unicode.IsLetter(ch)
+}
+
+func isDigit(ch rune) bool {
 	// Check if the character is a digit (0-9)
 	return '0' <= ch && ch <= '9'
 }
 
+func isBasePrefix(ch rune) bool {
+	// Check if the character follows a leading 0 to give the base of an integer, e.g. the x in 0xFF
+	return ch == 'x' || ch == 'X' || ch == 'o' || ch == 'O' || ch == 'b' || ch == 'B'
+}
+
+func isBinaryDigit(ch rune) bool {
+	return ch == '0' || ch == '1'
+}
+
+func isOctalDigit(ch rune) bool {
+	return '0' <= ch && ch <= '7'
+}
+
+func isHexDigit(ch rune) bool {
+	// Check if the character is a hexadecimal digit (0-9, a-f, A-F)
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
 func (l *Lexer) skipWhitespace() {
 	// Skip whitespace characters (space, tab, newline, carriage return)
 	// For example, if the input is "let x = 5;", skip the whitespace before "x"
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+	// A newline is not skipped when a semicolon is to be inserted there
+	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' && !l.insertSemi || l.ch == '\r' {
 		l.readChar()
+		l.startToken()
 	}
 }
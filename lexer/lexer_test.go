@@ -1,21 +1,24 @@
 package lexer
 
 import (
+	"errors"
 	"fmt"
 	"interpreter/token"
+	"io"
+	"strings"
 	"testing"
+	"testing/iotest"
 )
 
-func TestNextToken(t *testing.T) {
-	// This is a test function for the NextToken method of the Lexer
-	// It checks if the tokens returned by the lexer match the expected tokens
-	input := `let five = 5;
+// nextTokenInput is the input of TestNextToken, shared with the tests comparing the lexer constructors
+// The slash and the asterisk are separated by a space, as "/*" would start a block comment
+const nextTokenInput = `let five = 5;
 	let ten = 10;
 	let add = fn(x, y) {
 	x + y;
 	};
 	let result = add(five, ten);
-	!-/*5;
+	!-/ *5;
 	5 < 10 > 5;
 
 	if (5 < 10) {
@@ -27,6 +30,11 @@ func TestNextToken(t *testing.T) {
 	10 != 9;
 	`
 
+func TestNextToken(t *testing.T) {
+	// This is a test function for the NextToken method of the Lexer
+	// It checks if the tokens returned by the lexer match the expected tokens
+	input := nextTokenInput
+
 	tests := []struct {
 		expectedType    token.TokenType
 		expectedLiteral string
@@ -125,3 +133,938 @@ func TestNextToken(t *testing.T) {
 		fmt.Println(tok)
 	}
 }
+
+func TestNextTokenPositions(t *testing.T) {
+	// This is a test function for the positions the lexer attaches to the tokens
+	// It checks the start and end of every token of a multi-line input, including ILLEGAL and EOF
+	input := `let five = 5;
+	let add = fn(x, y) {
+	x + y;
+	};
+10 != 9 @
+`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedPos     token.Position
+		expectedEnd     token.Position
+	}{
+		{token.LET, "let", token.Position{Offset: 0, Line: 1, Column: 1}, token.Position{Offset: 3, Line: 1, Column: 4}},
+		{token.IDENT, "five", token.Position{Offset: 4, Line: 1, Column: 5}, token.Position{Offset: 8, Line: 1, Column: 9}},
+		{token.ASSIGN, "=", token.Position{Offset: 9, Line: 1, Column: 10}, token.Position{Offset: 10, Line: 1, Column: 11}},
+		{token.INT, "5", token.Position{Offset: 11, Line: 1, Column: 12}, token.Position{Offset: 12, Line: 1, Column: 13}},
+		{token.SEMICOLON, ";", token.Position{Offset: 12, Line: 1, Column: 13}, token.Position{Offset: 13, Line: 1, Column: 14}},
+		{token.LET, "let", token.Position{Offset: 15, Line: 2, Column: 2}, token.Position{Offset: 18, Line: 2, Column: 5}},
+		{token.IDENT, "add", token.Position{Offset: 19, Line: 2, Column: 6}, token.Position{Offset: 22, Line: 2, Column: 9}},
+		{token.ASSIGN, "=", token.Position{Offset: 23, Line: 2, Column: 10}, token.Position{Offset: 24, Line: 2, Column: 11}},
+		{token.FUNCTION, "fn", token.Position{Offset: 25, Line: 2, Column: 12}, token.Position{Offset: 27, Line: 2, Column: 14}},
+		{token.LPAREN, "(", token.Position{Offset: 27, Line: 2, Column: 14}, token.Position{Offset: 28, Line: 2, Column: 15}},
+		{token.IDENT, "x", token.Position{Offset: 28, Line: 2, Column: 15}, token.Position{Offset: 29, Line: 2, Column: 16}},
+		{token.COMMA, ",", token.Position{Offset: 29, Line: 2, Column: 16}, token.Position{Offset: 30, Line: 2, Column: 17}},
+		{token.IDENT, "y", token.Position{Offset: 31, Line: 2, Column: 18}, token.Position{Offset: 32, Line: 2, Column: 19}},
+		{token.RPAREN, ")", token.Position{Offset: 32, Line: 2, Column: 19}, token.Position{Offset: 33, Line: 2, Column: 20}},
+		{token.LBRACE, "{", token.Position{Offset: 34, Line: 2, Column: 21}, token.Position{Offset: 35, Line: 2, Column: 22}},
+		{token.IDENT, "x", token.Position{Offset: 37, Line: 3, Column: 2}, token.Position{Offset: 38, Line: 3, Column: 3}},
+		{token.PLUS, "+", token.Position{Offset: 39, Line: 3, Column: 4}, token.Position{Offset: 40, Line: 3, Column: 5}},
+		{token.IDENT, "y", token.Position{Offset: 41, Line: 3, Column: 6}, token.Position{Offset: 42, Line: 3, Column: 7}},
+		{token.SEMICOLON, ";", token.Position{Offset: 42, Line: 3, Column: 7}, token.Position{Offset: 43, Line: 3, Column: 8}},
+		{token.RBRACE, "}", token.Position{Offset: 45, Line: 4, Column: 2}, token.Position{Offset: 46, Line: 4, Column: 3}},
+		{token.SEMICOLON, ";", token.Position{Offset: 46, Line: 4, Column: 3}, token.Position{Offset: 47, Line: 4, Column: 4}},
+		{token.INT, "10", token.Position{Offset: 48, Line: 5, Column: 1}, token.Position{Offset: 50, Line: 5, Column: 3}},
+		{token.NOT_EQ, "!=", token.Position{Offset: 51, Line: 5, Column: 4}, token.Position{Offset: 53, Line: 5, Column: 6}},
+		{token.INT, "9", token.Position{Offset: 54, Line: 5, Column: 7}, token.Position{Offset: 55, Line: 5, Column: 8}},
+		{token.ILLEGAL, "@", token.Position{Offset: 56, Line: 5, Column: 9}, token.Position{Offset: 57, Line: 5, Column: 10}},
+		{token.EOF, "", token.Position{Offset: 58, Line: 6, Column: 1}, token.Position{Offset: 58, Line: 6, Column: 1}},
+		{token.EOF, "", token.Position{Offset: 58, Line: 6, Column: 1}, token.Position{Offset: 58, Line: 6, Column: 1}},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+
+		pos, end := l.File().Position(tok.Pos), l.File().Position(tok.End)
+
+		if pos != tt.expectedPos {
+			t.Fatalf("tests[%d] - pos wrong. expected=%+v, got=%+v",
+				i, tt.expectedPos, pos)
+		}
+
+		if end != tt.expectedEnd {
+			t.Fatalf("tests[%d] - end wrong. expected=%+v, got=%+v",
+				i, tt.expectedEnd, end)
+		}
+	}
+}
+
+func TestNextTokenUnicode(t *testing.T) {
+	// This is a test function for UTF-8 input
+	// Identifiers may contain any Unicode letter, and the offsets count bytes, not characters
+	input := "let größe = 5;\nlet 名前 = größe € \xff;"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedPos     token.Position
+		expectedEnd     token.Position
+	}{
+		{token.LET, "let", token.Position{Offset: 0, Line: 1, Column: 1}, token.Position{Offset: 3, Line: 1, Column: 4}},
+		{token.IDENT, "größe", token.Position{Offset: 4, Line: 1, Column: 5}, token.Position{Offset: 11, Line: 1, Column: 12}},
+		{token.ASSIGN, "=", token.Position{Offset: 12, Line: 1, Column: 13}, token.Position{Offset: 13, Line: 1, Column: 14}},
+		{token.INT, "5", token.Position{Offset: 14, Line: 1, Column: 15}, token.Position{Offset: 15, Line: 1, Column: 16}},
+		{token.SEMICOLON, ";", token.Position{Offset: 15, Line: 1, Column: 16}, token.Position{Offset: 16, Line: 1, Column: 17}},
+		{token.LET, "let", token.Position{Offset: 17, Line: 2, Column: 1}, token.Position{Offset: 20, Line: 2, Column: 4}},
+		{token.IDENT, "名前", token.Position{Offset: 21, Line: 2, Column: 5}, token.Position{Offset: 27, Line: 2, Column: 11}},
+		{token.ASSIGN, "=", token.Position{Offset: 28, Line: 2, Column: 12}, token.Position{Offset: 29, Line: 2, Column: 13}},
+		{token.IDENT, "größe", token.Position{Offset: 30, Line: 2, Column: 14}, token.Position{Offset: 37, Line: 2, Column: 21}},
+		{token.ILLEGAL, "€", token.Position{Offset: 38, Line: 2, Column: 22}, token.Position{Offset: 41, Line: 2, Column: 25}},
+		{token.ILLEGAL, "\xff", token.Position{Offset: 42, Line: 2, Column: 26}, token.Position{Offset: 43, Line: 2, Column: 27}},
+		{token.SEMICOLON, ";", token.Position{Offset: 43, Line: 2, Column: 27}, token.Position{Offset: 44, Line: 2, Column: 28}},
+		{token.EOF, "", token.Position{Offset: 44, Line: 2, Column: 28}, token.Position{Offset: 44, Line: 2, Column: 28}},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+
+		pos, end := l.File().Position(tok.Pos), l.File().Position(tok.End)
+
+		if pos != tt.expectedPos {
+			t.Fatalf("tests[%d] - pos wrong. expected=%+v, got=%+v",
+				i, tt.expectedPos, pos)
+		}
+
+		if end != tt.expectedEnd {
+			t.Fatalf("tests[%d] - end wrong. expected=%+v, got=%+v",
+				i, tt.expectedEnd, end)
+		}
+	}
+}
+
+func TestNextTokenStrings(t *testing.T) {
+	// This is a test function for string literals
+	// The literal of a STRING token is the value of the string with the escapes resolved,
+	// while the literal of an ILLEGAL token is the malformed string as it appears in the input
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{`"foobar"`, token.STRING, "foobar"},
+		{`"foo bar"`, token.STRING, "foo bar"},
+		{`""`, token.STRING, ""},
+		{`"a\nb\tc"`, token.STRING, "a\nb\tc"},
+		{`"say \"hi\""`, token.STRING, `say "hi"`},
+		{`"C:\\temp"`, token.STRING, `C:\temp`},
+		{`"\u{41}\u{e9}\u{1F600}"`, token.STRING, "Aé😀"},
+		{`"größe"`, token.STRING, "größe"},
+		{"`raw \\n string`", token.STRING, `raw \n string`},
+		{"`multi\nline`", token.STRING, "multi\nline"},
+		{`"unterminated`, token.ILLEGAL, `"unterminated`},
+		{"\"broken\nline\"", token.ILLEGAL, `"broken`},
+		{`"ends with \`, token.ILLEGAL, `"ends with \`},
+		{`"bad \q escape"`, token.ILLEGAL, `"bad \q escape"`},
+		{`"bad \u{110000}"`, token.ILLEGAL, `"bad \u{110000}"`},
+		{`"bad \u{}"`, token.ILLEGAL, `"bad \u{}"`},
+		{`"bad \u41"`, token.ILLEGAL, `"bad \u41"`},
+		{"`unterminated", token.ILLEGAL, "`unterminated"},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if l.File().Offset(tok.Pos) != 0 {
+			t.Fatalf("tests[%d] - pos wrong. expected=0, got=%d", i, l.File().Offset(tok.Pos))
+		}
+	}
+}
+
+func TestNextTokenAfterString(t *testing.T) {
+	// This is a test function for the tokens following a string
+	// The lexer must continue right after the closing quote, or after the end of the line for an unterminated string
+	input := "let s = \"a\\\"b\";\n\"open\nx"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "s"},
+		{token.ASSIGN, "="},
+		{token.STRING, `a"b`},
+		{token.SEMICOLON, ";"},
+		{token.ILLEGAL, `"open`},
+		{token.IDENT, "x"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestNextTokenInterpolation(t *testing.T) {
+	// This is a test function for strings with embedded expressions
+	// They are split into string parts and the tokens of the expressions, the last part is a STRING
+	type expected struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}
+
+	tests := []struct {
+		input    string
+		expected []expected
+	}{
+		{`"Hello ${name}, you are ${age + 1}"`, []expected{
+			{token.STRING_PART, "Hello "},
+			{token.INTERP_START, "${"},
+			{token.IDENT, "name"},
+			{token.INTERP_END, "}"},
+			{token.STRING_PART, ", you are "},
+			{token.INTERP_START, "${"},
+			{token.IDENT, "age"},
+			{token.PLUS, "+"},
+			{token.INT, "1"},
+			{token.INTERP_END, "}"},
+			{token.STRING, ""},
+			{token.EOF, ""},
+		}},
+		// The braces of the expression and the strings inside it don't end the interpolation
+		{`"${fn() { "}" }()} and ${ "in ${x}" }!" + y`, []expected{
+			{token.STRING_PART, ""},
+			{token.INTERP_START, "${"},
+			{token.FUNCTION, "fn"},
+			{token.LPAREN, "("},
+			{token.RPAREN, ")"},
+			{token.LBRACE, "{"},
+			{token.STRING, "}"},
+			{token.RBRACE, "}"},
+			{token.LPAREN, "("},
+			{token.RPAREN, ")"},
+			{token.INTERP_END, "}"},
+			{token.STRING_PART, " and "},
+			{token.INTERP_START, "${"},
+			{token.STRING_PART, "in "},
+			{token.INTERP_START, "${"},
+			{token.IDENT, "x"},
+			{token.INTERP_END, "}"},
+			{token.STRING, ""},
+			{token.INTERP_END, "}"},
+			{token.STRING, "!"},
+			{token.PLUS, "+"},
+			{token.IDENT, "y"},
+			{token.EOF, ""},
+		}},
+		// A dollar sign alone, or escaped, is just text, and a "}" outside a string is a brace
+		{`"$5 \${x} $" }`, []expected{
+			{token.STRING, "$5 ${x} $"},
+			{token.RBRACE, "}"},
+			{token.EOF, ""},
+		}},
+		{"\"a ${\n  b /* } */\n} c\"", []expected{
+			{token.STRING_PART, "a "},
+			{token.INTERP_START, "${"},
+			{token.IDENT, "b"},
+			{token.INTERP_END, "}"},
+			{token.STRING, " c"},
+			{token.EOF, ""},
+		}},
+		{`"a ${b`, []expected{
+			{token.STRING_PART, "a "},
+			{token.INTERP_START, "${"},
+			{token.IDENT, "b"},
+			{token.EOF, ""},
+		}},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+
+		for j, e := range tt.expected {
+			tok := l.NextToken()
+
+			if tok.Type != e.expectedType {
+				t.Fatalf("tests[%d][%d] - tokentype wrong. expected=%q, got=%q",
+					i, j, e.expectedType, tok.Type)
+			}
+
+			if tok.Literal != e.expectedLiteral {
+				t.Fatalf("tests[%d][%d] - literal wrong. expected=%q, got=%q",
+					i, j, e.expectedLiteral, tok.Literal)
+			}
+		}
+	}
+
+	// The parts cover the input between the delimiters
+	l := New(`"ab${x}cd"`)
+	for _, want := range []struct{ offset, end int }{{0, 3}, {3, 5}, {5, 6}, {6, 7}, {7, 10}} {
+		tok := l.NextToken()
+		if l.File().Offset(tok.Pos) != want.offset || l.File().Offset(tok.End) != want.end {
+			t.Errorf("%s %q - offsets wrong. expected=%d-%d, got=%d-%d", tok.Type, tok.Literal,
+				want.offset, want.end, l.File().Offset(tok.Pos), l.File().Offset(tok.End))
+		}
+	}
+
+	// No semicolon is inserted inside an embedded expression
+	l = New("\"${x\n}\"\n", WithAutoSemicolons())
+	var types []token.TokenType
+	for tok := range l.All() {
+		types = append(types, tok.Type)
+	}
+	if fmt.Sprint(types) != "[STRING_PART ${ IDENT } STRING ;]" {
+		t.Errorf("tokens wrong. got=%v", types)
+	}
+}
+
+func TestNextTokenComments(t *testing.T) {
+	// This is a test function for line and block comments
+	// The same input is lexed twice, once skipping the comments and once keeping them as COMMENT tokens
+	input := `// add two numbers
+let add = fn(x, y) { /* the sum /* nested */ of */ x + y; }; // trailing
+10 / 2 /* unterminated /* */`
+
+	type expected struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}
+
+	tests := []struct {
+		opts     []Option
+		expected []expected
+	}{
+		{nil, []expected{
+			{token.LET, "let"},
+			{token.IDENT, "add"},
+			{token.ASSIGN, "="},
+			{token.FUNCTION, "fn"},
+			{token.LPAREN, "("},
+			{token.IDENT, "x"},
+			{token.COMMA, ","},
+			{token.IDENT, "y"},
+			{token.RPAREN, ")"},
+			{token.LBRACE, "{"},
+			{token.IDENT, "x"},
+			{token.PLUS, "+"},
+			{token.IDENT, "y"},
+			{token.SEMICOLON, ";"},
+			{token.RBRACE, "}"},
+			{token.SEMICOLON, ";"},
+			{token.INT, "10"},
+			{token.SLASH, "/"},
+			{token.INT, "2"},
+			{token.ILLEGAL, "/* unterminated /* */"},
+			{token.EOF, ""},
+		}},
+		{[]Option{WithComments()}, []expected{
+			{token.COMMENT, "// add two numbers"},
+			{token.LET, "let"},
+			{token.IDENT, "add"},
+			{token.ASSIGN, "="},
+			{token.FUNCTION, "fn"},
+			{token.LPAREN, "("},
+			{token.IDENT, "x"},
+			{token.COMMA, ","},
+			{token.IDENT, "y"},
+			{token.RPAREN, ")"},
+			{token.LBRACE, "{"},
+			{token.COMMENT, "/* the sum /* nested */ of */"},
+			{token.IDENT, "x"},
+			{token.PLUS, "+"},
+			{token.IDENT, "y"},
+			{token.SEMICOLON, ";"},
+			{token.RBRACE, "}"},
+			{token.SEMICOLON, ";"},
+			{token.COMMENT, "// trailing"},
+			{token.INT, "10"},
+			{token.SLASH, "/"},
+			{token.INT, "2"},
+			{token.ILLEGAL, "/* unterminated /* */"},
+			{token.EOF, ""},
+		}},
+	}
+
+	for i, tt := range tests {
+		l := New(input, tt.opts...)
+
+		for j, e := range tt.expected {
+			tok := l.NextToken()
+
+			if tok.Type != e.expectedType {
+				t.Fatalf("tests[%d][%d] - tokentype wrong. expected=%q, got=%q",
+					i, j, e.expectedType, tok.Type)
+			}
+
+			if tok.Literal != e.expectedLiteral {
+				t.Fatalf("tests[%d][%d] - literal wrong. expected=%q, got=%q",
+					i, j, e.expectedLiteral, tok.Literal)
+			}
+		}
+	}
+}
+
+func TestNextTokenAutoSemicolons(t *testing.T) {
+	// This is a test function for the semicolons inserted at the ends of lines
+	// An inserted semicolon has the literal "\n", a semicolon of the input keeps the literal ";"
+	type expected struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}
+
+	tests := []struct {
+		input    string
+		opts     []Option
+		expected []expected
+	}{
+		{"let x = 5\nx", nil, []expected{
+			{token.LET, "let"},
+			{token.IDENT, "x"},
+			{token.ASSIGN, "="},
+			{token.INT, "5"},
+			{token.SEMICOLON, "\n"},
+			{token.IDENT, "x"},
+			{token.SEMICOLON, "\n"},
+			{token.EOF, ""},
+		}},
+		// No semicolon after a token that can't end a statement, nor after an explicit one
+		{"let add = fn(x,\n y) {\n  return\n}\nadd(1, 2);\n\n", nil, []expected{
+			{token.LET, "let"},
+			{token.IDENT, "add"},
+			{token.ASSIGN, "="},
+			{token.FUNCTION, "fn"},
+			{token.LPAREN, "("},
+			{token.IDENT, "x"},
+			{token.COMMA, ","},
+			{token.IDENT, "y"},
+			{token.RPAREN, ")"},
+			{token.LBRACE, "{"},
+			{token.RETURN, "return"},
+			{token.SEMICOLON, "\n"},
+			{token.RBRACE, "}"},
+			{token.SEMICOLON, "\n"},
+			{token.IDENT, "add"},
+			{token.LPAREN, "("},
+			{token.INT, "1"},
+			{token.COMMA, ","},
+			{token.INT, "2"},
+			{token.RPAREN, ")"},
+			{token.SEMICOLON, ";"},
+			{token.EOF, ""},
+		}},
+		{"1.5 +\n\"a\"\ntrue\nfalse\nx == y\n", nil, []expected{
+			{token.FLOAT, "1.5"},
+			{token.PLUS, "+"},
+			{token.STRING, "a"},
+			{token.SEMICOLON, "\n"},
+			{token.TRUE, "true"},
+			{token.SEMICOLON, "\n"},
+			{token.FALSE, "false"},
+			{token.SEMICOLON, "\n"},
+			{token.IDENT, "x"},
+			{token.EQ, "=="},
+			{token.IDENT, "y"},
+			{token.SEMICOLON, "\n"},
+			{token.EOF, ""},
+		}},
+		{"for (x in xs) {\n  if (x) { break }\n  continue\n}", nil, []expected{
+			{token.FOR, "for"},
+			{token.LPAREN, "("},
+			{token.IDENT, "x"},
+			{token.IN, "in"},
+			{token.IDENT, "xs"},
+			{token.RPAREN, ")"},
+			{token.LBRACE, "{"},
+			{token.IF, "if"},
+			{token.LPAREN, "("},
+			{token.IDENT, "x"},
+			{token.RPAREN, ")"},
+			{token.LBRACE, "{"},
+			{token.BREAK, "break"},
+			{token.RBRACE, "}"},
+			{token.SEMICOLON, "\n"},
+			{token.CONTINUE, "continue"},
+			{token.SEMICOLON, "\n"},
+			{token.RBRACE, "}"},
+			{token.SEMICOLON, "\n"},
+			{token.EOF, ""},
+		}},
+		// A comment running to the end of a line, or spanning lines, counts as a newline
+		{"x // note\ny /* a\nb */ z /* c */ + 1", []Option{WithComments()}, []expected{
+			{token.IDENT, "x"},
+			{token.SEMICOLON, "\n"},
+			{token.COMMENT, "// note"},
+			{token.IDENT, "y"},
+			{token.SEMICOLON, "\n"},
+			{token.COMMENT, "/* a\nb */"},
+			{token.IDENT, "z"},
+			{token.COMMENT, "/* c */"},
+			{token.PLUS, "+"},
+			{token.INT, "1"},
+			{token.SEMICOLON, "\n"},
+			{token.EOF, ""},
+		}},
+		{"x // note\ny /* a\nb */ z /* unterminated", nil, []expected{
+			{token.IDENT, "x"},
+			{token.SEMICOLON, "\n"},
+			{token.IDENT, "y"},
+			{token.SEMICOLON, "\n"},
+			{token.IDENT, "z"},
+			{token.SEMICOLON, "\n"},
+			{token.ILLEGAL, "/* unterminated"},
+			{token.EOF, ""},
+		}},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input, append(tt.opts, WithAutoSemicolons())...)
+
+		for j, e := range tt.expected {
+			tok := l.NextToken()
+
+			if tok.Type != e.expectedType {
+				t.Fatalf("tests[%d][%d] - tokentype wrong. expected=%q, got=%q",
+					i, j, e.expectedType, tok.Type)
+			}
+
+			if tok.Literal != e.expectedLiteral {
+				t.Fatalf("tests[%d][%d] - literal wrong. expected=%q, got=%q",
+					i, j, e.expectedLiteral, tok.Literal)
+			}
+		}
+
+		if len(l.Errors()) > 1 {
+			t.Errorf("tests[%d] - an error was reported more than once. got=%v", i, l.Errors())
+		}
+	}
+
+	// An inserted semicolon sits on the newline and has no width
+	l := New("x\ny", WithAutoSemicolons())
+	l.NextToken()
+	tok := l.NextToken()
+	if pos := l.File().Position(tok.Pos); pos.Line != 1 || pos.Column != 2 || tok.End != tok.Pos {
+		t.Errorf("inserted semicolon wrong. got=%s, width=%d", pos, tok.End-tok.Pos)
+	}
+	if pos := l.File().Position(l.NextToken().Pos); pos.Line != 2 || pos.Column != 1 {
+		t.Errorf("token after the inserted semicolon wrong. got=%s", pos)
+	}
+}
+
+func TestNextTokenNumbers(t *testing.T) {
+	// This is a test function for integer and float literals
+	// A malformed number must be a single ILLEGAL token, and must not be split into several tokens
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{"0", token.INT, "0"},
+		{"1234567890", token.INT, "1234567890"},
+		{"1_000_000", token.INT, "1_000_000"},
+		{"0xFF", token.INT, "0xFF"},
+		{"0Xdead_beef", token.INT, "0Xdead_beef"},
+		{"0o17", token.INT, "0o17"},
+		{"0b1010_0101", token.INT, "0b1010_0101"},
+		{"3.14", token.FLOAT, "3.14"},
+		{"1.5e-3", token.FLOAT, "1.5e-3"},
+		{"2E+10", token.FLOAT, "2E+10"},
+		{"6e23", token.FLOAT, "6e23"},
+		{"1_000.000_1", token.FLOAT, "1_000.000_1"},
+		{"0x", token.ILLEGAL, "0x"},
+		{"0b", token.ILLEGAL, "0b"},
+		{"0b102", token.ILLEGAL, "0b102"},
+		{"0o8", token.ILLEGAL, "0o8"},
+		{"0xFG", token.ILLEGAL, "0xFG"},
+		{"1e", token.ILLEGAL, "1e"},
+		{"1e+", token.ILLEGAL, "1e+"},
+		{"1.", token.ILLEGAL, "1."},
+		{"1.e5", token.ILLEGAL, "1.e5"},
+		{"1_", token.ILLEGAL, "1_"},
+		{"1__0", token.ILLEGAL, "1__0"},
+		{"0x_1", token.ILLEGAL, "0x_1"},
+		{"12ab", token.ILLEGAL, "12ab"},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input + ";")
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+
+		next := l.NextToken()
+		if next.Type != token.SEMICOLON {
+			t.Fatalf("tests[%d] - number was split. next token=%q (%q)",
+				i, next.Type, next.Literal)
+		}
+	}
+}
+
+func TestNextTokenOperators(t *testing.T) {
+	// This is a test function for the operators
+	// The longest operator always wins, so "**" is a single POWER token and "<=" a single LT_EQ token
+	input := `a <= b >= c && d || e % f ** g;
+x += 1; x -= 2; x *= 3; x /= 4;
+!a != b == c = d < e > f;
+a***b & c | d;
+[1, 2][0]; {foo: bar}`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "a"},
+		{token.LT_EQ, "<="},
+		{token.IDENT, "b"},
+		{token.GT_EQ, ">="},
+		{token.IDENT, "c"},
+		{token.AND, "&&"},
+		{token.IDENT, "d"},
+		{token.OR, "||"},
+		{token.IDENT, "e"},
+		{token.PERCENT, "%"},
+		{token.IDENT, "f"},
+		{token.POWER, "**"},
+		{token.IDENT, "g"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.MINUS_ASSIGN, "-="},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.INT, "3"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.SLASH_ASSIGN, "/="},
+		{token.INT, "4"},
+		{token.SEMICOLON, ";"},
+		{token.BANG, "!"},
+		{token.IDENT, "a"},
+		{token.NOT_EQ, "!="},
+		{token.IDENT, "b"},
+		{token.EQ, "=="},
+		{token.IDENT, "c"},
+		{token.ASSIGN, "="},
+		{token.IDENT, "d"},
+		{token.LT, "<"},
+		{token.IDENT, "e"},
+		{token.GT, ">"},
+		{token.IDENT, "f"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.POWER, "**"},
+		{token.ASTERISK, "*"},
+		{token.IDENT, "b"},
+		{token.ILLEGAL, "&"},
+		{token.IDENT, "c"},
+		{token.ILLEGAL, "|"},
+		{token.IDENT, "d"},
+		{token.SEMICOLON, ";"},
+		{token.LBRACKET, "["},
+		{token.INT, "1"},
+		{token.COMMA, ","},
+		{token.INT, "2"},
+		{token.RBRACKET, "]"},
+		{token.LBRACKET, "["},
+		{token.INT, "0"},
+		{token.RBRACKET, "]"},
+		{token.SEMICOLON, ";"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "foo"},
+		{token.COLON, ":"},
+		{token.IDENT, "bar"},
+		{token.RBRACE, "}"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if int(tok.End-tok.Pos) != len(tok.Literal) {
+			t.Fatalf("tests[%d] - token length wrong. expected=%d, got=%d",
+				i, len(tok.Literal), tok.End-tok.Pos)
+		}
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	// This is a test function for the Lexer reading from an io.Reader
+	// It must return exactly the same tokens, positions included, as the Lexer created from a string,
+	// even when the reader hands out a single byte at a time or the tokens cross the buffer boundaries
+	long := strings.Repeat("let größe = \"a \\u{1F600} string\"; /* comment */ 0x_1 `raw` ** 1.5e3;\n", 200)
+
+	tests := []struct {
+		name  string
+		input string
+		opts  []Option
+	}{
+		{"TestNextToken", nextTokenInput, nil},
+		{"empty", "", nil},
+		{"unicode", "let 名前 = größe € \xff;", nil},
+		{"long", long, nil},
+		{"long with comments", long, []Option{WithComments()}},
+		{"long string", `"` + strings.Repeat("x", 3*bufferSize) + `" + 1`, nil},
+		{"long comment", "1 // " + strings.Repeat("x", 3*bufferSize) + "\n2", nil},
+		{"long comment with semicolons", "1 // " + strings.Repeat("x", 3*bufferSize) + "\n2", []Option{WithAutoSemicolons(), WithComments()}},
+		{"long with semicolons", long, []Option{WithAutoSemicolons()}},
+		{"interpolation", strings.Repeat(`"a ${ fn() { "${b}" }() } c" `, 500), nil},
+		{"unterminated", "`" + strings.Repeat("x", bufferSize), nil},
+	}
+
+	for _, tt := range tests {
+		readers := map[string]io.Reader{
+			"reader":       strings.NewReader(tt.input),
+			"one byte":     iotest.OneByteReader(strings.NewReader(tt.input)),
+			"data and EOF": iotest.DataErrReader(strings.NewReader(tt.input)),
+		}
+
+		for name, r := range readers {
+			expected := New(tt.input, tt.opts...)
+			l := NewReader(r, tt.opts...)
+
+			for i := 0; ; i++ {
+				want := expected.NextToken()
+				got := l.NextToken()
+
+				if got != want {
+					t.Fatalf("%s, %s: tokens[%d] wrong. expected=%+v, got=%+v", tt.name, name, i, want, got)
+				}
+
+				if want.Type == token.EOF {
+					break
+				}
+			}
+
+			if l.Err() != nil {
+				t.Errorf("%s, %s: unexpected error %v", tt.name, name, l.Err())
+			}
+		}
+	}
+}
+
+func TestNewReaderError(t *testing.T) {
+	// This is a test function for a reader failing in the middle of the input
+	// The lexer stops as if the input ended, and the error is reported by Err
+	readErr := errors.New("disk on fire")
+	r := io.MultiReader(strings.NewReader("let x = 5;"), iotest.ErrReader(readErr))
+
+	l := NewReader(r)
+
+	var types []token.TokenType
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		types = append(types, tok.Type)
+	}
+
+	if len(types) != 5 {
+		t.Errorf("wrong number of tokens. expected=5, got=%d (%q)", len(types), types)
+	}
+
+	if l.Err() != readErr {
+		t.Errorf("error wrong. expected=%v, got=%v", readErr, l.Err())
+	}
+}
+
+// emptyReader returns no data and no error on every read, which io.Reader allows but discourages
+type emptyReader struct{}
+
+func (emptyReader) Read(p []byte) (int, error) { return 0, nil }
+
+func TestNewReaderNoProgress(t *testing.T) {
+	// This is a test function for a reader that never returns any data nor any error
+	// The lexer gives up on it instead of waiting forever, after the tokens read before
+	l := NewReader(io.MultiReader(strings.NewReader("let x"), emptyReader{}))
+
+	var types []token.TokenType
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		types = append(types, tok.Type)
+	}
+
+	if len(types) != 2 {
+		t.Errorf("wrong number of tokens. expected=2, got=%d (%q)", len(types), types)
+	}
+
+	if l.Err() != io.ErrNoProgress {
+		t.Errorf("error wrong. expected=%v, got=%v", io.ErrNoProgress, l.Err())
+	}
+}
+
+func TestNewReaderWindow(t *testing.T) {
+	// This is a test function for the bounded buffering of the Lexer reading from an io.Reader
+	// The window only holds the current token and what was read ahead, not the input read so far
+	input := strings.Repeat("let x = 12345; ", 100000)
+
+	l := NewReader(strings.NewReader(input))
+
+	count := 0
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if len(l.input) > 2*bufferSize {
+			t.Fatalf("window too large after %d tokens. got=%d bytes", count, len(l.input))
+		}
+		count++
+	}
+
+	if count != 500000 {
+		t.Errorf("wrong number of tokens. expected=500000, got=%d", count)
+	}
+}
+
+func BenchmarkNextToken(b *testing.B) {
+	// This is a benchmark for lexing a large input, the TestNextToken input repeated many times
+	input := strings.Repeat(nextTokenInput, 1000)
+	b.SetBytes(int64(len(input)))
+
+	for i := 0; i < b.N; i++ {
+		l := New(input)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
+	}
+}
+
+func BenchmarkNextTokenCompare(b *testing.B) {
+	// This is a benchmark for lexing while comparing every token type against several others,
+	// as a parser does when it looks for the token it expects
+	input := strings.Repeat(nextTokenInput, 1000)
+	b.SetBytes(int64(len(input)))
+
+	expected := []token.TokenType{token.LET, token.IDENT, token.ASSIGN, token.SEMICOLON, token.RBRACE}
+
+	for i := 0; i < b.N; i++ {
+		matches := 0
+		l := New(input)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+			for _, t := range expected {
+				if tok.Type == t {
+					matches++
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkNewReaderLongToken(b *testing.B) {
+	// This is a benchmark for reading one token of several MB from an io.Reader
+	// The window grows to hold the whole token, which must take a time proportional to its length
+	input := "`" + strings.Repeat("a", 8<<20) + "`"
+	b.SetBytes(int64(len(input)))
+
+	for i := 0; i < b.N; i++ {
+		l := NewReader(strings.NewReader(input))
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
+	}
+}
+
+func TestWithFile(t *testing.T) {
+	// This is a test function for lexing several files registered in the same FileSet
+	// The positions of the tokens of every file are resolved by the FileSet, with the name of their file
+	fset := token.NewFileSet()
+
+	files := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{"main.mk", "let x = lib();\n  puts(x);", []string{
+			"main.mk:1:1", "main.mk:1:5", "main.mk:1:7", "main.mk:1:9", "main.mk:1:12", "main.mk:1:13", "main.mk:1:14",
+			"main.mk:2:3", "main.mk:2:7", "main.mk:2:8", "main.mk:2:9", "main.mk:2:10",
+		}},
+		{"lib.mk", "\n\nlet lib = fn() { 1 };", []string{
+			"lib.mk:3:1", "lib.mk:3:5", "lib.mk:3:9", "lib.mk:3:11", "lib.mk:3:13", "lib.mk:3:14", "lib.mk:3:16",
+			"lib.mk:3:18", "lib.mk:3:20", "lib.mk:3:21",
+		}},
+	}
+
+	var positions [][]token.Pos
+	for _, f := range files {
+		var pos []token.Pos
+		for tok := range New(f.input, WithFile(fset, f.name)).All() {
+			pos = append(pos, tok.Pos)
+		}
+		positions = append(positions, pos)
+	}
+
+	for i, f := range files {
+		if len(positions[i]) != len(f.expected) {
+			t.Fatalf("%s - wrong number of tokens. expected=%d, got=%d", f.name, len(f.expected), len(positions[i]))
+		}
+		for j, expected := range f.expected {
+			if got := fset.Position(positions[i][j]).String(); got != expected {
+				t.Errorf("%s - tokens[%d] position wrong. expected=%q, got=%q", f.name, j, expected, got)
+			}
+		}
+	}
+
+	l := New("1 @", WithFile(fset, "bad.mk"))
+	for range l.All() {
+	}
+	if len(l.Errors()) != 1 || l.Errors()[0].Error() != `bad.mk:1:3: unexpected character "@"` {
+		t.Errorf("errors wrong. got=%v", l.Errors())
+	}
+}
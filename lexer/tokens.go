@@ -0,0 +1,107 @@
+package lexer
+
+import (
+	"interpreter/token"
+	"iter"
+)
+
+// All returns an iterator over the remaining tokens of the Lexer, up to but not including EOF
+// For example, "for tok := range l.All() { ... }" visits every token of the input once
+func (l *Lexer) All() iter.Seq[token.Token] {
+	return func(yield func(token.Token) bool) {
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+			if !yield(tok) {
+				return
+			}
+		}
+	}
+}
+
+// Tokenize returns every token of the input, without the final EOF, and the errors found in it
+// Every error is an *Error
+// For example, Tokenize("let x = @;") returns five tokens and the error "1:9: unexpected character \"@\""
+func Tokenize(input string) ([]token.Token, []error) {
+	var tokens []token.Token
+	var errs []error
+
+	l := New(input)
+	for tok := range l.All() {
+		tokens = append(tokens, tok)
+	}
+
+	for _, err := range l.Errors() {
+		errs = append(errs, err)
+	}
+
+	return tokens, errs
+}
+
+// Buffer reads tokens from a Lexer and allows looking any number of tokens ahead and going back
+// A parser can Mark a position, try to parse something and Reset to the mark if it fails
+type Buffer struct {
+	l *Lexer
+
+	// The tokens read from the lexer but not dropped yet, tokens[0] is the token with the index offset
+	// Indexes count the tokens from the beginning of the input
+	tokens []token.Token
+	offset int
+	// The index of the token Next returns
+	pos int
+	// The number of marks that were neither reset nor released
+	// As long as there are some, no token is dropped, so the buffer can go back to any of them
+	marks int
+}
+
+func NewBuffer(l *Lexer) *Buffer {
+	return &Buffer{l: l}
+}
+
+// Peek returns the token n tokens ahead without consuming anything
+// Peek(0) is the token the next call to Next returns
+// Past the end of the input, Peek returns EOF tokens
+func (b *Buffer) Peek(n int) token.Token {
+	// Read from the lexer until the buffer holds the token we want to see
+	index := b.pos - b.offset + n
+	for len(b.tokens) <= index {
+		b.tokens = append(b.tokens, b.l.NextToken())
+	}
+	return b.tokens[index]
+}
+
+// Next consumes and returns the next token
+func (b *Buffer) Next() token.Token {
+	tok := b.Peek(0)
+	b.pos += 1
+
+	if b.marks == 0 {
+		// Nobody can go back anymore, so the consumed tokens are not needed
+		// For example, a parser that never marks keeps at most its lookahead in the buffer
+		b.tokens = b.tokens[b.pos-b.offset:]
+		b.offset = b.pos
+	}
+
+	return tok
+}
+
+// Mark returns the current position, so the buffer can Reset to it later
+// Every mark must be given back with Reset or Release
+func (b *Buffer) Mark() int {
+	b.marks += 1
+	return b.pos
+}
+
+// Reset goes back to the position returned by Mark, the tokens after it are returned again by Next
+func (b *Buffer) Reset(mark int) {
+	if mark < b.offset || mark > b.offset+len(b.tokens) {
+		panic("lexer: Reset to a position that is not buffered")
+	}
+	b.pos = mark
+	b.Release(mark)
+}
+
+// Release gives back a mark without moving, once there is no need to go back to it
+func (b *Buffer) Release(mark int) {
+	if b.marks > 0 {
+		b.marks -= 1
+	}
+}
@@ -0,0 +1,138 @@
+package lexer
+
+import (
+	"interpreter/token"
+	"testing"
+)
+
+func TestAll(t *testing.T) {
+	// This is a test function for ranging over the tokens of a Lexer
+	// It must visit the same tokens as calling NextToken until EOF, and stop early on break
+	var expected []token.Token
+	l := New(nextTokenInput)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		expected = append(expected, tok)
+	}
+
+	var got []token.Token
+	for tok := range New(nextTokenInput).All() {
+		got = append(got, tok)
+	}
+
+	if len(got) != len(expected) {
+		t.Fatalf("wrong number of tokens. expected=%d, got=%d", len(expected), len(got))
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Fatalf("tokens[%d] wrong. expected=%+v, got=%+v", i, expected[i], got[i])
+		}
+	}
+
+	l = New("a b c d")
+	for tok := range l.All() {
+		if tok.Literal == "b" {
+			break
+		}
+	}
+	if tok := l.NextToken(); tok.Literal != "c" {
+		t.Errorf("token after break wrong. expected=%q, got=%q", "c", tok.Literal)
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	// This is a test function for tokenizing a whole input at once
+	tests := []struct {
+		input          string
+		expectedTypes  []token.TokenType
+		expectedErrors []string
+	}{
+		{"", nil, nil},
+		{"let x = 5;", []token.TokenType{token.LET, token.IDENT, token.ASSIGN, token.INT, token.SEMICOLON}, nil},
+		{"let x = @;", []token.TokenType{token.LET, token.IDENT, token.ASSIGN, token.ILLEGAL, token.SEMICOLON},
+			[]string{`1:9: unexpected character "@"`}},
+		{"1 &\n\"open", []token.TokenType{token.INT, token.ILLEGAL, token.ILLEGAL},
+			[]string{`1:3: unexpected character "&"`, `2:1: string literal not terminated`}},
+	}
+
+	for i, tt := range tests {
+		tokens, errs := Tokenize(tt.input)
+
+		if len(tokens) != len(tt.expectedTypes) {
+			t.Fatalf("tests[%d] - wrong number of tokens. expected=%d, got=%d", i, len(tt.expectedTypes), len(tokens))
+		}
+		for j, tok := range tokens {
+			if tok.Type != tt.expectedTypes[j] {
+				t.Errorf("tests[%d] - tokens[%d] wrong. expected=%q, got=%q", i, j, tt.expectedTypes[j], tok.Type)
+			}
+		}
+
+		if len(errs) != len(tt.expectedErrors) {
+			t.Fatalf("tests[%d] - wrong number of errors. expected=%d, got=%d", i, len(tt.expectedErrors), len(errs))
+		}
+		for j, err := range errs {
+			if err.Error() != tt.expectedErrors[j] {
+				t.Errorf("tests[%d] - errors[%d] wrong. expected=%q, got=%q", i, j, tt.expectedErrors[j], err.Error())
+			}
+		}
+	}
+}
+
+func TestBuffer(t *testing.T) {
+	// This is a test function for looking ahead and backtracking with a Buffer
+	b := NewBuffer(New("a b c d e"))
+
+	// Look far ahead without consuming anything
+	if tok := b.Peek(3); tok.Literal != "d" {
+		t.Fatalf("Peek(3) wrong. expected=%q, got=%q", "d", tok.Literal)
+	}
+	if tok := b.Peek(10); tok.Type != token.EOF {
+		t.Fatalf("Peek(10) wrong. expected=EOF, got=%q", tok.Type)
+	}
+	if tok := b.Next(); tok.Literal != "a" {
+		t.Fatalf("Next wrong. expected=%q, got=%q", "a", tok.Literal)
+	}
+
+	// Go back to a mark
+	mark := b.Mark()
+	b.Next()
+	inner := b.Mark()
+	b.Next()
+	b.Next()
+	b.Reset(inner)
+	if tok := b.Next(); tok.Literal != "c" {
+		t.Fatalf("Next after inner Reset wrong. expected=%q, got=%q", "c", tok.Literal)
+	}
+	b.Reset(mark)
+	if tok := b.Next(); tok.Literal != "b" {
+		t.Fatalf("Next after Reset wrong. expected=%q, got=%q", "b", tok.Literal)
+	}
+
+	// Keep going from a released mark
+	mark = b.Mark()
+	b.Next()
+	b.Release(mark)
+	if tok := b.Next(); tok.Literal != "d" {
+		t.Fatalf("Next after Release wrong. expected=%q, got=%q", "d", tok.Literal)
+	}
+
+	expected := []token.TokenType{token.IDENT, token.EOF, token.EOF}
+	for i, tt := range expected {
+		if tok := b.Next(); tok.Type != tt {
+			t.Fatalf("tokens[%d] wrong. expected=%q, got=%q", i, tt, tok.Type)
+		}
+	}
+}
+
+func TestBufferDropsConsumedTokens(t *testing.T) {
+	// This is a test function for the size of a Buffer without marks
+	// Only the lookahead is kept, however many tokens have been consumed
+	b := NewBuffer(New(nextTokenInput))
+
+	for b.Peek(0).Type != token.EOF {
+		b.Peek(2)
+		b.Next()
+		if len(b.tokens) > 3 {
+			t.Fatalf("buffer too large. got=%d tokens", len(b.tokens))
+		}
+	}
+}
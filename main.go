@@ -8,6 +8,11 @@ import (
 )
 
 func main() {
+	// With arguments, build or run a script instead of starting the REPL
+	if len(os.Args) > 1 {
+		os.Exit(runCLI(os.Args[1:], os.Stdout, os.Stderr))
+	}
+
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
@@ -15,6 +20,6 @@ func main() {
 
 	fmt.Printf("Hello %s! This is the Monkey programming languange!\n", user.Username)
 	fmt.Printf("Feel free to type in commands\n")
-	fmt.Printf("Type exit to quit\n")
+	fmt.Printf("Type exit or :quit to quit\n")
 	repl.Start(os.Stdin, os.Stdout)
 }
@@ -0,0 +1,329 @@
+// Package mkc reads and writes compiled Monkey bytecode as .mkc files, so a script doesn't have to be compiled on every run
+package mkc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"interpreter/code"
+	"interpreter/compiler"
+	"interpreter/object"
+	"io"
+	"math"
+)
+
+// A .mkc file is laid out as follows, with the fixed-size integers in big endian:
+//
+//	magic       "MKC\x00"
+//	version     uint16
+//	flags       one byte, flagOptimized when the source was optimized before it was compiled
+//	source hash the SHA-256 of the source, 32 bytes
+//	source      the name of the source file, as a string
+//	program     the instructions, the line table and the names of the globals of the main program
+//	constants   a uvarint count, then every constant as a tag byte followed by its value
+//	checksum    uint32, the CRC-32 of everything before it
+//
+// A string or an instruction sequence is a uvarint length followed by its bytes
+// A line table is a uvarint count followed by the offset and the line of every entry as uvarints
+// A list of names is a uvarint count followed by the names as strings
+const Magic = "MKC\x00"
+
+// Version is the version of the format written by Encode, and the only one Decode accepts
+// It has to be bumped whenever the layout or the meaning of the opcodes changes
+const Version = 4
+
+// The tags of the constants
+const (
+	tagInteger byte = iota + 1
+	tagFloat
+	tagString
+	tagFunction
+)
+
+// The bits of the flags byte
+const (
+	flagOptimized byte = 1 << iota
+)
+
+var (
+	ErrBadMagic           = errors.New("not a .mkc file")
+	ErrUnsupportedVersion = errors.New("unsupported .mkc version")
+	ErrChecksum           = errors.New(".mkc checksum mismatch")
+	ErrCorrupted          = errors.New("corrupted .mkc file")
+)
+
+// File is the content of a .mkc file
+type File struct {
+	// The name of the source file the bytecode was compiled from, and the hash of its content
+	// When the hash of the source no longer matches, the bytecode is stale
+	Source     string
+	SourceHash [sha256.Size]byte
+	// Whether the source was optimized before it was compiled, which gives other bytecode
+	Optimized bool
+	Bytecode  *compiler.Bytecode
+}
+
+// HashSource returns the hash of a source file stored in a File
+func HashSource(src []byte) [sha256.Size]byte {
+	return sha256.Sum256(src)
+}
+
+// Encode writes the file in the .mkc format
+// It fails if a constant isn't one the compiler produces
+func Encode(w io.Writer, f *File) error {
+	var e encoder
+	e.buf.WriteString(Magic)
+	binary.Write(&e.buf, binary.BigEndian, uint16(Version))
+	var flags byte
+	if f.Optimized {
+		flags |= flagOptimized
+	}
+	e.buf.WriteByte(flags)
+	e.buf.Write(f.SourceHash[:])
+	e.string(f.Source)
+
+	e.bytes(f.Bytecode.Instructions)
+	e.lines(f.Bytecode.Lines)
+	e.names(f.Bytecode.GlobalNames)
+
+	e.uvarint(uint64(len(f.Bytecode.Constants)))
+	for i, constant := range f.Bytecode.Constants {
+		if err := e.constant(constant); err != nil {
+			return fmt.Errorf("constant %d: %w", i, err)
+		}
+	}
+
+	binary.Write(&e.buf, binary.BigEndian, crc32.ChecksumIEEE(e.buf.Bytes()))
+
+	_, err := w.Write(e.buf.Bytes())
+	return err
+}
+
+type encoder struct {
+	buf bytes.Buffer
+}
+
+func (e *encoder) uvarint(x uint64) {
+	e.buf.Write(binary.AppendUvarint(nil, x))
+}
+
+func (e *encoder) bytes(b []byte) {
+	e.uvarint(uint64(len(b)))
+	e.buf.Write(b)
+}
+
+func (e *encoder) string(s string) {
+	e.bytes([]byte(s))
+}
+
+func (e *encoder) lines(lines code.LineTable) {
+	e.uvarint(uint64(len(lines)))
+	for _, entry := range lines {
+		e.uvarint(uint64(entry.Offset))
+		e.uvarint(uint64(entry.Line))
+	}
+}
+
+func (e *encoder) names(names []string) {
+	e.uvarint(uint64(len(names)))
+	for _, name := range names {
+		e.string(name)
+	}
+}
+
+func (e *encoder) constant(constant object.Object) error {
+	switch constant := constant.(type) {
+	case *object.Integer:
+		e.buf.WriteByte(tagInteger)
+		e.buf.Write(binary.AppendVarint(nil, constant.Value))
+	case *object.Float:
+		e.buf.WriteByte(tagFloat)
+		binary.Write(&e.buf, binary.BigEndian, math.Float64bits(constant.Value))
+	case *object.String:
+		e.buf.WriteByte(tagString)
+		e.string(constant.Value)
+	case *object.CompiledFunction:
+		e.buf.WriteByte(tagFunction)
+		e.uvarint(uint64(constant.NumLocals))
+		e.uvarint(uint64(constant.NumParameters))
+		e.bytes(constant.Instructions)
+		e.lines(constant.Lines)
+		e.names(constant.LocalNames)
+	default:
+		return fmt.Errorf("cannot encode %s", constant.Type())
+	}
+	return nil
+}
+
+// Decode reads a file in the .mkc format
+// The magic, the version and the checksum are checked before anything else is read,
+// so a file written by another version or damaged on disk is never run
+func Decode(r io.Reader) (*File, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	header := len(Magic) + 2
+	if len(data) < header || string(data[:len(Magic)]) != Magic {
+		return nil, ErrBadMagic
+	}
+	if version := binary.BigEndian.Uint16(data[len(Magic):]); version != Version {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, version)
+	}
+	if len(data) < header+1+sha256.Size+4 {
+		return nil, ErrCorrupted
+	}
+	body, checksum := data[:len(data)-4], binary.BigEndian.Uint32(data[len(data)-4:])
+	if crc32.ChecksumIEEE(body) != checksum {
+		return nil, ErrChecksum
+	}
+
+	d := decoder{data: body[header:]}
+	f := &File{Bytecode: &compiler.Bytecode{}}
+	flags := d.next(1)[0]
+	if flags&^flagOptimized != 0 {
+		return nil, ErrCorrupted
+	}
+	f.Optimized = flags&flagOptimized != 0
+	copy(f.SourceHash[:], d.next(sha256.Size))
+	f.Source = string(d.bytes())
+
+	f.Bytecode.Instructions = d.bytes()
+	f.Bytecode.Lines = d.lines()
+	f.Bytecode.GlobalNames = d.names()
+
+	n := d.count()
+	f.Bytecode.Constants = make([]object.Object, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		f.Bytecode.Constants = append(f.Bytecode.Constants, d.constant())
+	}
+
+	if d.err == nil && len(d.data) != 0 {
+		d.err = ErrCorrupted
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return f, nil
+}
+
+// decoder reads the values of a .mkc file one after the other
+// The first error is kept and every read after it returns zero values, so it only has to be checked at the end
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil || n < 0 || n > len(d.data) {
+		d.err = ErrCorrupted
+		return nil
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	x, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.err = ErrCorrupted
+		return 0
+	}
+	d.data = d.data[n:]
+	return x
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	x, n := binary.Varint(d.data)
+	if n <= 0 {
+		d.err = ErrCorrupted
+		return 0
+	}
+	d.data = d.data[n:]
+	return x
+}
+
+func (d *decoder) count() int {
+	// Every counted value takes at least one byte, so a count larger than what is left is corrupted
+	// This keeps a bad count from allocating a huge slice
+	n := d.uvarint()
+	if n > uint64(len(d.data)) {
+		d.err = ErrCorrupted
+		return 0
+	}
+	return int(n)
+}
+
+func (d *decoder) bytes() []byte {
+	b := d.next(d.count())
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
+
+func (d *decoder) lines() code.LineTable {
+	n := d.count()
+	if n == 0 {
+		return nil
+	}
+	lines := make(code.LineTable, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		lines = append(lines, code.LineEntry{Offset: int(d.uvarint()), Line: int(d.uvarint())})
+	}
+	return lines
+}
+
+func (d *decoder) names() []string {
+	n := d.count()
+	if n == 0 {
+		return nil
+	}
+	names := make([]string, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		names = append(names, string(d.bytes()))
+	}
+	return names
+}
+
+func (d *decoder) constant() object.Object {
+	tag := d.next(1)
+	if tag == nil {
+		return nil
+	}
+
+	switch tag[0] {
+	case tagInteger:
+		return &object.Integer{Value: d.varint()}
+	case tagFloat:
+		b := d.next(8)
+		if b == nil {
+			return nil
+		}
+		return &object.Float{Value: math.Float64frombits(binary.BigEndian.Uint64(b))}
+	case tagString:
+		return &object.String{Value: string(d.bytes())}
+	case tagFunction:
+		fn := &object.CompiledFunction{}
+		fn.NumLocals = int(d.uvarint())
+		fn.NumParameters = int(d.uvarint())
+		fn.Instructions = d.bytes()
+		fn.Lines = d.lines()
+		fn.LocalNames = d.names()
+		return fn
+	default:
+		d.err = ErrCorrupted
+		return nil
+	}
+}
@@ -0,0 +1,123 @@
+package mkc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"interpreter/compiler"
+	"interpreter/lexer"
+	"interpreter/object"
+	"interpreter/parser"
+	"interpreter/token"
+	"interpreter/vm"
+	"reflect"
+	"testing"
+)
+
+func compile(t *testing.T, input string) *compiler.Bytecode {
+	t.Helper()
+
+	fset := token.NewFileSet()
+	p := parser.New(lexer.New(input, lexer.WithFile(fset, "test.mk")))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	c := compiler.New(compiler.WithFileSet(fset))
+	if err := c.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	return c.Bytecode()
+}
+
+func encode(t *testing.T, f *File) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, f); err != nil {
+		t.Fatalf("encode error: %s", err)
+	}
+	return buf.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	// This is a test function for decoding what Encode wrote
+	// Every kind of constant is in the input, and the decoded bytecode has to run like the original
+	input := `
+let pi = 3.14;
+let greet = fn(name) { "Hello ${name}!" };
+let fib = fn(n) {
+  if (n < 2) { return n; }
+  fib(n - 1) + fib(n - 2)
+};
+[greet("Monkey"), fib(10), pi * -2]`
+
+	bytecode := compile(t, input)
+	f := &File{Source: "test.mk", SourceHash: HashSource([]byte(input)), Optimized: true, Bytecode: bytecode}
+
+	decoded, err := Decode(bytes.NewReader(encode(t, f)))
+	if err != nil {
+		t.Fatalf("decode error: %s", err)
+	}
+
+	if !reflect.DeepEqual(decoded, f) {
+		t.Fatalf("decoded file differs.\nwant=%#v\ngot=%#v", f, decoded)
+	}
+
+	machine := vm.New(decoded.Bytecode)
+	if err := machine.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	expected := "[Hello Monkey!, 55, -6.28]"
+	if got := machine.LastPoppedStackElem().Inspect(); got != expected {
+		t.Errorf("wrong result. want=%s, got=%s", expected, got)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	// This is a test function for the files Decode rejects
+	// For example, a single flipped byte of the instructions is caught by the checksum
+	valid := encode(t, &File{Source: "test.mk", Bytecode: compile(t, "let x = 1 + 2; x * 3")})
+
+	modify := func(f func(data []byte) []byte) []byte {
+		return f(append([]byte{}, valid...))
+	}
+	// checksum fixes the checksum of a modified file, so the change itself is what Decode rejects
+	checksum := func(d []byte) []byte {
+		binary.BigEndian.PutUint32(d[len(d)-4:], crc32.ChecksumIEEE(d[:len(d)-4]))
+		return d
+	}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		expected error
+	}{
+		{"empty", []byte{}, ErrBadMagic},
+		{"wrong magic", modify(func(d []byte) []byte { d[0] = 'X'; return d }), ErrBadMagic},
+		{"newer version", modify(func(d []byte) []byte { d[5] = Version + 1; return d }), ErrUnsupportedVersion},
+		{"unknown flag", modify(func(d []byte) []byte { d[6] = 0x80; return checksum(d) }), ErrCorrupted},
+		{"flipped byte", modify(func(d []byte) []byte { d[len(d)-10] ^= 0xFF; return d }), ErrChecksum},
+		{"truncated", valid[:len(valid)-1], ErrChecksum},
+		{"header only", valid[:6], ErrCorrupted},
+	}
+
+	for _, tt := range tests {
+		_, err := Decode(bytes.NewReader(tt.data))
+		if !errors.Is(err, tt.expected) {
+			t.Errorf("%s - wrong error. want=%q, got=%v", tt.name, tt.expected, err)
+		}
+	}
+}
+
+func TestEncodeUnsupportedConstant(t *testing.T) {
+	// This is a test function for constants the format has no tag for
+	f := &File{Bytecode: &compiler.Bytecode{Constants: []object.Object{&object.Boolean{Value: true}}}}
+
+	err := Encode(&bytes.Buffer{}, f)
+	if err == nil || err.Error() != "constant 0: cannot encode BOOLEAN" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+}
@@ -0,0 +1,225 @@
+package object
+
+import (
+	"fmt"
+	"interpreter/token"
+	"io"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// BuiltinFunction is the Go implementation of a built-in function
+// The output is where the function prints to, e.g. the out of repl.Start
+// It returns nil when the result is null, so it doesn't depend on how an evaluator represents null
+type BuiltinFunction func(out io.Writer, args ...Object) Object
+
+// Builtin is a built-in function as a value, e.g. the value of "len"
+type Builtin struct {
+	Fn BuiltinFunction
+}
+
+func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
+func (b *Builtin) Inspect() string  { return "builtin function" }
+
+// Builtins lists every built-in function with its name
+// The order never changes, so a compiler can refer to a built-in function by its index
+var Builtins = []struct {
+	Name    string
+	Builtin *Builtin
+}{
+	{"len", &Builtin{Fn: builtinLen}},
+	{"puts", &Builtin{Fn: builtinPuts}},
+	{"first", &Builtin{Fn: builtinFirst}},
+	{"last", &Builtin{Fn: builtinLast}},
+	{"rest", &Builtin{Fn: builtinRest}},
+	{"push", &Builtin{Fn: builtinPush}},
+	{"type", &Builtin{Fn: builtinType}},
+	{"str", &Builtin{Fn: builtinStr}},
+	{"int", &Builtin{Fn: builtinInt}},
+}
+
+// GetBuiltinByName returns the built-in function with the given name, or nil if there is none
+func GetBuiltinByName(name string) *Builtin {
+	for _, def := range Builtins {
+		if def.Name == name {
+			return def.Builtin
+		}
+	}
+	return nil
+}
+
+func newError(format string, a ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...)}
+}
+
+func checkArgs(name string, args []Object, want int) *Error {
+	// Check the number of arguments of a built-in function that takes a fixed number of them
+	if len(args) != want {
+		return newError("wrong number of arguments to `%s`: want=%d, got=%d", name, want, len(args))
+	}
+	return nil
+}
+
+func builtinLen(out io.Writer, args ...Object) Object {
+	// Return the number of characters of a string, or the number of elements of an array or a hash
+	// For example, len("größe") is 5, even though the string takes 7 bytes
+	if err := checkArgs("len", args, 1); err != nil {
+		return err
+	}
+
+	switch arg := args[0].(type) {
+	case *String:
+		return &Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+	case *Array:
+		return &Integer{Value: int64(len(arg.Elements))}
+	case *Hash:
+		return &Integer{Value: int64(len(arg.Pairs))}
+	default:
+		return newError("argument to `len` not supported, got %s", args[0].Type())
+	}
+}
+
+func builtinPuts(out io.Writer, args ...Object) Object {
+	// Print every argument on a line of its own and return null
+	for _, arg := range args {
+		io.WriteString(out, arg.Inspect()+"\n")
+	}
+	return nil
+}
+
+func arrayArg(name string, args []Object) (*Array, *Error) {
+	// Return the first argument of a built-in function that works on an array
+	// The number of arguments must have been checked already
+	array, ok := args[0].(*Array)
+	if !ok {
+		return nil, newError("argument to `%s` must be ARRAY, got %s", name, args[0].Type())
+	}
+	return array, nil
+}
+
+func builtinFirst(out io.Writer, args ...Object) Object {
+	// Return the first element of an array, or null if it is empty
+	if err := checkArgs("first", args, 1); err != nil {
+		return err
+	}
+	array, err := arrayArg("first", args)
+	if err != nil {
+		return err
+	}
+
+	if len(array.Elements) == 0 {
+		return nil
+	}
+	return array.Elements[0]
+}
+
+func builtinLast(out io.Writer, args ...Object) Object {
+	// Return the last element of an array, or null if it is empty
+	if err := checkArgs("last", args, 1); err != nil {
+		return err
+	}
+	array, err := arrayArg("last", args)
+	if err != nil {
+		return err
+	}
+
+	if len(array.Elements) == 0 {
+		return nil
+	}
+	return array.Elements[len(array.Elements)-1]
+}
+
+func builtinRest(out io.Writer, args ...Object) Object {
+	// Return a new array with every element but the first, or null if the array is empty
+	// For example, rest([1, 2, 3]) is [2, 3] and the array passed in is left as it is
+	if err := checkArgs("rest", args, 1); err != nil {
+		return err
+	}
+	array, err := arrayArg("rest", args)
+	if err != nil {
+		return err
+	}
+
+	if len(array.Elements) == 0 {
+		return nil
+	}
+
+	elements := make([]Object, len(array.Elements)-1)
+	copy(elements, array.Elements[1:])
+	return &Array{Elements: elements}
+}
+
+func builtinPush(out io.Writer, args ...Object) Object {
+	// Return a new array with the element added at the end
+	// For example, push([1, 2], 3) is [1, 2, 3] and the array passed in is left as it is
+	if err := checkArgs("push", args, 2); err != nil {
+		return err
+	}
+	array, err := arrayArg("push", args)
+	if err != nil {
+		return err
+	}
+
+	elements := make([]Object, len(array.Elements)+1)
+	copy(elements, array.Elements)
+	elements[len(array.Elements)] = args[1]
+	return &Array{Elements: elements}
+}
+
+func builtinType(out io.Writer, args ...Object) Object {
+	// Return the type of the value as a string, e.g. type(1.5) is "FLOAT"
+	if err := checkArgs("type", args, 1); err != nil {
+		return err
+	}
+	return &String{Value: string(args[0].Type())}
+}
+
+func builtinStr(out io.Writer, args ...Object) Object {
+	// Return the value as a string, the way puts prints it
+	// For example, str(1.0) is "1.0" and str([1, "a"]) is "[1, a]"
+	if err := checkArgs("str", args, 1); err != nil {
+		return err
+	}
+	if s, ok := args[0].(*String); ok {
+		return s
+	}
+	return &String{Value: args[0].Inspect()}
+}
+
+func builtinInt(out io.Writer, args ...Object) Object {
+	// Convert a number, a boolean or a string to an integer
+	// A float is truncated towards zero, and must be within the range of an integer, so NaN and the infinities fail
+	// A string must hold an integer literal
+	// For example, int(-2.5) is -2, int(true) is 1 and int("0xFF") is 255
+	// Like in the source code, a leading zero doesn't make a string octal, so int("010") is 10
+	if err := checkArgs("int", args, 1); err != nil {
+		return err
+	}
+
+	switch arg := args[0].(type) {
+	case *Integer:
+		return arg
+	case *Float:
+		// -2^63 is the smallest integer and 2^63 is one beyond the largest, both are exact floats
+		// The comparisons are false for NaN
+		if !(arg.Value >= math.MinInt64 && arg.Value < -math.MinInt64) {
+			return newError("could not convert %s to integer", arg.Inspect())
+		}
+		return &Integer{Value: int64(arg.Value)}
+	case *Boolean:
+		if arg.Value {
+			return &Integer{Value: 1}
+		}
+		return &Integer{Value: 0}
+	case *String:
+		// The string is parsed like an integer literal, so int("0_10") is 10 as well
+		value, err := token.ParseInt(strings.TrimSpace(arg.Value))
+		if err != nil {
+			return newError("could not parse %q as integer", arg.Value)
+		}
+		return &Integer{Value: value}
+	default:
+		return newError("argument to `int` not supported, got %s", args[0].Type())
+	}
+}
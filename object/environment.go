@@ -0,0 +1,79 @@
+package object
+
+import (
+	"io"
+	"os"
+)
+
+// Environment maps names to the values bound by let statements and function calls
+// Each function call gets a new Environment enclosed by the one the function was defined in,
+// so a name that is not found locally is looked up in the outer environments
+type Environment struct {
+	store map[string]Object
+	outer *Environment
+
+	// Where the built-in functions called in this environment print to, nil to use the outer one
+	out io.Writer
+
+	// The number of function calls running when this environment was created, counting its own
+	depth int
+}
+
+func NewEnvironment() *Environment {
+	s := make(map[string]Object)
+	return &Environment{store: s, outer: nil}
+}
+
+func NewEnclosedEnvironment(outer *Environment) *Environment {
+	env := NewEnvironment()
+	env.outer = outer
+	env.depth = outer.depth
+	return env
+}
+
+// NewCallEnvironment creates the environment of a function call, enclosed by the environment the function was defined in
+// The caller is the environment the function is called from, whose depth the call is one deeper than
+// For example, in "let f = fn() { f() }" every call of f is one deeper, though they all enclose the same environment
+func NewCallEnvironment(outer, caller *Environment) *Environment {
+	env := NewEnclosedEnvironment(outer)
+	env.depth = caller.depth + 1
+	return env
+}
+
+// Depth returns the number of function calls running in this environment, 0 outside of any function
+func (e *Environment) Depth() int {
+	return e.depth
+}
+
+func (e *Environment) Get(name string) (Object, bool) {
+	// Look the name up in this environment first and then in the enclosing ones
+	// For example, inside "fn(x) { x + y }" the x is found locally, while y comes from the outer environment
+	obj, ok := e.store[name]
+	if !ok && e.outer != nil {
+		obj, ok = e.outer.Get(name)
+	}
+	return obj, ok
+}
+
+func (e *Environment) Set(name string, val Object) Object {
+	// Bind the name in this environment only, shadowing any binding of the outer environments
+	e.store[name] = val
+	return val
+}
+
+// SetOutput sets where the built-in functions, like puts, print to
+// It applies to the environments enclosed by this one as well
+// For example, repl.Start sets its out, so everything is printed to the same place as the results
+func (e *Environment) SetOutput(out io.Writer) {
+	e.out = out
+}
+
+// Output returns where the built-in functions print to, os.Stdout if no output was set
+func (e *Environment) Output() io.Writer {
+	for env := e; env != nil; env = env.outer {
+		if env.out != nil {
+			return env.out
+		}
+	}
+	return os.Stdout
+}
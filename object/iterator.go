@@ -0,0 +1,44 @@
+package object
+
+// Iterator walks over the elements of a value in a for loop
+// An array gives its elements, a string its characters and a hash its keys, in the order they were added
+type Iterator struct {
+	elements []Object
+	next     int
+}
+
+// NewIterator returns an Iterator over the value, or false if the value can't be iterated over
+// For example, NewIterator of the string "größe" gives the five strings "g", "r", "ö", "ß" and "e"
+func NewIterator(obj Object) (*Iterator, bool) {
+	var elements []Object
+
+	switch obj := obj.(type) {
+	case *Array:
+		// Arrays are never changed in place, so the elements can be shared
+		elements = obj.Elements
+	case *String:
+		for _, r := range obj.Value {
+			elements = append(elements, &String{Value: string(r)})
+		}
+	case *Hash:
+		for _, key := range obj.Keys {
+			elements = append(elements, obj.Pairs[key].Key)
+		}
+	default:
+		return nil, false
+	}
+
+	return &Iterator{elements: elements}, true
+}
+
+// Next returns the next element, or false when every element was returned
+func (it *Iterator) Next() (Object, bool) {
+	if it.next >= len(it.elements) {
+		return nil, false
+	}
+	it.next += 1
+	return it.elements[it.next-1], true
+}
+
+func (it *Iterator) Type() ObjectType { return ITERATOR_OBJ }
+func (it *Iterator) Inspect() string  { return "iterator" }
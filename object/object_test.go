@@ -0,0 +1,162 @@
+package object
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestEnvironment(t *testing.T) {
+	// This is a test function for the lookup rules of the Environment
+	// Names are found in the enclosing environments, and Set only shadows them
+	outer := NewEnvironment()
+	outer.Set("x", &Integer{Value: 1})
+	outer.Set("y", &Integer{Value: 2})
+
+	inner := NewEnclosedEnvironment(outer)
+	inner.Set("x", &Integer{Value: 10})
+
+	tests := []struct {
+		env      *Environment
+		name     string
+		expected int64
+		found    bool
+	}{
+		{inner, "x", 10, true},
+		{inner, "y", 2, true},
+		{outer, "x", 1, true},
+		{inner, "z", 0, false},
+	}
+
+	for i, tt := range tests {
+		obj, ok := tt.env.Get(tt.name)
+		if ok != tt.found {
+			t.Fatalf("tests[%d] - found wrong. expected=%t, got=%t", i, tt.found, ok)
+		}
+		if !ok {
+			continue
+		}
+
+		integer, ok := obj.(*Integer)
+		if !ok {
+			t.Fatalf("tests[%d] - object is not *Integer. got=%T", i, obj)
+		}
+		if integer.Value != tt.expected {
+			t.Errorf("tests[%d] - value wrong. expected=%d, got=%d", i, tt.expected, integer.Value)
+		}
+	}
+}
+
+func TestEnvironmentDepth(t *testing.T) {
+	// This is a test function for counting the function calls of an environment
+	// A call is one deeper than its caller, whatever environment it encloses
+	global := NewEnvironment()
+	call := NewCallEnvironment(global, global)
+	nested := NewCallEnvironment(global, NewEnclosedEnvironment(call))
+
+	tests := []struct {
+		env      *Environment
+		expected int
+	}{
+		{global, 0},
+		{NewEnclosedEnvironment(global), 0},
+		{call, 1},
+		{nested, 2},
+	}
+
+	for i, tt := range tests {
+		if tt.env.Depth() != tt.expected {
+			t.Errorf("tests[%d] - wrong depth. expected=%d, got=%d", i, tt.expected, tt.env.Depth())
+		}
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	// This is a test function for printing floats
+	// A whole number keeps its fraction, so it can't be mistaken for an Integer
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{1.5, "1.5"},
+		{2, "2.0"},
+		{-3, "-3.0"},
+		{0.0015, "0.0015"},
+		{1e21, "1e+21"},
+	}
+
+	for i, tt := range tests {
+		f := &Float{Value: tt.value}
+		if f.Inspect() != tt.expected {
+			t.Errorf("tests[%d] - inspect wrong. expected=%q, got=%q", i, tt.expected, f.Inspect())
+		}
+	}
+}
+
+func TestHashKey(t *testing.T) {
+	// This is a test function for the keys of a Hash
+	// Equal values have the same key, whatever object holds them, and values of different types never do
+	tests := []struct {
+		a, b  Hashable
+		equal bool
+	}{
+		{&String{Value: "Hello World"}, &String{Value: "Hello World"}, true},
+		{&String{Value: "Hello World"}, &String{Value: "My name is johnny"}, false},
+		{&Integer{Value: 1}, &Integer{Value: 1}, true},
+		{&Integer{Value: 1}, &Integer{Value: 2}, false},
+		{&Boolean{Value: true}, &Boolean{Value: true}, true},
+		{&Boolean{Value: true}, &Boolean{Value: false}, false},
+		{&Integer{Value: 1}, &Boolean{Value: true}, false},
+		{&Integer{Value: 0}, &String{Value: ""}, false},
+	}
+
+	for i, tt := range tests {
+		if (tt.a.HashKey() == tt.b.HashKey()) != tt.equal {
+			t.Errorf("tests[%d] - %s %q and %s %q: keys equal wrong. expected=%t",
+				i, tt.a.Type(), tt.a.Inspect(), tt.b.Type(), tt.b.Inspect(), tt.equal)
+		}
+	}
+}
+
+func TestHashSet(t *testing.T) {
+	// This is a test function for setting the pairs of a Hash
+	// Setting a key again replaces its value but keeps its place
+	h := NewHash()
+	h.Set(&String{Value: "a"}, &Integer{Value: 1})
+	h.Set(&Integer{Value: 2}, &Integer{Value: 2})
+	h.Set(&String{Value: "a"}, &Integer{Value: 3})
+
+	if h.Inspect() != "{a: 3, 2: 2}" {
+		t.Errorf("inspect wrong. got=%q", h.Inspect())
+	}
+
+	if value, ok := h.Get(&String{Value: "a"}); !ok || value.Inspect() != "3" {
+		t.Errorf("Get wrong. got=%v, %t", value, ok)
+	}
+	if _, ok := h.Get(&String{Value: "b"}); ok {
+		t.Errorf("Get found a key that was never set")
+	}
+}
+
+func TestEnvironmentOutput(t *testing.T) {
+	// This is a test function for the output of the built-in functions
+	// An enclosed environment prints to the output of the environment it is enclosed by
+	var out bytes.Buffer
+
+	outer := NewEnvironment()
+	if outer.Output() != os.Stdout {
+		t.Errorf("default output is not os.Stdout")
+	}
+
+	outer.SetOutput(&out)
+	inner := NewEnclosedEnvironment(NewEnclosedEnvironment(outer))
+
+	if inner.Output() != &out {
+		t.Errorf("enclosed environment does not print to the outer output")
+	}
+
+	GetBuiltinByName("puts").Fn(inner.Output(), &String{Value: "hi"}, &Integer{Value: 1})
+	if out.String() != "hi\n1\n" {
+		t.Errorf("puts output wrong. got=%q", out.String())
+	}
+}
@@ -0,0 +1,228 @@
+package optimizer
+
+import (
+	"interpreter/ast"
+	"interpreter/object"
+)
+
+// countBindings counts how many times every name is bound in the program
+func (o *optimizer) countBindings(program *ast.Program) {
+	walk(program, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.LetStatement:
+			o.bindings[n.Name.Value]++
+		case *ast.ForStatement:
+			o.bindings[n.Variable.Value]++
+		case *ast.FunctionLiteral:
+			for _, p := range n.Parameters {
+				o.bindings[p.Value]++
+			}
+		}
+		return true
+	})
+}
+
+// inlineBody returns the expression a function consists of, or nil if it has more than one statement
+// For example, the body of "fn(x) { x * x }" or "fn(x) { return x * x; }" is "x * x"
+func inlineBody(fn *ast.FunctionLiteral) ast.Expression {
+	if len(fn.Body.Statements) != 1 {
+		return nil
+	}
+	switch s := fn.Body.Statements[0].(type) {
+	case *ast.ExpressionStatement:
+		return s.Expression
+	case *ast.ReturnStatement:
+		return s.ReturnValue
+	}
+	return nil
+}
+
+// canInline tells whether the calls of a function bound by a let of the main program can be replaced by its body
+// The body has to be a small expression, that binds no names and refers to nothing but its parameters,
+// the names bound once before the function, and the built-in functions that are not shadowed
+// So a name in the body refers to the same value at the call as in the function,
+// and the function can't call itself, as its own name is not bound yet
+func (o *optimizer) canInline(fn *ast.FunctionLiteral) bool {
+	body := inlineBody(fn)
+	if body == nil {
+		return false
+	}
+
+	parameters := map[string]bool{}
+	for _, p := range fn.Parameters {
+		if parameters[p.Value] {
+			return false
+		}
+		parameters[p.Value] = true
+	}
+
+	size := 0
+	ok := true
+	walk(body, func(node ast.Node) bool {
+		size++
+
+		switch n := node.(type) {
+		case *ast.Identifier:
+			if !parameters[n.Value] && !o.globals[n.Value] &&
+				(o.bindings[n.Value] != 0 || object.GetBuiltinByName(n.Value) == nil) {
+				ok = false
+			}
+		case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral, *ast.Boolean,
+			*ast.PrefixExpression, *ast.InfixExpression, *ast.InterpolatedString,
+			*ast.CallExpression, *ast.ArrayLiteral, *ast.HashLiteral, *ast.IndexExpression:
+		default:
+			// An if or a function literal has statements of its own, which may bind names
+			ok = false
+		}
+		return ok
+	})
+
+	return ok && size <= MaxInlineSize
+}
+
+// inline replaces a call of an inlinable function by its body, with the parameters replaced by the arguments
+// The arguments have to be constants or names that are surely bound, as they are evaluated where the parameters are used:
+// they may then be evaluated several times, or not at all, and in another order, which is only safe without effects or errors
+// For example, with "let square = fn(x) { x * x };", "square(y)" becomes "y * y"
+func (o *optimizer) inline(call *ast.CallExpression) (ast.Expression, bool) {
+	name, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return nil, false
+	}
+	fn, ok := o.inlinable[name.Value]
+	if !ok || len(call.Arguments) != len(fn.Parameters) {
+		return nil, false
+	}
+
+	arguments := map[string]ast.Expression{}
+	for i, argument := range call.Arguments {
+		if ident, isIdent := argument.(*ast.Identifier); !isConstant(argument) && !(isIdent && o.isBound(ident.Value)) {
+			return nil, false
+		}
+		arguments[fn.Parameters[i].Value] = argument
+	}
+
+	// The arguments may make constants of the operators of the body, e.g. "square(3)" becomes "3 * 3" and then "9"
+	return o.expression(substitute(inlineBody(fn), arguments)), true
+}
+
+// substitute returns a copy of an expression with the identifiers in arguments replaced by their argument
+// The copy is made so the optimizer can go on rewriting it without changing the function it comes from
+func substitute(expression ast.Expression, arguments map[string]ast.Expression) ast.Expression {
+	switch e := expression.(type) {
+	case *ast.Identifier:
+		if argument, ok := arguments[e.Value]; ok {
+			return argument
+		}
+		return e
+
+	case *ast.PrefixExpression:
+		return &ast.PrefixExpression{Token: e.Token, Operator: e.Operator, Right: substitute(e.Right, arguments)}
+
+	case *ast.InfixExpression:
+		return &ast.InfixExpression{
+			Token:    e.Token,
+			Left:     substitute(e.Left, arguments),
+			Operator: e.Operator,
+			Right:    substitute(e.Right, arguments),
+		}
+
+	case *ast.InterpolatedString:
+		return &ast.InterpolatedString{Token: e.Token, Texts: e.Texts, Expressions: substituteAll(e.Expressions, arguments)}
+
+	case *ast.CallExpression:
+		return &ast.CallExpression{
+			Token:     e.Token,
+			Function:  substitute(e.Function, arguments),
+			Arguments: substituteAll(e.Arguments, arguments),
+		}
+
+	case *ast.ArrayLiteral:
+		return &ast.ArrayLiteral{Token: e.Token, Elements: substituteAll(e.Elements, arguments)}
+
+	case *ast.HashLiteral:
+		pairs := make([]ast.HashPair, len(e.Pairs))
+		for i, pair := range e.Pairs {
+			pairs[i] = ast.HashPair{Key: substitute(pair.Key, arguments), Value: substitute(pair.Value, arguments)}
+		}
+		return &ast.HashLiteral{Token: e.Token, Pairs: pairs}
+
+	case *ast.IndexExpression:
+		return &ast.IndexExpression{Token: e.Token, Left: substitute(e.Left, arguments), Index: substitute(e.Index, arguments)}
+	}
+
+	// The literals are never rewritten, so they can be shared
+	return expression
+}
+
+func substituteAll(expressions []ast.Expression, arguments map[string]ast.Expression) []ast.Expression {
+	result := make([]ast.Expression, len(expressions))
+	for i, e := range expressions {
+		result[i] = substitute(e, arguments)
+	}
+	return result
+}
+
+// walk calls visit for the node and, as long as visit returns true, for every node inside it
+func walk(node ast.Node, visit func(ast.Node) bool) {
+	if node == nil || !visit(node) {
+		return
+	}
+
+	switch n := node.(type) {
+	case *ast.Program:
+		for _, s := range n.Statements {
+			walk(s, visit)
+		}
+	case *ast.BlockStatement:
+		for _, s := range n.Statements {
+			walk(s, visit)
+		}
+	case *ast.LetStatement:
+		walk(n.Value, visit)
+	case *ast.ReturnStatement:
+		walk(n.ReturnValue, visit)
+	case *ast.ExpressionStatement:
+		walk(n.Expression, visit)
+	case *ast.WhileStatement:
+		walk(n.Condition, visit)
+		walk(n.Body, visit)
+	case *ast.ForStatement:
+		walk(n.Iterable, visit)
+		walk(n.Body, visit)
+	case *ast.PrefixExpression:
+		walk(n.Right, visit)
+	case *ast.InfixExpression:
+		walk(n.Left, visit)
+		walk(n.Right, visit)
+	case *ast.InterpolatedString:
+		for _, e := range n.Expressions {
+			walk(e, visit)
+		}
+	case *ast.IfExpression:
+		walk(n.Condition, visit)
+		walk(n.Consequence, visit)
+		if n.Alternative != nil {
+			walk(n.Alternative, visit)
+		}
+	case *ast.FunctionLiteral:
+		walk(n.Body, visit)
+	case *ast.CallExpression:
+		walk(n.Function, visit)
+		for _, a := range n.Arguments {
+			walk(a, visit)
+		}
+	case *ast.ArrayLiteral:
+		for _, e := range n.Elements {
+			walk(e, visit)
+		}
+	case *ast.HashLiteral:
+		for _, pair := range n.Pairs {
+			walk(pair.Key, visit)
+			walk(pair.Value, visit)
+		}
+	case *ast.IndexExpression:
+		walk(n.Left, visit)
+		walk(n.Index, visit)
+	}
+}
@@ -0,0 +1,344 @@
+// Package optimizer rewrites the AST of a program into one that gives the same results with less work at runtime
+package optimizer
+
+import (
+	"interpreter/ast"
+	"interpreter/evaluator"
+	"interpreter/object"
+	"interpreter/token"
+)
+
+// MaxInlineSize is how many nodes the body of a function can have at most to be inlined
+const MaxInlineSize = 20
+
+// Optimize rewrites the program in place:
+//   - Operators on constants are computed, e.g. "2 * 3" becomes "6", unless they fail like "1 / 0"
+//   - An if with a constant condition is replaced by the branch it takes
+//   - The statements after a return, a break or a continue are removed, as they never run
+//   - A call of a small function is replaced by its body, e.g. "square(3)" becomes "3 * 3" and then "9"
+//
+// The optimized program gives the same results and prints the same output as the original one
+// Only whole programs can be optimized, not the chunks of a REPL, as the functions are inlined
+// knowing that their name is never bound to anything else in the program
+func Optimize(program *ast.Program) {
+	o := &optimizer{
+		bindings:  map[string]int{},
+		globals:   map[string]bool{},
+		inlinable: map[string]*ast.FunctionLiteral{},
+	}
+	o.countBindings(program)
+
+	o.scopes = []map[string]bool{{}}
+	program.Statements = o.statements(program.Statements)
+}
+
+type optimizer struct {
+	// How many times every name is bound anywhere in the program, by let, as a parameter or as a loop variable
+	bindings map[string]int
+	// The names bound once in the whole program, by a let of the main program seen so far
+	// They refer to the same value wherever they are used after their let
+	globals map[string]bool
+	// The functions whose calls are replaced by their body, by the name they are bound to
+	inlinable map[string]*ast.FunctionLiteral
+
+	// The names that are surely bound at the current point of the program, innermost block last
+	// A name bound in a block is forgotten at its end, as the block might not have run
+	scopes []map[string]bool
+}
+
+func (o *optimizer) enterScope(names ...*ast.Identifier) {
+	scope := map[string]bool{}
+	for _, name := range names {
+		scope[name.Value] = true
+	}
+	o.scopes = append(o.scopes, scope)
+}
+
+func (o *optimizer) leaveScope() {
+	o.scopes = o.scopes[:len(o.scopes)-1]
+}
+
+// isBound tells whether looking up the name surely succeeds at the current point of the program
+func (o *optimizer) isBound(name string) bool {
+	for _, scope := range o.scopes {
+		if scope[name] {
+			return true
+		}
+	}
+	return o.bindings[name] == 0 && object.GetBuiltinByName(name) != nil
+}
+
+func (o *optimizer) block(block *ast.BlockStatement, names ...*ast.Identifier) {
+	if block == nil {
+		return
+	}
+	o.enterScope(names...)
+	block.Statements = o.statements(block.Statements)
+	o.leaveScope()
+}
+
+func (o *optimizer) statements(statements []ast.Statement) []ast.Statement {
+	var result []ast.Statement
+
+	for i, statement := range statements {
+		statement = o.statement(statement)
+
+		// An if that is decided is replaced by the statements of its branch, as a block has no scope of its own
+		// The last statement gives the value of the block, so it is only replaced when the value stays the same
+		if block, ok := decidedBranch(statement); ok && (i < len(statements)-1 || endsWithValue(block)) {
+			for _, s := range block.Statements {
+				result = append(result, s)
+				if isJump(s) {
+					return result
+				}
+			}
+			continue
+		}
+
+		result = append(result, statement)
+		if isJump(statement) {
+			// Nothing after a return, a break or a continue can run
+			// For example, "return x; puts(x);" becomes "return x;"
+			return result
+		}
+	}
+
+	return result
+}
+
+func (o *optimizer) statement(statement ast.Statement) ast.Statement {
+	switch s := statement.(type) {
+	case *ast.LetStatement:
+		s.Value = o.expression(s.Value)
+
+		// The statements of the main program run in order, and only once
+		if len(o.scopes) == 1 && o.bindings[s.Name.Value] == 1 {
+			if fn, ok := s.Value.(*ast.FunctionLiteral); ok && o.canInline(fn) {
+				o.inlinable[s.Name.Value] = fn
+			}
+			o.globals[s.Name.Value] = true
+		}
+		o.scopes[len(o.scopes)-1][s.Name.Value] = true
+
+	case *ast.ReturnStatement:
+		s.ReturnValue = o.expression(s.ReturnValue)
+
+	case *ast.ExpressionStatement:
+		s.Expression = o.expression(s.Expression)
+
+	case *ast.WhileStatement:
+		s.Condition = o.expression(s.Condition)
+		o.block(s.Body)
+
+	case *ast.ForStatement:
+		s.Iterable = o.expression(s.Iterable)
+		o.block(s.Body, s.Variable)
+
+	case *ast.BlockStatement:
+		o.block(s)
+	}
+
+	return statement
+}
+
+func (o *optimizer) expression(expression ast.Expression) ast.Expression {
+	switch e := expression.(type) {
+	case *ast.PrefixExpression:
+		e.Right = o.expression(e.Right)
+		return fold(e)
+
+	case *ast.InfixExpression:
+		e.Left = o.expression(e.Left)
+		e.Right = o.expression(e.Right)
+
+		// The right side of && and || is never evaluated when the left side decides the result
+		// For example, "false && f()" is false without calling f
+		if truthy, ok := constantTruthiness(e.Left); ok {
+			if e.Operator == "&&" && !truthy {
+				return newBoolean(false, e.Pos())
+			}
+			if e.Operator == "||" && truthy {
+				return newBoolean(true, e.Pos())
+			}
+		}
+		return fold(e)
+
+	case *ast.InterpolatedString:
+		for i := range e.Expressions {
+			e.Expressions[i] = o.expression(e.Expressions[i])
+		}
+		return fold(e)
+
+	case *ast.IfExpression:
+		return o.ifExpression(e)
+
+	case *ast.FunctionLiteral:
+		o.block(e.Body, e.Parameters...)
+
+	case *ast.CallExpression:
+		e.Function = o.expression(e.Function)
+		for i := range e.Arguments {
+			e.Arguments[i] = o.expression(e.Arguments[i])
+		}
+		if inlined, ok := o.inline(e); ok {
+			return inlined
+		}
+
+	case *ast.ArrayLiteral:
+		for i := range e.Elements {
+			e.Elements[i] = o.expression(e.Elements[i])
+		}
+
+	case *ast.HashLiteral:
+		for i := range e.Pairs {
+			e.Pairs[i].Key = o.expression(e.Pairs[i].Key)
+			e.Pairs[i].Value = o.expression(e.Pairs[i].Value)
+		}
+
+	case *ast.IndexExpression:
+		e.Left = o.expression(e.Left)
+		e.Index = o.expression(e.Index)
+	}
+
+	return expression
+}
+
+func (o *optimizer) ifExpression(ie *ast.IfExpression) ast.Expression {
+	ie.Condition = o.expression(ie.Condition)
+	o.block(ie.Consequence)
+	o.block(ie.Alternative)
+
+	truthy, ok := constantTruthiness(ie.Condition)
+	if !ok {
+		return ie
+	}
+
+	// Keep only the branch that is taken, and replace the if by it when it is a single expression
+	// For example, "if (1 < 2) { 10 } else { 20 }" becomes "10"
+	// Without an else branch, "if (false) { ... }" still evaluates to null, so only its body is removed
+	branch := ie.Alternative
+	if truthy {
+		branch = ie.Consequence
+	}
+	if branch == nil {
+		ie.Consequence = &ast.BlockStatement{Token: ie.Consequence.Token}
+		return ie
+	}
+	if len(branch.Statements) == 1 {
+		if es, ok := branch.Statements[0].(*ast.ExpressionStatement); ok {
+			return es.Expression
+		}
+	}
+
+	ie.Condition = newBoolean(true, ie.Condition.Pos())
+	ie.Consequence = branch
+	ie.Alternative = nil
+	return ie
+}
+
+// decidedBranch returns the branch of an if statement with a constant condition
+// The branch is empty when the condition is falsy and there is no else branch
+func decidedBranch(statement ast.Statement) (*ast.BlockStatement, bool) {
+	es, ok := statement.(*ast.ExpressionStatement)
+	if !ok {
+		return nil, false
+	}
+	ie, ok := es.Expression.(*ast.IfExpression)
+	if !ok {
+		return nil, false
+	}
+	truthy, ok := constantTruthiness(ie.Condition)
+	if !ok {
+		return nil, false
+	}
+
+	switch {
+	case truthy:
+		return ie.Consequence, true
+	case ie.Alternative != nil:
+		return ie.Alternative, true
+	default:
+		return &ast.BlockStatement{}, true
+	}
+}
+
+// endsWithValue tells whether the last statement of the block gives its value, or leaves it
+func endsWithValue(block *ast.BlockStatement) bool {
+	if len(block.Statements) == 0 {
+		return false
+	}
+	switch block.Statements[len(block.Statements)-1].(type) {
+	case *ast.ExpressionStatement, *ast.ReturnStatement, *ast.BranchStatement:
+		return true
+	}
+	return false
+}
+
+func isJump(statement ast.Statement) bool {
+	switch statement.(type) {
+	case *ast.ReturnStatement, *ast.BranchStatement:
+		return true
+	}
+	return false
+}
+
+func isConstant(expression ast.Expression) bool {
+	switch expression.(type) {
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral, *ast.Boolean:
+		return true
+	}
+	return false
+}
+
+// constantTruthiness tells whether a constant is truthy, ok is false if the expression is not a constant
+// Only false is falsy among the constants, as there is no literal for null
+func constantTruthiness(expression ast.Expression) (truthy bool, ok bool) {
+	if !isConstant(expression) {
+		return false, false
+	}
+	if b, isBoolean := expression.(*ast.Boolean); isBoolean {
+		return b.Value, true
+	}
+	return true, true
+}
+
+// fold computes an operator whose operands are all constants, and returns the result as a literal
+// The operator is computed by the evaluator, so the result is exactly the one it would give at runtime
+// When the operator fails, e.g. "1 / 0", the expression is kept so the error still happens at runtime
+func fold(expression ast.Expression) ast.Expression {
+	var operands []ast.Expression
+	switch e := expression.(type) {
+	case *ast.PrefixExpression:
+		operands = []ast.Expression{e.Right}
+	case *ast.InfixExpression:
+		operands = []ast.Expression{e.Left, e.Right}
+	case *ast.InterpolatedString:
+		operands = e.Expressions
+	}
+	for _, operand := range operands {
+		if !isConstant(operand) {
+			return expression
+		}
+	}
+
+	pos := expression.Pos()
+	switch result := evaluator.Eval(expression, object.NewEnvironment()).(type) {
+	case *object.Integer:
+		return &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: result.Inspect(), Pos: pos}, Value: result.Value}
+	case *object.Float:
+		return &ast.FloatLiteral{Token: token.Token{Type: token.FLOAT, Literal: result.Inspect(), Pos: pos}, Value: result.Value}
+	case *object.String:
+		return &ast.StringLiteral{Token: token.Token{Type: token.STRING, Literal: result.Value, Pos: pos}, Value: result.Value}
+	case *object.Boolean:
+		return newBoolean(result.Value, pos)
+	default:
+		return expression
+	}
+}
+
+func newBoolean(value bool, pos token.Pos) *ast.Boolean {
+	if value {
+		return &ast.Boolean{Token: token.Token{Type: token.TRUE, Literal: "true", Pos: pos}, Value: true}
+	}
+	return &ast.Boolean{Token: token.Token{Type: token.FALSE, Literal: "false", Pos: pos}, Value: false}
+}
@@ -0,0 +1,236 @@
+package optimizer
+
+import (
+	"bytes"
+	"interpreter/ast"
+	"interpreter/compiler"
+	"interpreter/evaluator"
+	"interpreter/lexer"
+	"interpreter/object"
+	"interpreter/parser"
+	"interpreter/vm"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
+
+func TestOptimize(t *testing.T) {
+	// This is a test function for the rewritten programs, printed back as source code
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// Constant folding
+		{"1 + 2 * 3", "7"},
+		{"-(2 - 5)", "3"},
+		{"!(1 < 2)", "false"},
+		{"1.5 * 2", "3.0"},
+		{`"a" + "b"`, `"ab"`},
+		{`"${1 + 1} apples"`, `"2 apples"`},
+		{"5 < 10 > 5", "(true > 5)"},
+		{"x + 1 * 2", "(x + 2)"},
+		{"1 / 0", "(1 / 0)"},
+		{"false && f()", "false"},
+		{"true || f()", "true"},
+		{"true && f()", "(true && f())"},
+
+		// Branch pruning
+		{"if (1 < 2) { 10 } else { 20 }", "10"},
+		{"if (false) { 10 } else { 20 }", "20"},
+		{"if (false) { puts(1) }", "iffalse "},
+		{"if (false) { puts(1) }; 5", "5"},
+		{"if (true) { let a = 1; puts(a) }; a", "let a = 1;puts(a)a"},
+		{"let x = if (true) { let a = 1; a };", "let x = iftrue let a = 1;a;"},
+		{"if (true) { let a = 1; }", "iftrue let a = 1;"},
+		{"if (x) { 1 } else { 2 }", "ifx 1else 2"},
+
+		// Dead code after return
+		{"let f = fn() { return 1; puts(2); 3 };", "let f = fn() return 1;;"},
+		{"while (x) { break; puts(1); }", "while x break;"},
+		{"if (true) { return 1; } puts(2);", "return 1;"},
+
+		// Inlining
+		{"let square = fn(x) { x * x }; square(3)", "let square = fn(x) (x * x);9"},
+		{"let square = fn(x) { x * x }; let y = 2; square(y)", "let square = fn(x) (x * x);let y = 2;(y * y)"},
+		{"let add = fn(a, b) { return a + b; }; add(1, 2)", "let add = fn(a, b) return (a + b);;3"},
+		{"let one = 1; let inc = fn(x) { x + one }; inc(5)", "let one = 1;let inc = fn(x) (x + one);(5 + one)"},
+		{"let f = fn(x) { len(x) }; f(\"abc\")", `let f = fn(x) len(x);len("abc")`},
+		{"let f = fn(x) { x }; let g = fn(y) { f(y) + 1 }; g(1)", "let f = fn(x) x;let g = fn(y) (y + 1);2"},
+		// Not inlined: an argument with effects, an unbound name, recursion, a rebound name,
+		// a name defined after the function, a shadowed built-in function and a body with statements
+		{"let f = fn(x) { x }; f(puts(1))", "let f = fn(x) x;f(puts(1))"},
+		{"let f = fn(x) { x }; f(y)", "let f = fn(x) x;f(y)"},
+		{"let f = fn(x) { f(x) }; f(1)", "let f = fn(x) f(x);f(1)"},
+		{"let f = fn(x) { x }; let f = 2; f(1)", "let f = fn(x) x;let f = 2;f(1)"},
+		{"let f = fn(x) { g(x) }; let g = fn(x) { x }; f(1)", "let f = fn(x) g(x);let g = fn(x) x;f(1)"},
+		{"let f = fn(x) { len(x) }; let g = fn(len) { f(len) };", "let f = fn(x) len(x);let g = fn(len) f(len);"},
+		{"let f = fn(x) { let y = x; y }; f(1)", "let f = fn(x) let y = x;y;f(1)"},
+		{"f(1); let f = fn(x) { x };", "f(1)let f = fn(x) x;"},
+		{"let f = fn(x, y) { x }; f(1)", "let f = fn(x, y) x;f(1)"},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		Optimize(program)
+
+		if program.String() != tt.expected {
+			t.Errorf("%q - wrong program. want=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+// semanticsPrograms are run with and without optimizing them, to check that they give the same results
+// The first ones are the samples in lexer_test.go, without the line "!-/ *5;" that is not a valid expression
+var semanticsPrograms = []string{
+	`let five = 5;
+	let ten = 10;
+	let add = fn(x, y) {
+	x + y;
+	};
+	let result = add(five, ten);
+	5 < 10 > 5;
+
+	if (5 < 10) {
+		return true;
+	} else {
+		return false;
+	}
+	10 == 10;
+	10 != 9;
+	`,
+	`let five = 5;
+	let add = fn(x, y) {
+	x + y;
+	};
+	add(five, 10)`,
+	`let add = fn(x, y) { x + y; }; 10 / 2`,
+	`let square = fn(x) { x * x };
+	let sum = fn(a, b) { a + b };
+	let results = [];
+	for (i in [1, 2, 3]) {
+		if (i == 2) { continue; puts("never"); }
+		let results = push(results, sum(square(i), square(2 + 1)));
+	}
+	puts(results);
+	results`,
+	`let fib = fn(n) { if (n < 2) { return n; } fib(n - 1) + fib(n - 2) };
+	let big = 2 * 10 - 5;
+	if (big > 10) { puts("big ${big}"); } else { puts("small"); }
+	fib(big)`,
+	`let greet = fn(name) { "Hello ${name}!" };
+	let name = "Monkey";
+	puts(greet(name), greet("World"));
+	let i = 0;
+	while (true) {
+		let i = i + 1;
+		if (i > 3 * 2) { break; }
+	}
+	i`,
+	`let f = fn(x) { if (true) { let y = x * 2; } y };
+	f(21)`,
+	`let check = fn(x) { x > 1 && x < 3 || false && puts("never") };
+	[check(0), check(2), {"key": 1 + 1}["key"], "abc"[1 - 1], if (1 > 2) { 1 }]`,
+	`let div = fn(a, b) { a / b }; div(1, 2 - 2)`,
+	`puts("before"); return 1 + 1; puts("after")`,
+}
+
+func TestSemanticsUnchanged(t *testing.T) {
+	// This is a test function for running the programs optimized and not, with the evaluator and the stack vm
+	// Both must print the same output and give the same result or the same error
+	for i, input := range semanticsPrograms {
+		evalResult, evalOut := evaluate(t, input, false)
+		optimizedResult, optimizedOut := evaluate(t, input, true)
+		if evalResult != optimizedResult || evalOut != optimizedOut {
+			t.Errorf("programs[%d] - evaluator: optimized program differs.\nwant=%q, printed %q\ngot=%q, printed %q",
+				i, evalResult, evalOut, optimizedResult, optimizedOut)
+		}
+
+		vmResult, vmOut := runVM(t, input, false)
+		optimizedResult, optimizedOut = runVM(t, input, true)
+		if vmResult != optimizedResult || vmOut != optimizedOut {
+			t.Errorf("programs[%d] - vm: optimized program differs.\nwant=%q, printed %q\ngot=%q, printed %q",
+				i, vmResult, vmOut, optimizedResult, optimizedOut)
+		}
+	}
+}
+
+func evaluate(t *testing.T, input string, optimize bool) (string, string) {
+	program := parse(t, input)
+	if optimize {
+		Optimize(program)
+	}
+
+	var out bytes.Buffer
+	env := object.NewEnvironment()
+	env.SetOutput(&out)
+
+	result := evaluator.Eval(program, env)
+	if result == nil {
+		return "", out.String()
+	}
+	return result.Inspect(), out.String()
+}
+
+func runVM(t *testing.T, input string, optimize bool) (string, string) {
+	program := parse(t, input)
+	if optimize {
+		Optimize(program)
+	}
+
+	c := compiler.New()
+	if err := c.Compile(program); err != nil {
+		return "compiler error: " + err.Error(), ""
+	}
+
+	var out bytes.Buffer
+	machine := vm.New(c.Bytecode())
+	machine.SetOutput(&out)
+	if err := machine.Run(); err != nil {
+		return "ERROR: " + err.Error(), out.String()
+	}
+	return machine.LastPoppedStackElem().Inspect(), out.String()
+}
+
+func TestOptimizeCounts(t *testing.T) {
+	// This is a test function for the work the optimizations save at runtime
+	// The sample "5 < 10 > 5" from lexer_test.go compares only once instead of twice
+	input := "let square = fn(x) { x * x }; if (5 < 10) { square(2 + 1) } else { 0 }"
+
+	counts := func(optimize bool) map[string]int {
+		program := parse(t, input)
+		if optimize {
+			Optimize(program)
+		}
+		c := compiler.New()
+		if err := c.Compile(program); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		machine := vm.New(c.Bytecode())
+		if err := machine.Run(); err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+		return machine.OpcodeCounts()
+	}
+
+	original, optimized := counts(false), counts(true)
+	for _, op := range []string{"OpLessThan", "OpCall", "OpMul", "OpAdd", "OpJumpNotTruthy"} {
+		if original[op] == 0 || optimized[op] != 0 {
+			t.Errorf("%s - not optimized away. original=%d, optimized=%d", op, original[op], optimized[op])
+		}
+	}
+
+	program := parse(t, "5 < 10 > 5")
+	Optimize(program)
+	if program.String() != "(true > 5)" {
+		t.Errorf("wrong program. want=%q, got=%q", "(true > 5)", program.String())
+	}
+}
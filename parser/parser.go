@@ -0,0 +1,753 @@
+package parser
+
+import (
+	"fmt"
+	"interpreter/ast"
+	"interpreter/lexer"
+	"interpreter/token"
+	"strconv"
+	"strings"
+)
+
+// The precedences of the operators, from the lowest to the highest, as defined by the token package
+const (
+	LOWEST      = token.LowestPrec
+	OR          = token.OrPrec          // ||
+	AND         = token.AndPrec         // &&
+	EQUALS      = token.EqualsPrec      // ==
+	LESSGREATER = token.LessGreaterPrec // > or <
+	SUM         = token.SumPrec         // +
+	PRODUCT     = token.ProductPrec     // *
+	PREFIX      = token.PrefixPrec      // -X or !X
+	POWER       = token.PowerPrec       // **
+	CALL        = token.CallPrec        // myFunction(X)
+	INDEX       = token.IndexPrec       // array[index]
+)
+
+type (
+	prefixParseFn func() ast.Expression
+	infixParseFn  func(ast.Expression) ast.Expression
+)
+
+type Parser struct {
+	l      *lexer.Lexer
+	errors []string
+
+	// The current token under examination and the one after it
+	curToken  token.Token
+	peekToken token.Token
+
+	// The errors the lexer found while reading the current token and the one after it
+	// For an ILLEGAL token they are reported instead of a generic parse error
+	curLexErrors  []*lexer.Error
+	peekLexErrors []*lexer.Error
+
+	prefixParseFns map[token.TokenType]prefixParseFn
+	infixParseFns  map[token.TokenType]infixParseFn
+
+	// The number of loops around the current token, within the current function
+	// A break or a continue is only allowed inside a loop
+	loopDepth int
+}
+
+func New(l *lexer.Lexer) *Parser {
+	// Create a new Parser instance reading tokens from the given Lexer
+	// and register the parsing functions for every token type that can start or continue an expression
+	p := &Parser{
+		l:      l,
+		errors: []string{},
+	}
+
+	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
+	p.registerPrefix(token.IDENT, p.parseIdentifier)
+	for op := range compoundOperators {
+		p.registerPrefix(op, p.parseMisplacedAssignment)
+	}
+	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
+	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.STRING_PART, p.parseInterpolatedString)
+	p.registerPrefix(token.BANG, p.parsePrefixExpression)
+	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefix(token.TRUE, p.parseBoolean)
+	p.registerPrefix(token.FALSE, p.parseBoolean)
+	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
+	p.registerPrefix(token.IF, p.parseIfExpression)
+	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
+	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+
+	p.infixParseFns = make(map[token.TokenType]infixParseFn)
+	p.registerInfix(token.PLUS, p.parseInfixExpression)
+	p.registerInfix(token.MINUS, p.parseInfixExpression)
+	p.registerInfix(token.SLASH, p.parseInfixExpression)
+	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
+	p.registerInfix(token.EQ, p.parseInfixExpression)
+	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.LT, p.parseInfixExpression)
+	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.LT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.GT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
+	p.registerInfix(token.POWER, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
+	p.registerInfix(token.LPAREN, p.parseCallExpression)
+	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+
+	// Read two tokens, so curToken and peekToken are both set
+	p.nextToken()
+	p.nextToken()
+
+	return p
+}
+
+// Errors returns the messages of every parse error encountered so far
+func (p *Parser) Errors() []string {
+	return p.errors
+}
+
+func (p *Parser) registerPrefix(tokenType token.TokenType, fn prefixParseFn) {
+	p.prefixParseFns[tokenType] = fn
+}
+
+func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
+	p.infixParseFns[tokenType] = fn
+}
+
+func (p *Parser) nextToken() {
+	p.curToken = p.peekToken
+	p.curLexErrors = p.peekLexErrors
+
+	seen := len(p.l.Errors())
+	p.peekToken = p.l.NextToken()
+
+	// Comments are only kept by the lexer for tools like formatters, they have no meaning to the parser
+	for p.peekToken.Type == token.COMMENT {
+		p.peekToken = p.l.NextToken()
+	}
+	p.peekLexErrors = p.l.Errors()[seen:]
+}
+
+func (p *Parser) curTokenIs(t token.TokenType) bool {
+	return p.curToken.Type == t
+}
+
+func (p *Parser) peekTokenIs(t token.TokenType) bool {
+	return p.peekToken.Type == t
+}
+
+func (p *Parser) expectPeek(t token.TokenType) bool {
+	// Advance only if the next token has the expected type
+	// For example, after "let" we expect an identifier, so "let 5" records an error and stops
+	if p.peekTokenIs(t) {
+		p.nextToken()
+		return true
+	}
+	p.peekError(t)
+	return false
+}
+
+func (p *Parser) peekError(t token.TokenType) {
+	if p.lexError(p.peekToken, p.peekLexErrors) {
+		return
+	}
+	msg := fmt.Sprintf("expected next token to be %s, got %s instead",
+		t, p.peekToken.Type)
+	p.errors = append(p.errors, msg)
+}
+
+func (p *Parser) noPrefixParseFnError(t token.TokenType) {
+	if p.lexError(p.curToken, p.curLexErrors) {
+		return
+	}
+	msg := fmt.Sprintf("no prefix parse function for %s found", t)
+	p.errors = append(p.errors, msg)
+}
+
+func (p *Parser) lexError(tok token.Token, errs []*lexer.Error) bool {
+	// Report why the lexer returned an ILLEGAL token, rather than that the parser did not expect it
+	// For example, for puts("a\qb") the error is "1:8: unknown escape sequence", not "no prefix parse function for ILLEGAL found"
+	if tok.Type != token.ILLEGAL || len(errs) == 0 {
+		return false
+	}
+	for _, err := range errs {
+		p.errors = append(p.errors, err.Error())
+	}
+	return true
+}
+
+func (p *Parser) peekPrecedence() int {
+	return p.peekToken.Type.Precedence()
+}
+
+func (p *Parser) curPrecedence() int {
+	return p.curToken.Type.Precedence()
+}
+
+// ParseProgram parses statements until the end of the input
+// Parse errors are collected in Errors instead of stopping the parser
+func (p *Parser) ParseProgram() *ast.Program {
+	program := &ast.Program{}
+	program.Statements = []ast.Statement{}
+
+	for !p.curTokenIs(token.EOF) {
+		stmt := p.parseStatement()
+		if stmt != nil {
+			program.Statements = append(program.Statements, stmt)
+		}
+		p.nextToken()
+	}
+
+	return program
+}
+
+func (p *Parser) parseStatement() ast.Statement {
+	switch p.curToken.Type {
+	case token.LET:
+		return p.parseLetStatement()
+	case token.RETURN:
+		return p.parseReturnStatement()
+	case token.WHILE:
+		return p.parseWhileStatement()
+	case token.FOR:
+		return p.parseForStatement()
+	case token.BREAK, token.CONTINUE:
+		return p.parseBranchStatement()
+	case token.IDENT:
+		if _, ok := compoundOperators[p.peekToken.Type]; ok {
+			return p.parseCompoundAssignment()
+		}
+		return p.parseExpressionStatement()
+	default:
+		return p.parseExpressionStatement()
+	}
+}
+
+// compoundOperators maps every compound assignment to the infix operator it applies
+var compoundOperators = map[token.TokenType]token.TokenType{
+	token.PLUS_ASSIGN:     token.PLUS,
+	token.MINUS_ASSIGN:    token.MINUS,
+	token.ASTERISK_ASSIGN: token.ASTERISK,
+	token.SLASH_ASSIGN:    token.SLASH,
+}
+
+func (p *Parser) parseCompoundAssignment() ast.Statement {
+	// Parse "<identifier> += <expression>;" as "let <identifier> = <identifier> + <expression>;"
+	// So it binds the name like let does, and every evaluator and compiler runs it without knowing about it
+	name := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	stmt := &ast.LetStatement{Token: token.Token{Type: token.LET, Literal: "let", Pos: name.Token.Pos, End: name.Token.End}, Name: name}
+
+	p.nextToken()
+
+	// The operator is the compound one without its "=", e.g. + for +=
+	operator := p.curToken
+	operator.Type = compoundOperators[operator.Type]
+	operator.Literal = strings.TrimSuffix(operator.Literal, "=")
+	infix := &ast.InfixExpression{Token: operator, Left: name, Operator: operator.Literal}
+
+	p.nextToken()
+
+	infix.Right = p.parseExpression(LOWEST)
+	stmt.Value = infix
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseMisplacedAssignment() ast.Expression {
+	// A compound assignment is a statement, so it can't be used where an expression is expected
+	// For example, "let y = x += 1;" or "1 += 2;"
+	msg := fmt.Sprintf("%s must follow a name at the start of a statement", p.curToken.Literal)
+	p.errors = append(p.errors, msg)
+	return nil
+}
+
+func (p *Parser) parseLetStatement() ast.Statement {
+	// Parse "let <identifier> = <expression>;"
+	stmt := &ast.LetStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.ASSIGN) {
+		return nil
+	}
+
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseReturnStatement() ast.Statement {
+	// Parse "return <expression>;"
+	stmt := &ast.ReturnStatement{Token: p.curToken}
+
+	p.nextToken()
+
+	stmt.ReturnValue = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseWhileStatement() ast.Statement {
+	// Parse "while (<condition>) { <body> }"
+	stmt := &ast.WhileStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	return stmt
+}
+
+func (p *Parser) parseForStatement() ast.Statement {
+	// Parse "for (<identifier> in <iterable>) { <body> }"
+	stmt := &ast.ForStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	stmt.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	return stmt
+}
+
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	// Parse the body of a loop, where break and continue are allowed
+	// The semicolon after the closing brace is optional
+	p.loopDepth += 1
+	body := p.parseBlockStatement()
+	p.loopDepth -= 1
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return body
+}
+
+func (p *Parser) parseBranchStatement() ast.Statement {
+	// Parse "break;" or "continue;"
+	stmt := &ast.BranchStatement{Token: p.curToken}
+
+	if p.loopDepth == 0 {
+		p.errors = append(p.errors, fmt.Sprintf("%s is not in a loop", p.curToken.Literal))
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseExpressionStatement() ast.Statement {
+	stmt := &ast.ExpressionStatement{Token: p.curToken}
+
+	stmt.Expression = p.parseExpression(LOWEST)
+
+	// The semicolon is optional, so "5 + 5" can be typed into the REPL
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseExpression(precedence int) ast.Expression {
+	// This is the heart of the Pratt parser
+	// Parse the prefix part of the expression first, then keep folding it into infix expressions
+	// as long as the next operator binds tighter than the precedence we were called with
+	// For example, "1 + 2 * 3" becomes "(1 + (2 * 3))", while "1 * 2 + 3" becomes "((1 * 2) + 3)"
+	prefix := p.prefixParseFns[p.curToken.Type]
+	if prefix == nil {
+		p.noPrefixParseFnError(p.curToken.Type)
+		return nil
+	}
+	leftExp := prefix()
+
+	for !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
+		infix := p.infixParseFns[p.peekToken.Type]
+		if infix == nil {
+			return leftExp
+		}
+
+		p.nextToken()
+
+		leftExp = infix(leftExp)
+	}
+
+	return leftExp
+}
+
+func (p *Parser) parseIdentifier() ast.Expression {
+	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+}
+
+func (p *Parser) parseIntegerLiteral() ast.Expression {
+	lit := &ast.IntegerLiteral{Token: p.curToken}
+
+	// A prefixed literal like "0xFF" gives its own base, everything else is decimal
+	// A leading zero alone does not mean octal, so "010" is ten
+	value, err := token.ParseInt(p.curToken.Literal)
+	if err != nil {
+		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+
+	lit.Value = value
+
+	return lit
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+
+	value, err := strconv.ParseFloat(strings.ReplaceAll(p.curToken.Literal, "_", ""), 64)
+	if err != nil {
+		msg := fmt.Sprintf("could not parse %q as float", p.curToken.Literal)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+
+	lit.Value = value
+
+	return lit
+}
+
+func (p *Parser) parseStringLiteral() ast.Expression {
+	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+}
+
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	// Parse the parts of an interpolated string, e.g. "a ${x} b"
+	// Every STRING_PART is followed by an embedded expression, and the STRING after the last one ends the string
+	str := &ast.InterpolatedString{Token: p.curToken}
+
+	for p.curTokenIs(token.STRING_PART) {
+		str.Texts = append(str.Texts, p.curToken.Literal)
+
+		if !p.expectPeek(token.INTERP_START) {
+			return nil
+		}
+		p.nextToken()
+		str.Expressions = append(str.Expressions, p.parseExpression(LOWEST))
+
+		if !p.expectPeek(token.INTERP_END) {
+			return nil
+		}
+		if p.peekTokenIs(token.STRING_PART) {
+			p.nextToken()
+		} else if !p.expectPeek(token.STRING) {
+			return nil
+		}
+	}
+
+	str.Texts = append(str.Texts, p.curToken.Literal)
+
+	return str
+}
+
+func (p *Parser) parseBoolean() ast.Expression {
+	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
+}
+
+func (p *Parser) parsePrefixExpression() ast.Expression {
+	expression := &ast.PrefixExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Literal,
+	}
+
+	p.nextToken()
+
+	expression.Right = p.parseExpression(PREFIX)
+
+	return expression
+}
+
+func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
+	expression := &ast.InfixExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Literal,
+		Left:     left,
+	}
+
+	precedence := p.curPrecedence()
+	if p.curTokenIs(token.POWER) {
+		// ** is right-associative, so "2 ** 3 ** 2" is "(2 ** (3 ** 2))"
+		precedence -= 1
+	}
+	p.nextToken()
+	expression.Right = p.parseExpression(precedence)
+
+	return expression
+}
+
+func (p *Parser) parseGroupedExpression() ast.Expression {
+	// Parentheses only raise the precedence of what they enclose, so they don't get a node of their own
+	p.nextToken()
+
+	exp := p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	return exp
+}
+
+func (p *Parser) parseIfExpression() ast.Expression {
+	// Parse "if (<condition>) { <consequence> } else { <alternative> }"
+	expression := &ast.IfExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	expression.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	expression.Consequence = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.ELSE) {
+		p.nextToken()
+
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+
+		expression.Alternative = p.parseBlockStatement()
+	}
+
+	return expression
+}
+
+func (p *Parser) parseBlockStatement() *ast.BlockStatement {
+	// Parse statements until the closing brace, or the end of the input if it is missing
+	block := &ast.BlockStatement{Token: p.curToken}
+	block.Statements = []ast.Statement{}
+
+	p.nextToken()
+
+	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+		stmt := p.parseStatement()
+		if stmt != nil {
+			block.Statements = append(block.Statements, stmt)
+		}
+		p.nextToken()
+	}
+
+	if p.curTokenIs(token.EOF) {
+		p.peekError(token.RBRACE)
+	}
+
+	return block
+}
+
+func (p *Parser) parseFunctionLiteral() ast.Expression {
+	// Parse "fn(<parameters>) { <body> }"
+	lit := &ast.FunctionLiteral{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	lit.Parameters = p.parseFunctionParameters()
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	// A break in the body can't leave a loop the function is defined in
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
+	lit.Body = p.parseBlockStatement()
+	p.loopDepth = loopDepth
+
+	return lit
+}
+
+func (p *Parser) parseFunctionParameters() []*ast.Identifier {
+	// Parse a comma separated list of identifiers, e.g. "(x, y)"
+	// A name can only be used once, as "fn(a, a)" would leave one of the arguments unreachable
+	identifiers := []*ast.Identifier{}
+
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		return identifiers
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	identifiers = append(identifiers, ident)
+
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		for _, previous := range identifiers {
+			if previous.Value == ident.Value {
+				p.errors = append(p.errors, fmt.Sprintf("duplicate parameter %s", ident.Value))
+				break
+			}
+		}
+		identifiers = append(identifiers, ident)
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	return identifiers
+}
+
+func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
+	// The "(" after an expression is treated as an infix operator with the highest precedence
+	// For example, "add(1, 2)" is the infix "(" between "add" and the argument list
+	exp := &ast.CallExpression{Token: p.curToken, Function: function}
+	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	return exp
+}
+
+func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+	// Parse a comma-separated list of expressions up to the end token
+	// It is used for the arguments of a call, ending with ")", and the elements of an array, ending with "]"
+	list := []ast.Expression{}
+
+	if p.peekTokenIs(end) {
+		p.nextToken()
+		return list
+	}
+
+	p.nextToken()
+	list = append(list, p.parseExpression(LOWEST))
+
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		p.nextToken()
+		list = append(list, p.parseExpression(LOWEST))
+	}
+
+	if !p.expectPeek(end) {
+		return nil
+	}
+
+	return list
+}
+
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	array := &ast.ArrayLiteral{Token: p.curToken}
+	array.Elements = p.parseExpressionList(token.RBRACKET)
+	return array
+}
+
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	// Like a call, the "[" after an expression is treated as an infix operator, binding even tighter
+	// For example, "a * b[2]" is parsed as "(a * (b[2]))"
+	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+
+	p.nextToken()
+	exp.Index = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	return exp
+}
+
+func (p *Parser) parseHashLiteral() ast.Expression {
+	// Parse the pairs of a hash literal, e.g. {"one": 1, "two": 2}
+	// A "{" only starts a hash where an expression is expected, elsewhere it starts a block
+	hash := &ast.HashLiteral{Token: p.curToken}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		key := p.parseExpression(LOWEST)
+
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	return hash
+}
@@ -0,0 +1,768 @@
+package parser
+
+import (
+	"fmt"
+	"interpreter/ast"
+	"interpreter/lexer"
+	"testing"
+)
+
+func TestLetStatements(t *testing.T) {
+	// This is a test function for parsing let statements
+	// It checks the bound name and the value of every statement
+	tests := []struct {
+		input              string
+		expectedIdentifier string
+		expectedValue      interface{}
+	}{
+		{"let x = 5;", "x", 5},
+		{"let y = true;", "y", true},
+		{"let foobar = y;", "foobar", "y"},
+	}
+
+	for i, tt := range tests {
+		program := parseProgram(t, tt.input)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("tests[%d] - program.Statements does not contain 1 statement. got=%d",
+				i, len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.LetStatement)
+		if !ok {
+			t.Fatalf("tests[%d] - statement is not *ast.LetStatement. got=%T", i, program.Statements[0])
+		}
+
+		if stmt.Name.Value != tt.expectedIdentifier {
+			t.Fatalf("tests[%d] - name wrong. expected=%q, got=%q",
+				i, tt.expectedIdentifier, stmt.Name.Value)
+		}
+
+		testLiteralExpression(t, stmt.Value, tt.expectedValue)
+	}
+}
+
+func TestReturnStatements(t *testing.T) {
+	// This is a test function for parsing return statements
+	tests := []struct {
+		input         string
+		expectedValue interface{}
+	}{
+		{"return 5;", 5},
+		{"return true;", true},
+		{"return foobar;", "foobar"},
+	}
+
+	for i, tt := range tests {
+		program := parseProgram(t, tt.input)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("tests[%d] - program.Statements does not contain 1 statement. got=%d",
+				i, len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ReturnStatement)
+		if !ok {
+			t.Fatalf("tests[%d] - statement is not *ast.ReturnStatement. got=%T", i, program.Statements[0])
+		}
+
+		testLiteralExpression(t, stmt.ReturnValue, tt.expectedValue)
+	}
+}
+
+func TestPrefixExpressions(t *testing.T) {
+	// This is a test function for parsing the prefix operators ! and -
+	tests := []struct {
+		input    string
+		operator string
+		value    interface{}
+	}{
+		{"!5;", "!", 5},
+		{"-15;", "-", 15},
+		{"!foobar;", "!", "foobar"},
+		{"!true;", "!", true},
+		{"!false;", "!", false},
+	}
+
+	for i, tt := range tests {
+		program := parseProgram(t, tt.input)
+		exp := singleExpression(t, program)
+
+		pe, ok := exp.(*ast.PrefixExpression)
+		if !ok {
+			t.Fatalf("tests[%d] - exp is not *ast.PrefixExpression. got=%T", i, exp)
+		}
+		if pe.Operator != tt.operator {
+			t.Fatalf("tests[%d] - operator wrong. expected=%q, got=%q", i, tt.operator, pe.Operator)
+		}
+		testLiteralExpression(t, pe.Right, tt.value)
+	}
+}
+
+func TestInfixExpressions(t *testing.T) {
+	// This is a test function for parsing every infix operator
+	tests := []struct {
+		input      string
+		leftValue  interface{}
+		operator   string
+		rightValue interface{}
+	}{
+		{"5 + 5;", 5, "+", 5},
+		{"5 - 5;", 5, "-", 5},
+		{"5 * 5;", 5, "*", 5},
+		{"5 / 5;", 5, "/", 5},
+		{"5 > 5;", 5, ">", 5},
+		{"5 < 5;", 5, "<", 5},
+		{"5 == 5;", 5, "==", 5},
+		{"5 != 5;", 5, "!=", 5},
+		{"5 <= 5;", 5, "<=", 5},
+		{"5 >= 5;", 5, ">=", 5},
+		{"5 % 5;", 5, "%", 5},
+		{"5 ** 5;", 5, "**", 5},
+		{"true && false", true, "&&", false},
+		{"true || false", true, "||", false},
+		{"foobar + barfoo;", "foobar", "+", "barfoo"},
+		{"true == true", true, "==", true},
+		{"true != false", true, "!=", false},
+	}
+
+	for _, tt := range tests {
+		program := parseProgram(t, tt.input)
+		exp := singleExpression(t, program)
+
+		testInfixExpression(t, exp, tt.leftValue, tt.operator, tt.rightValue)
+	}
+}
+
+func TestOperatorPrecedenceParsing(t *testing.T) {
+	// This is a test function for the precedence and associativity of the operators
+	// The expected output is the program printed back with explicit parentheses
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"-a * b", "((-a) * b)"},
+		{"!-a", "(!(-a))"},
+		{"a + b + c", "((a + b) + c)"},
+		{"a + b - c", "((a + b) - c)"},
+		{"a * b * c", "((a * b) * c)"},
+		{"a * b / c", "((a * b) / c)"},
+		{"a + b / c", "(a + (b / c))"},
+		{"a + b * c + d / e - f", "(((a + (b * c)) + (d / e)) - f)"},
+		{"3 + 4; -5 * 5", "(3 + 4)((-5) * 5)"},
+		{"5 > 4 == 3 < 4", "((5 > 4) == (3 < 4))"},
+		{"5 < 4 != 3 > 4", "((5 < 4) != (3 > 4))"},
+		{"3 + 4 * 5 == 3 * 1 + 4 * 5", "((3 + (4 * 5)) == ((3 * 1) + (4 * 5)))"},
+		{"true", "true"},
+		{"3 > 5 == false", "((3 > 5) == false)"},
+		{"1 + (2 + 3) + 4", "((1 + (2 + 3)) + 4)"},
+		{"(5 + 5) * 2", "((5 + 5) * 2)"},
+		{"-(5 + 5)", "(-(5 + 5))"},
+		{"!(true == true)", "(!(true == true))"},
+		{"a + add(b * c) + d", "((a + add((b * c))) + d)"},
+		{"add(a, b, 1, 2 * 3, 4 + 5, add(6, 7 * 8))", "add(a, b, 1, (2 * 3), (4 + 5), add(6, (7 * 8)))"},
+		{"add(a + b + c * d / f + g)", "add((((a + b) + ((c * d) / f)) + g))"},
+		{"a * [1, 2, 3, 4][b * c] * d", "((a * ([1, 2, 3, 4][(b * c)])) * d)"},
+		{"add(a * b[2], b[1], 2 * [1, 2][1])", "add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))"},
+		{"-a[0] ** f(x)[1]", "(-((a[0]) ** (f(x)[1])))"},
+		{"a + b % c", "(a + (b % c))"},
+		{"a <= b == b >= a", "((a <= b) == (b >= a))"},
+		{"a || b && c", "(a || (b && c))"},
+		{"a && b || c", "((a && b) || c)"},
+		{"a == b && c != d", "((a == b) && (c != d))"},
+		{"2 ** 3 ** 2", "(2 ** (3 ** 2))"},
+		{"-2 ** 2", "(-(2 ** 2))"},
+		{"a * b ** c", "(a * (b ** c))"},
+		{"f(a) ** 2", "(f(a) ** 2)"},
+	}
+
+	for i, tt := range tests {
+		program := parseProgram(t, tt.input)
+
+		if program.String() != tt.expected {
+			t.Errorf("tests[%d] - expected=%q, got=%q", i, tt.expected, program.String())
+		}
+	}
+}
+
+func TestNumberLiteralExpression(t *testing.T) {
+	// This is a test function for parsing integer literals in every base and float literals
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"5;", int64(5)},
+		{"010;", int64(10)},
+		{"0_10;", int64(10)},
+		{"0_0;", int64(0)},
+		{"1_000_000;", int64(1000000)},
+		{"0xFF;", int64(255)},
+		{"0o17;", int64(15)},
+		{"0b1010;", int64(10)},
+		{"0x_ff", nil},
+		{"3.14;", 3.14},
+		{"1.5e-3;", 0.0015},
+		{"1_000.5;", 1000.5},
+	}
+
+	for i, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		if tt.expected == nil {
+			if len(p.Errors()) == 0 {
+				t.Errorf("tests[%d] - expected a parse error for %q", i, tt.input)
+			}
+			continue
+		}
+		checkParserErrors(t, p)
+		exp := singleExpression(t, program)
+
+		switch expected := tt.expected.(type) {
+		case int64:
+			il, ok := exp.(*ast.IntegerLiteral)
+			if !ok {
+				t.Fatalf("tests[%d] - exp is not *ast.IntegerLiteral. got=%T", i, exp)
+			}
+			if il.Value != expected {
+				t.Errorf("tests[%d] - value wrong. expected=%d, got=%d", i, expected, il.Value)
+			}
+		case float64:
+			fl, ok := exp.(*ast.FloatLiteral)
+			if !ok {
+				t.Fatalf("tests[%d] - exp is not *ast.FloatLiteral. got=%T", i, exp)
+			}
+			if fl.Value != expected {
+				t.Errorf("tests[%d] - value wrong. expected=%g, got=%g", i, expected, fl.Value)
+			}
+		}
+	}
+}
+
+func TestStringLiteralExpression(t *testing.T) {
+	// This is a test function for parsing string literals
+	// The value has its escapes resolved, and String prints it back as a double-quoted string
+	tests := []struct {
+		input          string
+		expectedValue  string
+		expectedString string
+	}{
+		{`"hello world";`, "hello world", `"hello world"`},
+		{`"say \"hi\"\n"`, "say \"hi\"\n", `"say \"hi\"\n"`},
+		{"`C:\\temp`", `C:\temp`, `"C:\\temp"`},
+	}
+
+	for i, tt := range tests {
+		program := parseProgram(t, tt.input)
+		exp := singleExpression(t, program)
+
+		literal, ok := exp.(*ast.StringLiteral)
+		if !ok {
+			t.Fatalf("tests[%d] - exp is not *ast.StringLiteral. got=%T", i, exp)
+		}
+
+		if literal.Value != tt.expectedValue {
+			t.Errorf("tests[%d] - value wrong. expected=%q, got=%q", i, tt.expectedValue, literal.Value)
+		}
+
+		if literal.String() != tt.expectedString {
+			t.Errorf("tests[%d] - string wrong. expected=%q, got=%q", i, tt.expectedString, literal.String())
+		}
+	}
+}
+
+func TestIfExpression(t *testing.T) {
+	// This is a test function for parsing if expressions with and without an else branch
+	tests := []struct {
+		input          string
+		hasAlternative bool
+	}{
+		{"if (x < y) { x }", false},
+		{"if (x < y) { x } else { y }", true},
+	}
+
+	for i, tt := range tests {
+		program := parseProgram(t, tt.input)
+		exp := singleExpression(t, program)
+
+		ie, ok := exp.(*ast.IfExpression)
+		if !ok {
+			t.Fatalf("tests[%d] - exp is not *ast.IfExpression. got=%T", i, exp)
+		}
+
+		testInfixExpression(t, ie.Condition, "x", "<", "y")
+
+		if len(ie.Consequence.Statements) != 1 {
+			t.Fatalf("tests[%d] - consequence is not 1 statement. got=%d", i, len(ie.Consequence.Statements))
+		}
+		consequence, ok := ie.Consequence.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("tests[%d] - consequence is not *ast.ExpressionStatement. got=%T", i, ie.Consequence.Statements[0])
+		}
+		testIdentifier(t, consequence.Expression, "x")
+
+		if !tt.hasAlternative {
+			if ie.Alternative != nil {
+				t.Errorf("tests[%d] - alternative was not nil. got=%+v", i, ie.Alternative)
+			}
+			continue
+		}
+
+		if ie.Alternative == nil || len(ie.Alternative.Statements) != 1 {
+			t.Fatalf("tests[%d] - alternative is not 1 statement. got=%+v", i, ie.Alternative)
+		}
+		alternative, ok := ie.Alternative.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("tests[%d] - alternative is not *ast.ExpressionStatement. got=%T", i, ie.Alternative.Statements[0])
+		}
+		testIdentifier(t, alternative.Expression, "y")
+	}
+}
+
+func TestFunctionLiteralParsing(t *testing.T) {
+	// This is a test function for parsing function literals and their parameter lists
+	tests := []struct {
+		input          string
+		expectedParams []string
+		expectedBody   string
+	}{
+		{"fn() {};", []string{}, ""},
+		{"fn(x) { x };", []string{"x"}, "x"},
+		{"fn(x, y) { x + y; }", []string{"x", "y"}, "(x + y)"},
+	}
+
+	for i, tt := range tests {
+		program := parseProgram(t, tt.input)
+		exp := singleExpression(t, program)
+
+		function, ok := exp.(*ast.FunctionLiteral)
+		if !ok {
+			t.Fatalf("tests[%d] - exp is not *ast.FunctionLiteral. got=%T", i, exp)
+		}
+
+		if len(function.Parameters) != len(tt.expectedParams) {
+			t.Fatalf("tests[%d] - parameters wrong. expected=%d, got=%d",
+				i, len(tt.expectedParams), len(function.Parameters))
+		}
+
+		for j, ident := range tt.expectedParams {
+			testLiteralExpression(t, function.Parameters[j], ident)
+		}
+
+		if function.Body.String() != tt.expectedBody {
+			t.Errorf("tests[%d] - body wrong. expected=%q, got=%q", i, tt.expectedBody, function.Body.String())
+		}
+	}
+}
+
+func TestCallExpressionParsing(t *testing.T) {
+	// This is a test function for parsing call expressions and their arguments
+	tests := []struct {
+		input         string
+		expectedIdent string
+		expectedArgs  []string
+	}{
+		{"add();", "add", []string{}},
+		{"add(1);", "add", []string{"1"}},
+		{"add(1, 2 * 3, 4 + 5);", "add", []string{"1", "(2 * 3)", "(4 + 5)"}},
+	}
+
+	for i, tt := range tests {
+		program := parseProgram(t, tt.input)
+		exp := singleExpression(t, program)
+
+		call, ok := exp.(*ast.CallExpression)
+		if !ok {
+			t.Fatalf("tests[%d] - exp is not *ast.CallExpression. got=%T", i, exp)
+		}
+
+		testIdentifier(t, call.Function, tt.expectedIdent)
+
+		if len(call.Arguments) != len(tt.expectedArgs) {
+			t.Fatalf("tests[%d] - arguments wrong. expected=%d, got=%d",
+				i, len(tt.expectedArgs), len(call.Arguments))
+		}
+
+		for j, arg := range tt.expectedArgs {
+			if call.Arguments[j].String() != arg {
+				t.Errorf("tests[%d] - argument %d wrong. expected=%q, got=%q",
+					i, j, arg, call.Arguments[j].String())
+			}
+		}
+	}
+}
+
+func TestArrayLiteralParsing(t *testing.T) {
+	// This is a test function for parsing array literals
+	tests := []struct {
+		input            string
+		expectedElements []string
+	}{
+		{"[]", []string{}},
+		{"[1, 2 * 2, 3 + 3]", []string{"1", "(2 * 2)", "(3 + 3)"}},
+		{`[[1], "a", fn(x) { x }]`, []string{"[1]", `"a"`, "fn(x) x"}},
+	}
+
+	for i, tt := range tests {
+		program := parseProgram(t, tt.input)
+		exp := singleExpression(t, program)
+
+		array, ok := exp.(*ast.ArrayLiteral)
+		if !ok {
+			t.Fatalf("tests[%d] - exp is not *ast.ArrayLiteral. got=%T", i, exp)
+		}
+
+		if len(array.Elements) != len(tt.expectedElements) {
+			t.Fatalf("tests[%d] - elements wrong. expected=%d, got=%d",
+				i, len(tt.expectedElements), len(array.Elements))
+		}
+
+		for j, el := range tt.expectedElements {
+			if array.Elements[j].String() != el {
+				t.Errorf("tests[%d] - element %d wrong. expected=%q, got=%q",
+					i, j, el, array.Elements[j].String())
+			}
+		}
+	}
+}
+
+func TestIndexExpressionParsing(t *testing.T) {
+	// This is a test function for parsing index expressions
+	program := parseProgram(t, "myArray[1 + 1]")
+	exp := singleExpression(t, program)
+
+	index, ok := exp.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("exp is not *ast.IndexExpression. got=%T", exp)
+	}
+
+	testIdentifier(t, index.Left, "myArray")
+	testInfixExpression(t, index.Index, 1, "+", 1)
+}
+
+func TestHashLiteralParsing(t *testing.T) {
+	// This is a test function for parsing hash literals
+	// The pairs keep the order they are written in
+	tests := []struct {
+		input         string
+		expectedPairs [][2]string
+	}{
+		{"{}", nil},
+		{`{"one": 1, "two": 2, "three": 3}`, [][2]string{{`"one"`, "1"}, {`"two"`, "2"}, {`"three"`, "3"}}},
+		{`{true: 0 + 1, 2: 10 - 8, "a" + "b": 15 / 5,}`, [][2]string{{"true", "(0 + 1)"}, {"2", "(10 - 8)"}, {`("a" + "b")`, "(15 / 5)"}}},
+	}
+
+	for i, tt := range tests {
+		program := parseProgram(t, tt.input)
+		exp := singleExpression(t, program)
+
+		hash, ok := exp.(*ast.HashLiteral)
+		if !ok {
+			t.Fatalf("tests[%d] - exp is not *ast.HashLiteral. got=%T", i, exp)
+		}
+
+		if len(hash.Pairs) != len(tt.expectedPairs) {
+			t.Fatalf("tests[%d] - pairs wrong. expected=%d, got=%d", i, len(tt.expectedPairs), len(hash.Pairs))
+		}
+
+		for j, pair := range tt.expectedPairs {
+			if hash.Pairs[j].Key.String() != pair[0] || hash.Pairs[j].Value.String() != pair[1] {
+				t.Errorf("tests[%d] - pair %d wrong. expected=%s: %s, got=%s: %s", i, j,
+					pair[0], pair[1], hash.Pairs[j].Key.String(), hash.Pairs[j].Value.String())
+			}
+		}
+	}
+}
+
+func TestLoopStatements(t *testing.T) {
+	// This is a test function for parsing while and for loops, with break and continue in their bodies
+	// The expected output is the program printed back
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"while (i < 10) { let i = i + 1; }", "while (i < 10) let i = (i + 1);"},
+		{"while (true) { break; };", "while true break;"},
+		{"for (x in [1, 2]) { puts(x) }", "for (x in [1, 2]) puts(x)"},
+		{"for (c in \"abc\") { if (c == \"b\") { continue } c }", `for (c in "abc") if(c == "b") continue;c`},
+		{"while (a) { for (b in c) { break } continue }", "while a for (b in c) break;continue;"},
+		{"fn() { while (x) { return 1; } }", "fn() while x return 1;"},
+	}
+
+	for i, tt := range tests {
+		program := parseProgram(t, tt.input)
+
+		if program.String() != tt.expected {
+			t.Errorf("tests[%d] - program wrong. expected=%q, got=%q", i, tt.expected, program.String())
+		}
+	}
+
+	program := parseProgram(t, "for (item in items) { item; }")
+	stmt, ok := program.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("statement is not *ast.ForStatement. got=%T", program.Statements[0])
+	}
+	testIdentifier(t, stmt.Variable, "item")
+	testIdentifier(t, stmt.Iterable, "items")
+	if len(stmt.Body.Statements) != 1 {
+		t.Errorf("body has wrong number of statements. got=%d", len(stmt.Body.Statements))
+	}
+}
+
+func TestParsingSkipsComments(t *testing.T) {
+	// This is a test function for parsing with a lexer that keeps the comments
+	// The COMMENT tokens must be skipped, so the program is the same as without them
+	input := `// the answer
+let x = /* six */ 6 * 7; // times seven`
+
+	l := lexer.New(input, lexer.WithComments())
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if program.String() != "let x = (6 * 7);" {
+		t.Errorf("program wrong. got=%q", program.String())
+	}
+}
+
+func TestInterpolatedStringExpression(t *testing.T) {
+	// This is a test function for parsing strings with embedded expressions
+	// The texts have their escapes resolved, and String prints the string back with its expressions
+	tests := []struct {
+		input               string
+		expectedTexts       []string
+		expectedExpressions []string
+		expectedString      string
+	}{
+		{`"Hello ${name}, you are ${age + 1}"`, []string{"Hello ", ", you are ", ""},
+			[]string{"name", "(age + 1)"}, `"Hello ${name}, you are ${(age + 1)}"`},
+		{`"${"in ${x}"}\t\${y}"`, []string{"", "\t${y}"},
+			[]string{`"in ${x}"`}, `"${"in ${x}"}\t\${y}"`},
+		{`"${fn(x) { x * 2 }(2)}"`, []string{"", ""},
+			[]string{"fn(x) (x * 2)(2)"}, `"${fn(x) (x * 2)(2)}"`},
+	}
+
+	for i, tt := range tests {
+		program := parseProgram(t, tt.input)
+		exp := singleExpression(t, program)
+
+		str, ok := exp.(*ast.InterpolatedString)
+		if !ok {
+			t.Fatalf("tests[%d] - exp is not *ast.InterpolatedString. got=%T", i, exp)
+		}
+
+		if fmt.Sprintf("%q", str.Texts) != fmt.Sprintf("%q", tt.expectedTexts) {
+			t.Errorf("tests[%d] - texts wrong. expected=%q, got=%q", i, tt.expectedTexts, str.Texts)
+		}
+
+		if len(str.Expressions) != len(tt.expectedExpressions) {
+			t.Fatalf("tests[%d] - wrong number of expressions. expected=%d, got=%d",
+				i, len(tt.expectedExpressions), len(str.Expressions))
+		}
+		for j, e := range tt.expectedExpressions {
+			if str.Expressions[j].String() != e {
+				t.Errorf("tests[%d][%d] - expression wrong. expected=%q, got=%q", i, j, e, str.Expressions[j].String())
+			}
+		}
+
+		if str.String() != tt.expectedString {
+			t.Errorf("tests[%d] - string wrong. expected=%q, got=%q", i, tt.expectedString, str.String())
+		}
+	}
+}
+
+func TestCompoundAssignments(t *testing.T) {
+	// This is a test function for parsing compound assignments, which are let statements of the operation
+	// The expected output is the program printed back
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x += 1;", "let x = (x + 1);"},
+		{"x -= 2 * y", "let x = (x - (2 * y));"},
+		{"x *= f(1);", "let x = (x * f(1));"},
+		{"x /= 2; x", "let x = (x / 2);x"},
+		{"while (i < 3) { i += 1 }", "while (i < 3) let i = (i + 1);"},
+	}
+
+	for i, tt := range tests {
+		program := parseProgram(t, tt.input)
+
+		if program.String() != tt.expected {
+			t.Errorf("tests[%d] - program wrong. expected=%q, got=%q", i, tt.expected, program.String())
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	// This is a test function for the errors the parser collects instead of panicking
+	// Only the first error is checked, as the parser keeps going and may report follow-up errors
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"let = 5;", "expected next token to be IDENT, got = instead"},
+		{"let x 5;", "expected next token to be =, got INT instead"},
+		{"if (x { x }", "expected next token to be ), got { instead"},
+		{"fn(x, 1) {}", "expected next token to be IDENT, got INT instead"},
+		{"let f = fn(a, a) { a }; f(1, 2)", "duplicate parameter a"},
+		{"fn(a, b, c, b) {}", "duplicate parameter b"},
+		{"add(1, 2", "expected next token to be ), got EOF instead"},
+		{"fn(x) { x", "expected next token to be }, got EOF instead"},
+		{"*5", "no prefix parse function for * found"},
+		{"99999999999999999999", "could not parse \"99999999999999999999\" as integer"},
+		{"[1, 2", "expected next token to be ], got EOF instead"},
+		{"break;", "break is not in a loop"},
+		{"if (x) { continue }", "continue is not in a loop"},
+		{"while (x) { fn() { break } }", "break is not in a loop"},
+		{"while x { }", "expected next token to be (, got IDENT instead"},
+		{"for (x of y) { }", "expected next token to be IN, got IDENT instead"},
+		{"for (1 in y) { }", "expected next token to be IDENT, got INT instead"},
+		{"a[1", "expected next token to be ], got EOF instead"},
+		{`{"a" 1}`, "expected next token to be :, got INT instead"},
+		{`{"a": 1 "b": 2}`, "expected next token to be ,, got STRING instead"},
+		{`"a ${x y}"`, "expected next token to be }, got IDENT instead"},
+		{`"a ${x`, "expected next token to be }, got EOF instead"},
+		{"let y = x += 1;", "+= must follow a name at the start of a statement"},
+		{"1 -= 2;", "-= must follow a name at the start of a statement"},
+		{`puts("a\qb")`, "1:8: unknown escape sequence"},
+		{`puts("ab`, "1:6: string literal not terminated"},
+		{"let x = 1 @ 2;", "1:11: unexpected character \"@\""},
+		{"let @ = 1;", "1:5: unexpected character \"@\""},
+		{"let x = 12ab;", "1:9: malformed number \"12ab\""},
+	}
+
+	for i, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("tests[%d] - expected an error for %q, got none", i, tt.input)
+		}
+
+		if errors[0] != tt.expectedError {
+			t.Errorf("tests[%d] - error wrong. expected=%q, got=%q", i, tt.expectedError, errors[0])
+		}
+	}
+}
+
+func parseProgram(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	return program
+}
+
+func checkParserErrors(t *testing.T, p *Parser) {
+	t.Helper()
+
+	errors := p.Errors()
+	if len(errors) == 0 {
+		return
+	}
+
+	t.Errorf("parser has %d errors", len(errors))
+	for _, msg := range errors {
+		t.Errorf("parser error: %q", msg)
+	}
+	t.FailNow()
+}
+
+func singleExpression(t *testing.T, program *ast.Program) ast.Expression {
+	t.Helper()
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("statement is not *ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	return stmt.Expression
+}
+
+func testLiteralExpression(t *testing.T, exp ast.Expression, expected interface{}) {
+	t.Helper()
+
+	switch v := expected.(type) {
+	case int:
+		testIntegerLiteral(t, exp, int64(v))
+	case int64:
+		testIntegerLiteral(t, exp, v)
+	case string:
+		testIdentifier(t, exp, v)
+	case bool:
+		testBooleanLiteral(t, exp, v)
+	default:
+		t.Errorf("type of exp not handled. got=%T", exp)
+	}
+}
+
+func testInfixExpression(t *testing.T, exp ast.Expression, left interface{}, operator string, right interface{}) {
+	t.Helper()
+
+	ie, ok := exp.(*ast.InfixExpression)
+	if !ok {
+		t.Fatalf("exp is not *ast.InfixExpression. got=%T(%s)", exp, exp)
+	}
+
+	testLiteralExpression(t, ie.Left, left)
+
+	if ie.Operator != operator {
+		t.Errorf("operator wrong. expected=%q, got=%q", operator, ie.Operator)
+	}
+
+	testLiteralExpression(t, ie.Right, right)
+}
+
+func testIntegerLiteral(t *testing.T, exp ast.Expression, value int64) {
+	t.Helper()
+
+	il, ok := exp.(*ast.IntegerLiteral)
+	if !ok {
+		t.Fatalf("exp is not *ast.IntegerLiteral. got=%T", exp)
+	}
+
+	if il.Value != value {
+		t.Errorf("value wrong. expected=%d, got=%d", value, il.Value)
+	}
+
+	if il.TokenLiteral() != fmt.Sprintf("%d", value) {
+		t.Errorf("token literal wrong. expected=%d, got=%s", value, il.TokenLiteral())
+	}
+}
+
+func testIdentifier(t *testing.T, exp ast.Expression, value string) {
+	t.Helper()
+
+	ident, ok := exp.(*ast.Identifier)
+	if !ok {
+		t.Fatalf("exp is not *ast.Identifier. got=%T", exp)
+	}
+
+	if ident.Value != value {
+		t.Errorf("value wrong. expected=%q, got=%q", value, ident.Value)
+	}
+}
+
+func testBooleanLiteral(t *testing.T, exp ast.Expression, value bool) {
+	t.Helper()
+
+	b, ok := exp.(*ast.Boolean)
+	if !ok {
+		t.Fatalf("exp is not *ast.Boolean. got=%T", exp)
+	}
+
+	if b.Value != value {
+		t.Errorf("value wrong. expected=%t, got=%t", value, b.Value)
+	}
+}
@@ -0,0 +1,582 @@
+package regvm
+
+import (
+	"fmt"
+	"interpreter/code"
+	"interpreter/compiler"
+	"interpreter/object"
+	"interpreter/vm"
+)
+
+// Translate turns the stack bytecode of the compiler into register code, one function at a time
+// It returns the main program as a function without parameters
+//
+// The registers of a function are allocated from the stack code:
+// the locals keep the slots the compiler gave them, and the stack slot at depth d becomes the register NumLocals+d
+// Most of the pushes and pops then disappear, as a value is only copied into its stack register when it has to be:
+// constants and locals are used where they are, and a value stored to a local is computed directly into it
+// For example, "let b = a + 1" in a function with the locals a and b becomes "ADD R1 R0 K0"
+// instead of the four instructions "OpGetLocal 0, OpConstant 0, OpAdd, OpSetLocal 1"
+func Translate(bytecode *compiler.Bytecode) (*Function, error) {
+	a := &allocator{
+		constants: bytecode.Constants,
+		functions: map[*object.CompiledFunction]*Function{},
+	}
+
+	main, err := a.translate(&object.CompiledFunction{Instructions: bytecode.Instructions}, true)
+	if err != nil {
+		return nil, err
+	}
+	main.GlobalNames = bytecode.GlobalNames
+	return main, nil
+}
+
+type allocator struct {
+	// The constants of the whole program, as the compiler numbered them
+	constants []object.Object
+
+	// Every function constant is translated once, even if it is used in several places
+	functions map[*object.CompiledFunction]*Function
+}
+
+type operandKind byte
+
+const (
+	inRegister operandKind = iota
+	inConstant
+)
+
+// operand is a value on the stack of the stack code, as the register code sees it
+// It is either in a register, which may be a local or its own stack register, or a constant that was not loaded yet
+type operand struct {
+	kind  operandKind
+	index int
+
+	// The instruction that computed the value into its stack register, or -1
+	// While it is the last instruction, it can compute the value into another register instead
+	producer int
+}
+
+// jump is a jump instruction whose target is patched when the positions of all instructions are known
+type jump struct {
+	instruction int
+	target      int
+}
+
+// function is the state of the translation of one function
+type function struct {
+	*allocator
+
+	in   *object.CompiledFunction
+	out  *Function
+	main bool
+
+	constantIndexes map[object.Object]int
+
+	stack     []operand
+	numLocals int
+
+	// The offsets of the stack code that are jumped to, with the depth of the stack there
+	// A backward target is the start of a loop, jumped to from the end of the loop
+	targets  map[int]bool
+	backward map[int]bool
+	depths   map[int]int
+
+	// The register code index of every offset of the stack code that is translated
+	positions map[int]int
+	jumps     []jump
+
+	// The locals that are set on every path to the current instruction, and on every jump to a target
+	// A local is nil until its "let" runs, so it is checked where it is read unless it is surely set
+	assigned   []bool
+	assignedAt map[int][]bool
+
+	// False after an unconditional jump or a return, until the next target that is jumped to
+	reachable bool
+}
+
+func (a *allocator) translate(in *object.CompiledFunction, main bool) (*Function, error) {
+	if out, ok := a.functions[in]; ok {
+		return out, nil
+	}
+
+	f := &function{
+		allocator: a,
+		in:        in,
+		out: &Function{
+			NumParameters: in.NumParameters,
+			NumLocals:     in.NumLocals,
+			LocalNames:    in.LocalNames,
+		},
+		main:            main,
+		constantIndexes: map[object.Object]int{},
+		numLocals:       in.NumLocals,
+		targets:         map[int]bool{},
+		backward:        map[int]bool{},
+		depths:          map[int]int{},
+		positions:       map[int]int{},
+		assigned:        make([]bool, in.NumLocals),
+		assignedAt:      map[int][]bool{},
+		reachable:       true,
+	}
+	a.functions[in] = f.out
+
+	// The arguments are the first locals, so they are always set
+	for i := 0; i < in.NumParameters; i++ {
+		f.assigned[i] = true
+	}
+
+	ins := in.Instructions
+
+	for ip := 0; ip < len(ins); {
+		def, err := code.Lookup(ins[ip])
+		if err != nil {
+			return nil, err
+		}
+		operands, read := code.ReadOperands(def, ins[ip+1:])
+
+		switch code.Opcode(ins[ip]) {
+		case code.OpJump, code.OpJumpNotTruthy, code.OpIterNext:
+			f.targets[operands[0]] = true
+			if operands[0] <= ip {
+				f.backward[operands[0]] = true
+			}
+		}
+
+		ip += 1 + read
+	}
+
+	for ip := 0; ip < len(ins); {
+		def, _ := code.Lookup(ins[ip])
+		operands, read := code.ReadOperands(def, ins[ip+1:])
+
+		if err := f.label(ip); err != nil {
+			return nil, err
+		}
+
+		// The code that can't be reached, e.g. after a return, is left out
+		if f.reachable {
+			f.positions[ip] = len(f.out.Instructions)
+			if err := f.instruction(code.Opcode(ins[ip]), operands); err != nil {
+				return nil, err
+			}
+		}
+
+		ip += 1 + read
+	}
+
+	// Only the main program can run past its last instruction, a function always ends with a return
+	if err := f.label(len(ins)); err != nil {
+		return nil, err
+	}
+	f.positions[len(ins)] = len(f.out.Instructions)
+
+	for _, j := range f.jumps {
+		position, ok := f.positions[j.target]
+		if !ok {
+			return nil, fmt.Errorf("jump to unreachable offset %d", j.target)
+		}
+		if position > maxBx {
+			return nil, fmt.Errorf("function is too long")
+		}
+		f.out.Instructions[j.instruction] |= Instruction(position) << posB
+	}
+
+	// The main program keeps the value of its last expression statement in the register 0
+	if main && f.out.NumRegisters == 0 {
+		f.out.NumRegisters = 1
+	}
+	if f.out.NumRegisters > maxA+1 {
+		return nil, fmt.Errorf("function needs too many registers: %d", f.out.NumRegisters)
+	}
+
+	return f.out, nil
+}
+
+func (f *function) label(ip int) error {
+	// Every value must be in its own stack register where code paths meet
+	// So the value of an if expression ends up in the same register, whichever branch computed it
+	if !f.targets[ip] {
+		return nil
+	}
+
+	if f.reachable {
+		depth, ok := f.depths[ip]
+		if !ok && !f.backward[ip] {
+			// Only unreachable code jumps here, e.g. the end of an if whose branch returns
+			return nil
+		}
+		if ok && depth != len(f.stack) {
+			return fmt.Errorf("stack depth mismatch at offset %d: %d and %d", ip, depth, len(f.stack))
+		}
+		f.flush()
+
+		// Only the locals set on the jumps here too are surely set, a jump back to a loop start is left out
+		// as it comes later, and only sets more locals than the code before the loop
+		if at, ok := f.assignedAt[ip]; ok {
+			for i := range f.assigned {
+				f.assigned[i] = f.assigned[i] && at[i]
+			}
+		}
+	} else {
+		depth, ok := f.depths[ip]
+		if !ok {
+			// Nothing jumps here, so the code stays unreachable
+			return nil
+		}
+
+		f.stack = f.stack[:0]
+		for i := 0; i < depth; i++ {
+			f.push(operand{kind: inRegister, index: f.slot(i)})
+		}
+		copy(f.assigned, f.assignedAt[ip])
+		f.reachable = true
+	}
+
+	// A value computed before the target can't be computed into another register any more,
+	// as another code path may jump past the instruction that computes it
+	for i := range f.stack {
+		f.stack[i].producer = -1
+	}
+
+	return nil
+}
+
+func (f *function) instruction(op code.Opcode, operands []int) error {
+	switch op {
+	case code.OpConstant:
+		f.pushConstant(f.constants[operands[0]])
+
+	case code.OpTrue:
+		f.pushConstant(vm.True)
+
+	case code.OpFalse:
+		f.pushConstant(vm.False)
+
+	case code.OpNull:
+		f.pushConstant(vm.Null)
+
+	case code.OpGetBuiltin:
+		f.pushConstant(object.Builtins[operands[0]].Builtin)
+
+	case code.OpPop:
+		d := len(f.stack) - 1
+		if f.main && d == 0 {
+			f.toSlot(0)
+		}
+		f.stack = f.stack[:d]
+
+	case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod, code.OpPow,
+		code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpGreaterEqual, code.OpLessThan, code.OpLessEqual,
+		code.OpIndex:
+		d := len(f.stack) - 2
+		left := f.rk(d)
+		right := f.rk(d + 1)
+		f.stack = f.stack[:d]
+		f.result(registerOpcodes[op], left, right)
+
+	case code.OpMinus, code.OpBang:
+		d := len(f.stack) - 1
+		right := f.rk(d)
+		f.stack = f.stack[:d]
+		f.result(registerOpcodes[op], right)
+
+	case code.OpGetLocal:
+		if !f.assigned[operands[0]] {
+			f.emit(OpCheckLocal, operands[0])
+			f.assigned[operands[0]] = true
+		}
+		f.push(operand{kind: inRegister, index: operands[0], producer: -1})
+
+	case code.OpSetLocal:
+		f.setLocal(operands[0])
+
+	case code.OpGetGlobal:
+		f.result(OpGetGlobal, operands[0])
+
+	case code.OpSetGlobal:
+		d := len(f.stack) - 1
+		r := f.register(d)
+		f.stack = f.stack[:d]
+		f.emit(OpSetGlobal, r, operands[0])
+
+	case code.OpGetFree:
+		f.result(OpGetFree, operands[0])
+
+	case code.OpCurrentClosure:
+		f.result(OpCurrentClosure)
+
+	case code.OpGetCell:
+		// A captured local holds its cell, so its value is loaded into a register of its own
+		f.result(OpGetCell, operands[0])
+
+	case code.OpSetCell:
+		d := len(f.stack) - 1
+		r := f.register(d)
+		f.stack = f.stack[:d]
+		f.emit(OpSetCell, operands[0], r)
+
+	case code.OpCaptureLocal:
+		f.result(OpCaptureLocal, operands[0])
+
+	case code.OpCaptureFree:
+		f.result(OpCaptureFree, operands[0])
+
+	case code.OpArray, code.OpHash, code.OpInterpolate:
+		n := operands[0]
+		if n > maxB {
+			return fmt.Errorf("too many elements: %d", n)
+		}
+		d := f.toSlots(n)
+		f.result(registerOpcodes[op], f.slot(d), n)
+
+	case code.OpJump:
+		f.flush()
+		f.emitJump(OpJump, operands[0])
+		if err := f.setDepth(operands[0], len(f.stack)); err != nil {
+			return err
+		}
+		f.reachable = false
+
+	case code.OpJumpNotTruthy:
+		return f.jumpNotTruthy(operands[0])
+
+	case code.OpCall:
+		d := f.toSlots(operands[0] + 1)
+		f.emit(OpCall, f.slot(d), operands[0])
+		f.push(operand{kind: inRegister, index: f.slot(d), producer: -1})
+
+	case code.OpReturnValue:
+		d := len(f.stack) - 1
+		r := f.register(d)
+		f.stack = f.stack[:d]
+		f.emit(OpReturn, r)
+		f.reachable = false
+
+	case code.OpReturn:
+		f.emit(OpReturnNull)
+		f.reachable = false
+
+	case code.OpClosure:
+		fn, ok := f.constants[operands[0]].(*object.CompiledFunction)
+		if !ok {
+			return fmt.Errorf("not a function: %+v", f.constants[operands[0]])
+		}
+
+		translated, err := f.translate(fn, false)
+		if err != nil {
+			return err
+		}
+		translated.NumFree = operands[1]
+
+		d := f.toSlots(operands[1])
+		f.emit(OpClosure, f.slot(d), f.constant(translated))
+		f.push(operand{kind: inRegister, index: f.slot(d), producer: -1})
+
+	case code.OpIter:
+		d := len(f.stack) - 1
+		iterable := f.rk(d)
+		f.stack = f.stack[:d]
+		f.emit(OpIter, f.slot(d), iterable)
+		f.push(operand{kind: inRegister, index: f.slot(d), producer: -1})
+
+	case code.OpIterNext:
+		// The iterator stays in its register, the element is put in the register after it
+		f.flush()
+		d := len(f.stack) - 1
+		f.emitJump(OpIterNext, operands[0], f.slot(d))
+		if err := f.setDepth(operands[0], d); err != nil {
+			return err
+		}
+		f.push(operand{kind: inRegister, index: f.slot(d + 1), producer: -1})
+
+	default:
+		def, _ := code.Lookup(byte(op))
+		return fmt.Errorf("opcode %s not supported", def.Name)
+	}
+
+	return nil
+}
+
+// The register opcodes of the stack opcodes that compute a value from other values
+var registerOpcodes = map[code.Opcode]Opcode{
+	code.OpAdd:          OpAdd,
+	code.OpSub:          OpSub,
+	code.OpMul:          OpMul,
+	code.OpDiv:          OpDiv,
+	code.OpMod:          OpMod,
+	code.OpPow:          OpPow,
+	code.OpEqual:        OpEqual,
+	code.OpNotEqual:     OpNotEqual,
+	code.OpGreaterThan:  OpGreaterThan,
+	code.OpGreaterEqual: OpGreaterEqual,
+	code.OpLessThan:     OpLessThan,
+	code.OpLessEqual:    OpLessEqual,
+	code.OpIndex:        OpIndex,
+	code.OpMinus:        OpMinus,
+	code.OpBang:         OpBang,
+	code.OpArray:        OpArray,
+	code.OpHash:         OpHash,
+	code.OpInterpolate:  OpInterpolate,
+}
+
+func (f *function) setLocal(local int) {
+	f.assigned[local] = true
+
+	d := len(f.stack) - 1
+	value := f.stack[d]
+	f.stack = f.stack[:d]
+
+	// The values on the stack that are still the old value of the local are copied away first
+	for i := range f.stack {
+		if f.stack[i].kind == inRegister && f.stack[i].index == local {
+			f.toSlot(i)
+		}
+	}
+
+	last := len(f.out.Instructions) - 1
+
+	switch {
+	case value.kind == inConstant:
+		f.emit(OpLoadK, local, value.index)
+	case value.producer >= 0 && value.producer == last:
+		// Compute the value directly into the local instead of copying it there
+		ins := f.out.Instructions[last]
+		f.out.Instructions[last] = ins&^(maxA<<posA) | Instruction(local)<<posA
+	case value.index != local:
+		f.emit(OpMove, local, value.index)
+	}
+}
+
+func (f *function) jumpNotTruthy(target int) error {
+	d := len(f.stack) - 1
+	condition := f.stack[d]
+	f.stack = f.stack[:d]
+
+	// A constant condition is decided here, e.g. "while (true)" doesn't test anything
+	if condition.kind == inConstant {
+		if vm.IsTruthy(f.out.Constants[condition.index]) {
+			return nil
+		}
+
+		f.flush()
+		f.emitJump(OpJump, target)
+		f.reachable = false
+		return f.setDepth(target, d)
+	}
+
+	// The condition is a local or its own stack register, so flushing the stack below it doesn't change it
+	f.flush()
+	f.emitJump(OpJumpNotTruthy, target, condition.index)
+	return f.setDepth(target, d)
+}
+
+func (f *function) setDepth(target int, depth int) error {
+	if existing, ok := f.depths[target]; ok && existing != depth {
+		return fmt.Errorf("stack depth mismatch at offset %d: %d and %d", target, existing, depth)
+	}
+	f.depths[target] = depth
+
+	// The locals set at the target are the ones set on every jump to it
+	if at, ok := f.assignedAt[target]; ok {
+		for i := range at {
+			at[i] = at[i] && f.assigned[i]
+		}
+	} else {
+		f.assignedAt[target] = append([]bool{}, f.assigned...)
+	}
+	return nil
+}
+
+func (f *function) slot(depth int) int {
+	return f.numLocals + depth
+}
+
+func (f *function) push(o operand) {
+	f.stack = append(f.stack, o)
+
+	if n := f.slot(len(f.stack)); n > f.out.NumRegisters {
+		f.out.NumRegisters = n
+	}
+}
+
+func (f *function) pushConstant(obj object.Object) {
+	f.push(operand{kind: inConstant, index: f.constant(obj), producer: -1})
+}
+
+func (f *function) constant(obj object.Object) int {
+	if index, ok := f.constantIndexes[obj]; ok {
+		return index
+	}
+
+	f.out.Constants = append(f.out.Constants, obj)
+	f.constantIndexes[obj] = len(f.out.Constants) - 1
+	return len(f.out.Constants) - 1
+}
+
+func (f *function) emit(op Opcode, operands ...int) int {
+	f.out.Instructions = append(f.out.Instructions, Make(op, operands...))
+	return len(f.out.Instructions) - 1
+}
+
+func (f *function) emitJump(op Opcode, target int, operands ...int) {
+	// The target is left 0 until it is patched
+	index := f.emit(op, append(operands, 0)...)
+	f.jumps = append(f.jumps, jump{instruction: index, target: target})
+}
+
+func (f *function) result(op Opcode, operands ...int) {
+	// Compute a value into the stack register of the next slot and push it
+	d := len(f.stack)
+	index := f.emit(op, append([]int{f.slot(d)}, operands...)...)
+	f.push(operand{kind: inRegister, index: f.slot(d), producer: index})
+}
+
+func (f *function) toSlot(i int) {
+	// Copy the value at the depth i into its own stack register, if it is not there yet
+	o := f.stack[i]
+	if o.kind == inRegister && o.index == f.slot(i) {
+		return
+	}
+
+	var index int
+	if o.kind == inConstant {
+		index = f.emit(OpLoadK, f.slot(i), o.index)
+	} else {
+		index = f.emit(OpMove, f.slot(i), o.index)
+	}
+	f.stack[i] = operand{kind: inRegister, index: f.slot(i), producer: index}
+}
+
+func (f *function) toSlots(n int) int {
+	// Copy the top n values into their stack registers and pop them, for the instructions that need them in a row
+	// It returns the depth of the first one
+	d := len(f.stack) - n
+	for i := d; i < len(f.stack); i++ {
+		f.toSlot(i)
+	}
+	f.stack = f.stack[:d]
+	return d
+}
+
+func (f *function) flush() {
+	for i := range f.stack {
+		f.toSlot(i)
+	}
+}
+
+func (f *function) register(i int) int {
+	// Return a register holding the value at the depth i
+	if f.stack[i].kind == inConstant {
+		f.toSlot(i)
+	}
+	return f.stack[i].index
+}
+
+func (f *function) rk(i int) int {
+	// Return an RK operand for the value at the depth i, a constant is used directly if it can be
+	if o := f.stack[i]; o.kind == inConstant && o.index < rkConstant {
+		return RK(o.index)
+	}
+	return f.register(i)
+}
@@ -0,0 +1,99 @@
+package regvm
+
+import "testing"
+
+func TestTranslate(t *testing.T) {
+	// This is a test function for the register code the stack code is translated to
+	// The expected code is the one of the first function of the program, or of the main program if there is none
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			// Constants are used directly and the sum is computed into the local b
+			"fn(a) { let b = a + 1; b }",
+			"0000 ADD R1 R0 K0\n0001 RETURN R1\n",
+		},
+		{
+			// The arguments of a call are put in the registers after the function
+			"fn(a) { len(a) + 1 }",
+			"0000 LOADK R1 K0\n0001 MOVE R2 R0\n0002 CALL R1 1\n0003 ADD R1 R1 K1\n0004 RETURN R1\n",
+		},
+		{
+			// Both branches of an if compute the value into the same register
+			"fn(a) { if (a) { 1 } else { 2 } }",
+			"0000 JMPIFNOT R0 3\n0001 LOADK R1 K0\n0002 JMP 4\n0003 LOADK R1 K1\n0004 RETURN R1\n",
+		},
+		{
+			// The code after a return is left out, and "while (true)" tests nothing
+			"fn() { while (true) { return 1; 2 } }",
+			"0000 LOADK R0 K1\n0001 RETURN R0\n",
+		},
+		{
+			// The old value of a local is copied away before the local is changed
+			"fn(a) { a + if (true) { let a = 2; a } }",
+			"0000 MOVE R1 R0\n0001 LOADK R0 K1\n0002 MOVE R2 R0\n0003 JMP 4\n0004 ADD R1 R1 R2\n0005 RETURN R1\n",
+		},
+		{
+			"let x = [1, 2]; x[0]",
+			"0000 LOADK R0 K0\n0001 LOADK R1 K1\n0002 ARRAY R0 R0 2\n0003 SETGLOBAL R0 G0\n0004 GETGLOBAL R0 G0\n0005 INDEX R0 R0 K2\n",
+		},
+		{
+			// A local that is not set on every path is checked before it is read
+			"fn(c) { if (c) { let x = 1 }; x }",
+			"0000 JMPIFNOT R0 4\n0001 LOADK R1 K0\n0002 LOADK R2 K1\n0003 JMP 5\n0004 LOADK R2 K1\n0005 CHECKLOCAL R1\n0006 RETURN R1\n",
+		},
+		{
+			// Set on both branches, y is not checked
+			"fn(c) { let x = 0; if (c) { let y = x } else { let y = 2 }; x + y }",
+			"0000 LOADK R1 K0\n0001 JMPIFNOT R0 5\n0002 MOVE R2 R1\n0003 LOADK R3 K1\n0004 JMP 7\n0005 LOADK R2 K2\n0006 LOADK R3 K1\n0007 ADD R3 R1 R2\n0008 RETURN R3\n",
+		},
+		{
+			// A captured local holds its cell, it is read and written through it
+			"fn(a) { let g = fn() { a }; let a = a + 1; g() }",
+			"0000 CAPTURELOCAL R2 R0\n0001 CLOSURE R2 K0\n0002 MOVE R1 R2\n0003 GETCELL R2 R0\n0004 ADD R2 R2 K1\n0005 SETCELL R0 R2\n0006 MOVE R2 R1\n0007 CALL R2 0\n0008 RETURN R2\n",
+		},
+		{
+			"fn(xs) { for (x in xs) { x } }",
+			"0000 ITER R2 R0\n0001 ITERNEXT R2 4\n0002 MOVE R1 R3\n0003 JMP 1\n0004 RETURNNULL\n",
+		},
+	}
+
+	for i, tt := range tests {
+		fn := translate(t, tt.input)
+		for _, constant := range fn.Constants {
+			if inner, ok := constant.(*Function); ok {
+				fn = inner
+				break
+			}
+		}
+
+		if fn.Instructions.String() != tt.expected {
+			t.Errorf("tests[%d] - wrong instructions.\nwant=\n%s\ngot=\n%s", i, tt.expected, fn.Instructions)
+		}
+	}
+}
+
+func TestTranslateRegisters(t *testing.T) {
+	// This is a test function for the number of registers of a function, its locals and the deepest stack
+	fn := translate(t, "fn(a, b) { let c = [a, b, [a]]; c }")
+	inner := fn.Constants[0].(*Function)
+
+	if inner.NumParameters != 2 {
+		t.Errorf("wrong number of parameters. want=2, got=%d", inner.NumParameters)
+	}
+	// The three locals and the values a, b and a of "[a, b, [a]]" at the deepest point
+	if inner.NumRegisters != 6 {
+		t.Errorf("wrong number of registers. want=6, got=%d", inner.NumRegisters)
+	}
+}
+
+func translate(t *testing.T, input string) *Function {
+	t.Helper()
+
+	fn, err := Translate(compile(t, input))
+	if err != nil {
+		t.Fatalf("translation error: %s", err)
+	}
+	return fn
+}
@@ -0,0 +1,284 @@
+package regvm
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Instruction is a register instruction packed into 32 bits, like the instructions of Lua
+// The opcode takes the low 6 bits, followed by the operands A (8 bits), B (9 bits) and C (9 bits)
+// Bx is B and C read together as one 18-bit operand, for constants, globals and jump targets
+// For example, "ADD R2 R0 K1" adds the register 0 and the constant 1 and stores the sum in the register 2
+type Instruction uint32
+
+const (
+	sizeOp = 6
+	sizeA  = 8
+	sizeB  = 9
+	sizeC  = 9
+
+	posA = sizeOp
+	posB = posA + sizeA
+	posC = posB + sizeB
+
+	maxA  = 1<<sizeA - 1
+	maxB  = 1<<sizeB - 1
+	maxBx = 1<<(sizeB+sizeC) - 1
+
+	// An RK operand with this bit set is an index in the constants of the function, otherwise a register
+	// So the first 256 constants can be used directly, without loading them into a register first
+	rkConstant = 1 << (sizeB - 1)
+)
+
+func (i Instruction) Opcode() Opcode { return Opcode(i & (1<<sizeOp - 1)) }
+func (i Instruction) A() int         { return int(i>>posA) & maxA }
+func (i Instruction) B() int         { return int(i>>posB) & maxB }
+func (i Instruction) C() int         { return int(i>>posC) & maxB }
+func (i Instruction) Bx() int        { return int(i>>posB) & maxBx }
+
+type Opcode byte
+
+const (
+	// OpMove copies the register B into the register A
+	OpMove Opcode = iota
+	// OpLoadK loads the constant Bx into the register A
+	OpLoadK
+	OpGetGlobal
+	OpSetGlobal
+	// OpGetFree loads the free variable B of the running closure, the value in its cell
+	OpGetFree
+	// OpCurrentClosure loads the running closure, so a function can call itself
+	OpCurrentClosure
+	// OpCheckLocal stops the program if the local in the register A is not set yet
+	OpCheckLocal
+	// OpGetCell loads the local in the register B into the register A, through its cell if a closure captured it
+	OpGetCell
+	// OpSetCell stores the register B in the local in the register A, through its cell if a closure captured it
+	OpSetCell
+	// OpCaptureLocal loads the cell of the local in the register B, putting the local in a new cell if needed
+	OpCaptureLocal
+	// OpCaptureFree loads the cell of the free variable B of the running closure, for a closure created in it
+	OpCaptureFree
+
+	// The binary operators store RK(B) op RK(C) in the register A
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpMod
+	OpPow
+	OpEqual
+	OpNotEqual
+	OpGreaterThan
+	OpGreaterEqual
+	OpLessThan
+	OpLessEqual
+
+	// The prefix operators store op RK(B) in the register A
+	OpMinus
+	OpBang
+
+	// OpJump jumps to the instruction Bx
+	OpJump
+	// OpJumpNotTruthy jumps to the instruction Bx if the register A is falsy
+	OpJumpNotTruthy
+
+	// OpArray, OpHash and OpInterpolate build a value from the C registers starting at the register B
+	OpArray
+	OpHash
+	OpInterpolate
+	// OpIndex stores RK(B)[RK(C)] in the register A
+	OpIndex
+
+	// OpCall calls the function in the register A with the B arguments in the registers after it
+	// The result replaces the function in the register A
+	OpCall
+	// OpReturn returns the register A from the running function
+	OpReturn
+	// OpReturnNull returns null from the running function
+	OpReturnNull
+	// OpClosure stores a closure of the function constant Bx in the register A
+	// The free variables it captures are taken from the registers starting at A
+	OpClosure
+
+	// OpIter stores an iterator over RK(B) in the register A
+	OpIter
+	// OpIterNext stores the next element of the iterator in the register A in the register A+1
+	// When there is none, it jumps to the instruction Bx instead
+	OpIterNext
+)
+
+// OperandKind tells how an operand of an instruction is used
+type OperandKind byte
+
+const (
+	Register OperandKind = iota
+	RegisterOrConstant
+	Count
+	// The kinds below are stored in Bx
+	Constant
+	Global
+	Target
+)
+
+// Definition tells the name of an opcode and the operands it takes
+// The operands are stored in A, B and C in order, except the Bx kinds which are always stored in Bx
+type Definition struct {
+	Name     string
+	Operands []OperandKind
+}
+
+var binaryOperands = []OperandKind{Register, RegisterOrConstant, RegisterOrConstant}
+var collectionOperands = []OperandKind{Register, Register, Count}
+
+var definitions = map[Opcode]*Definition{
+	OpMove:           {"MOVE", []OperandKind{Register, Register}},
+	OpLoadK:          {"LOADK", []OperandKind{Register, Constant}},
+	OpGetGlobal:      {"GETGLOBAL", []OperandKind{Register, Global}},
+	OpSetGlobal:      {"SETGLOBAL", []OperandKind{Register, Global}},
+	OpGetFree:        {"GETFREE", []OperandKind{Register, Count}},
+	OpCurrentClosure: {"CURRENTCLOSURE", []OperandKind{Register}},
+	OpCheckLocal:     {"CHECKLOCAL", []OperandKind{Register}},
+	OpGetCell:        {"GETCELL", []OperandKind{Register, Register}},
+	OpSetCell:        {"SETCELL", []OperandKind{Register, Register}},
+	OpCaptureLocal:   {"CAPTURELOCAL", []OperandKind{Register, Register}},
+	OpCaptureFree:    {"CAPTUREFREE", []OperandKind{Register, Count}},
+
+	OpAdd:          {"ADD", binaryOperands},
+	OpSub:          {"SUB", binaryOperands},
+	OpMul:          {"MUL", binaryOperands},
+	OpDiv:          {"DIV", binaryOperands},
+	OpMod:          {"MOD", binaryOperands},
+	OpPow:          {"POW", binaryOperands},
+	OpEqual:        {"EQ", binaryOperands},
+	OpNotEqual:     {"NE", binaryOperands},
+	OpGreaterThan:  {"GT", binaryOperands},
+	OpGreaterEqual: {"GE", binaryOperands},
+	OpLessThan:     {"LT", binaryOperands},
+	OpLessEqual:    {"LE", binaryOperands},
+
+	OpMinus: {"MINUS", []OperandKind{Register, RegisterOrConstant}},
+	OpBang:  {"NOT", []OperandKind{Register, RegisterOrConstant}},
+
+	OpJump:          {"JMP", []OperandKind{Target}},
+	OpJumpNotTruthy: {"JMPIFNOT", []OperandKind{Register, Target}},
+
+	OpArray:       {"ARRAY", collectionOperands},
+	OpHash:        {"HASH", collectionOperands},
+	OpInterpolate: {"INTERPOLATE", collectionOperands},
+	OpIndex:       {"INDEX", binaryOperands},
+
+	OpCall:       {"CALL", []OperandKind{Register, Count}},
+	OpReturn:     {"RETURN", []OperandKind{Register}},
+	OpReturnNull: {"RETURNNULL", []OperandKind{}},
+	OpClosure:    {"CLOSURE", []OperandKind{Register, Constant}},
+
+	OpIter:     {"ITER", []OperandKind{Register, RegisterOrConstant}},
+	OpIterNext: {"ITERNEXT", []OperandKind{Register, Target}},
+}
+
+// Lookup returns the definition of the opcode
+func Lookup(op Opcode) (*Definition, error) {
+	def, ok := definitions[op]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+
+	return def, nil
+}
+
+func isBx(kind OperandKind) bool {
+	return kind >= Constant
+}
+
+// Make encodes an instruction
+// For example, Make(OpAdd, 2, 0, RK(1)) is "ADD R2 R0 K1"
+func Make(op Opcode, operands ...int) Instruction {
+	def := definitions[op]
+
+	ins := Instruction(op)
+	shifts := []int{posA, posB, posC}
+	next := 0
+
+	for i, o := range operands {
+		if isBx(def.Operands[i]) {
+			ins |= Instruction(o) << posB
+			continue
+		}
+		ins |= Instruction(o) << shifts[next]
+		next++
+	}
+
+	return ins
+}
+
+// RK returns the RK operand of the constant with the given index
+func RK(constant int) int {
+	return constant | rkConstant
+}
+
+// Operands decodes the operands of an instruction, the opposite of Make
+func (i Instruction) Operands() []int {
+	def := definitions[i.Opcode()]
+
+	fields := []int{i.A(), i.B(), i.C()}
+	next := 0
+
+	operands := make([]int, len(def.Operands))
+	for j, kind := range def.Operands {
+		if isBx(kind) {
+			operands[j] = i.Bx()
+			continue
+		}
+		operands[j] = fields[next]
+		next++
+	}
+
+	return operands
+}
+
+func (i Instruction) String() string {
+	// For example, "ADD R2 R0 K1" or "JMP 7"
+	def, err := Lookup(i.Opcode())
+	if err != nil {
+		return "ERROR: " + err.Error()
+	}
+
+	var out bytes.Buffer
+	out.WriteString(def.Name)
+
+	for j, o := range i.Operands() {
+		switch def.Operands[j] {
+		case Register:
+			fmt.Fprintf(&out, " R%d", o)
+		case RegisterOrConstant:
+			if o&rkConstant != 0 {
+				fmt.Fprintf(&out, " K%d", o&^rkConstant)
+			} else {
+				fmt.Fprintf(&out, " R%d", o)
+			}
+		case Constant:
+			fmt.Fprintf(&out, " K%d", o)
+		case Global:
+			fmt.Fprintf(&out, " G%d", o)
+		default:
+			fmt.Fprintf(&out, " %d", o)
+		}
+	}
+
+	return out.String()
+}
+
+// Instructions is the code of a function
+type Instructions []Instruction
+
+func (ins Instructions) String() string {
+	// Print one instruction per line, prefixed with its index
+	var out bytes.Buffer
+
+	for i, instruction := range ins {
+		fmt.Fprintf(&out, "%04d %s\n", i, instruction)
+	}
+
+	return out.String()
+}
@@ -0,0 +1,65 @@
+package regvm
+
+import "testing"
+
+func TestMake(t *testing.T) {
+	// This is a test function for encoding and decoding instructions
+	// The Bx operands take the place of B and C together
+	tests := []struct {
+		op       Opcode
+		operands []int
+		expected string
+	}{
+		{OpMove, []int{1, 255}, "MOVE R1 R255"},
+		{OpAdd, []int{2, 0, RK(1)}, "ADD R2 R0 K1"},
+		{OpLoadK, []int{3, maxBx}, "LOADK R3 K262143"},
+		{OpSetGlobal, []int{0, 65535}, "SETGLOBAL R0 G65535"},
+		{OpJump, []int{7}, "JMP 7"},
+		{OpJumpNotTruthy, []int{4, 12}, "JMPIFNOT R4 12"},
+		{OpArray, []int{1, 2, 511}, "ARRAY R1 R2 511"},
+		{OpReturnNull, []int{}, "RETURNNULL"},
+	}
+
+	for i, tt := range tests {
+		ins := Make(tt.op, tt.operands...)
+
+		if ins.Opcode() != tt.op {
+			t.Errorf("tests[%d] - wrong opcode. expected=%d, got=%d", i, tt.op, ins.Opcode())
+		}
+
+		operands := ins.Operands()
+		if len(operands) != len(tt.operands) {
+			t.Errorf("tests[%d] - wrong number of operands. expected=%d, got=%d", i, len(tt.operands), len(operands))
+			continue
+		}
+		for j, want := range tt.operands {
+			if operands[j] != want {
+				t.Errorf("tests[%d] - operand %d wrong. expected=%d, got=%d", i, j, want, operands[j])
+			}
+		}
+
+		if ins.String() != tt.expected {
+			t.Errorf("tests[%d] - instruction wrongly formatted. expected=%q, got=%q", i, tt.expected, ins.String())
+		}
+	}
+}
+
+func TestEveryOpcodeIsDefined(t *testing.T) {
+	// This is a test function for the definitions table
+	// Every opcode must fit in the opcode bits and have a definition with a unique name
+	names := map[string]bool{}
+	for op := OpMove; op <= OpIterNext; op++ {
+		def, err := Lookup(op)
+		if err != nil {
+			t.Fatalf("opcode %d has no definition", op)
+		}
+		if names[def.Name] {
+			t.Errorf("opcode name %s is used twice", def.Name)
+		}
+		names[def.Name] = true
+	}
+
+	if OpIterNext >= 1<<sizeOp {
+		t.Errorf("too many opcodes for %d bits", sizeOp)
+	}
+}
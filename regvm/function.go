@@ -0,0 +1,39 @@
+package regvm
+
+import (
+	"fmt"
+	"interpreter/object"
+)
+
+// Function is a function translated to register instructions
+// Its parameters are its first registers, followed by its other locals and then its temporaries
+type Function struct {
+	Instructions  Instructions
+	Constants     []object.Object
+	NumParameters int
+	NumLocals     int
+	NumRegisters  int
+	// The number of free variables a closure of the function captures
+	NumFree int
+
+	// The names of the locals by register, and for the main program the names of the globals,
+	// so a variable read before it is set can be named
+	LocalNames  []string
+	GlobalNames []string
+}
+
+func (f *Function) Type() object.ObjectType { return object.COMPILED_FUNCTION_OBJ }
+func (f *Function) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", f)
+}
+
+// Closure is a translated function together with the values of its free variables
+type Closure struct {
+	Fn   *Function
+	Free []object.Object
+}
+
+func (c *Closure) Type() object.ObjectType { return object.CLOSURE_OBJ }
+func (c *Closure) Inspect() string {
+	return fmt.Sprintf("Closure[%p]", c)
+}
@@ -0,0 +1,332 @@
+package regvm
+
+import (
+	"fmt"
+	"interpreter/code"
+	"interpreter/object"
+	"interpreter/vm"
+	"io"
+	"os"
+)
+
+// RegisterFileSize is the number of registers shared by all the calls, like the stack of the stack vm
+const RegisterFileSize = 65536
+const GlobalsSize = 65536
+const MaxFrames = 1024
+
+// frame is a call of a closure being run
+// Its registers are a window of the register file, starting at the base
+type frame struct {
+	cl   *Closure
+	ip   int
+	base int
+}
+
+// VM runs register code produced by Translate
+// A call doesn't copy its arguments: the window of the callee starts right after the function register of the caller,
+// so the arguments are already the first registers of the callee, like in Lua
+type VM struct {
+	registers []object.Object
+	globals   []object.Object
+	// The names of the globals, for the error of a global read before it is set
+	globalNames []string
+
+	frames      []frame
+	framesIndex int
+
+	out io.Writer
+
+	// How many times every opcode was run, to compare with the stack vm
+	counts [1 << sizeOp]int
+}
+
+func New(main *Function) *VM {
+	frames := make([]frame, MaxFrames)
+	frames[0] = frame{cl: &Closure{Fn: main}}
+
+	return &VM{
+		registers:   make([]object.Object, RegisterFileSize),
+		globals:     make([]object.Object, GlobalsSize),
+		globalNames: main.GlobalNames,
+		frames:      frames,
+		framesIndex: 1,
+		out:         os.Stdout,
+	}
+}
+
+// SetOutput sets where the built-in functions print to, os.Stdout by default
+func (v *VM) SetOutput(w io.Writer) {
+	v.out = w
+}
+
+// Result returns the value of the last expression statement of the main program, or of its return
+func (v *VM) Result() object.Object {
+	if v.registers[0] == nil {
+		return vm.Null
+	}
+	return v.registers[0]
+}
+
+// OpcodeCounts returns how many times every opcode was run, by the name of the opcode
+// Opcodes that were never run are left out
+func (v *VM) OpcodeCounts() map[string]int {
+	counts := map[string]int{}
+	for op, n := range v.counts {
+		if n == 0 {
+			continue
+		}
+		if def, err := Lookup(Opcode(op)); err == nil {
+			counts[def.Name] = n
+		}
+	}
+	return counts
+}
+
+// The stack opcodes of the binary operators, whose semantics are shared with the stack vm
+var binaryOperators = [...]code.Opcode{
+	OpAdd:          code.OpAdd,
+	OpSub:          code.OpSub,
+	OpMul:          code.OpMul,
+	OpDiv:          code.OpDiv,
+	OpMod:          code.OpMod,
+	OpPow:          code.OpPow,
+	OpEqual:        code.OpEqual,
+	OpNotEqual:     code.OpNotEqual,
+	OpGreaterThan:  code.OpGreaterThan,
+	OpGreaterEqual: code.OpGreaterEqual,
+	OpLessThan:     code.OpLessThan,
+	OpLessEqual:    code.OpLessEqual,
+}
+
+func rk(registers, constants []object.Object, operand int) object.Object {
+	if operand&rkConstant != 0 {
+		return constants[operand&^rkConstant]
+	}
+	return registers[operand]
+}
+
+// Run runs the program until its end or the first runtime error
+// The errors have the same messages as the errors of the stack vm and the evaluator
+func (v *VM) Run() error {
+	// The current frame is kept in local variables, and reloaded on every call and return
+	f := &v.frames[v.framesIndex-1]
+	instructions := f.cl.Fn.Instructions
+	constants := f.cl.Fn.Constants
+	registers := v.registers[f.base:]
+
+	for f.ip < len(instructions) {
+		ins := instructions[f.ip]
+		f.ip++
+
+		op := ins.Opcode()
+		v.counts[op]++
+
+		switch op {
+		case OpMove:
+			registers[ins.A()] = registers[ins.B()]
+
+		case OpLoadK:
+			registers[ins.A()] = constants[ins.Bx()]
+
+		case OpGetGlobal:
+			value := v.globals[ins.Bx()]
+			if value == nil {
+				return vm.NotFound(v.globalNames, ins.Bx())
+			}
+			registers[ins.A()] = value
+
+		case OpSetGlobal:
+			v.globals[ins.Bx()] = registers[ins.A()]
+
+		case OpGetFree:
+			value, err := vm.GetFree(f.cl.Free[ins.B()])
+			if err != nil {
+				return err
+			}
+			registers[ins.A()] = value
+
+		case OpCurrentClosure:
+			registers[ins.A()] = f.cl
+
+		case OpCheckLocal:
+			if registers[ins.A()] == nil {
+				return vm.NotFound(f.cl.Fn.LocalNames, ins.A())
+			}
+
+		case OpGetCell:
+			value, err := vm.GetCell(registers[ins.B()], f.cl.Fn.LocalNames, ins.B())
+			if err != nil {
+				return err
+			}
+			registers[ins.A()] = value
+
+		case OpSetCell:
+			vm.SetCell(&registers[ins.A()], registers[ins.B()])
+
+		case OpCaptureLocal:
+			registers[ins.A()] = vm.Capture(&registers[ins.B()], f.cl.Fn.LocalNames, ins.B())
+
+		case OpCaptureFree:
+			registers[ins.A()] = f.cl.Free[ins.B()]
+
+		case OpAdd, OpSub, OpMul, OpDiv, OpMod, OpPow,
+			OpEqual, OpNotEqual, OpGreaterThan, OpGreaterEqual, OpLessThan, OpLessEqual:
+			left := rk(registers, constants, ins.B())
+			right := rk(registers, constants, ins.C())
+
+			result, err := vm.BinaryOperation(binaryOperators[op], left, right)
+			if err != nil {
+				return err
+			}
+			registers[ins.A()] = result
+
+		case OpMinus:
+			result, err := vm.Minus(rk(registers, constants, ins.B()))
+			if err != nil {
+				return err
+			}
+			registers[ins.A()] = result
+
+		case OpBang:
+			if vm.IsTruthy(rk(registers, constants, ins.B())) {
+				registers[ins.A()] = vm.False
+			} else {
+				registers[ins.A()] = vm.True
+			}
+
+		case OpJump:
+			f.ip = ins.Bx()
+
+		case OpJumpNotTruthy:
+			if !vm.IsTruthy(registers[ins.A()]) {
+				f.ip = ins.Bx()
+			}
+
+		case OpArray:
+			elements := make([]object.Object, ins.C())
+			copy(elements, registers[ins.B():ins.B()+ins.C()])
+			registers[ins.A()] = &object.Array{Elements: elements}
+
+		case OpHash:
+			hash, err := vm.BuildHash(registers[ins.B() : ins.B()+ins.C()])
+			if err != nil {
+				return err
+			}
+			registers[ins.A()] = hash
+
+		case OpInterpolate:
+			registers[ins.A()] = vm.Interpolate(registers[ins.B() : ins.B()+ins.C()])
+
+		case OpIndex:
+			left := rk(registers, constants, ins.B())
+			index := rk(registers, constants, ins.C())
+
+			result, err := vm.Index(left, index)
+			if err != nil {
+				return err
+			}
+			registers[ins.A()] = result
+
+		case OpCall:
+			a, numArgs := ins.A(), ins.B()
+
+			switch callee := registers[a].(type) {
+			case *Closure:
+				if numArgs != callee.Fn.NumParameters {
+					return fmt.Errorf("wrong number of arguments: want=%d, got=%d", callee.Fn.NumParameters, numArgs)
+				}
+
+				base := f.base + a + 1
+				if v.framesIndex >= MaxFrames || base+callee.Fn.NumRegisters > len(v.registers) {
+					return fmt.Errorf("stack overflow")
+				}
+
+				// The other locals are cleared, so a local that isn't set yet doesn't read what a previous call left there
+				for i := base + numArgs; i < base+callee.Fn.NumLocals; i++ {
+					v.registers[i] = nil
+				}
+
+				v.frames[v.framesIndex] = frame{cl: callee, base: base}
+				v.framesIndex++
+
+				f = &v.frames[v.framesIndex-1]
+				instructions = f.cl.Fn.Instructions
+				constants = f.cl.Fn.Constants
+				registers = v.registers[f.base:]
+
+			case *object.Builtin:
+				result := callee.Fn(v.out, registers[a+1:a+1+numArgs]...)
+
+				// An error of a built-in function stops the program like any other runtime error
+				if err, ok := result.(*object.Error); ok {
+					return fmt.Errorf("%s", err.Message)
+				}
+				if result == nil {
+					result = vm.Null
+				}
+				registers[a] = result
+
+			default:
+				return fmt.Errorf("not a function: %s", callee.Type())
+			}
+
+		case OpReturn, OpReturnNull:
+			var value object.Object = vm.Null
+			if op == OpReturn {
+				value = registers[ins.A()]
+			}
+
+			// The result replaces the function in the register before the window of the callee
+			// A return in the main program stops it
+			v.framesIndex--
+			if v.framesIndex == 0 {
+				v.registers[0] = value
+				return nil
+			}
+			v.registers[f.base-1] = value
+
+			f = &v.frames[v.framesIndex-1]
+			instructions = f.cl.Fn.Instructions
+			constants = f.cl.Fn.Constants
+			registers = v.registers[f.base:]
+
+		case OpClosure:
+			a := ins.A()
+			fn := constants[ins.Bx()].(*Function)
+
+			// The free variables are in the registers starting at A, in the order of the FreeSymbols of the function
+			// They are the cells of the captured locals, like on the stack vm
+			free := make([]object.Object, fn.NumFree)
+			copy(free, registers[a:a+fn.NumFree])
+			registers[a] = &Closure{Fn: fn, Free: free}
+
+		case OpIter:
+			iterable := rk(registers, constants, ins.B())
+
+			iterator, ok := object.NewIterator(iterable)
+			if !ok {
+				return fmt.Errorf("cannot iterate over %s", iterable.Type())
+			}
+			registers[ins.A()] = iterator
+
+		case OpIterNext:
+			a := ins.A()
+
+			element, ok := registers[a].(*object.Iterator).Next()
+			if !ok {
+				f.ip = ins.Bx()
+				continue
+			}
+			registers[a+1] = element
+
+		default:
+			def, err := Lookup(op)
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("opcode %s not supported", def.Name)
+		}
+	}
+
+	return nil
+}
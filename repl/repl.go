@@ -2,31 +2,114 @@ package repl
 
 import (
 	"bufio"
-	"fmt"
+	"interpreter/evaluator"
 	"interpreter/lexer"
+	"interpreter/object"
+	"interpreter/parser"
 	"interpreter/token"
 	"io"
+	"strings"
 )
 
 const PROMPT = ">> "
 
+// CONTINUATION_PROMPT is shown while an opened ( or { still has to be closed
+const CONTINUATION_PROMPT = ".. "
+
+// Start reads Monkey code line by line from in, evaluates it and writes the results to out
+// It returns when the input ends or when the user types "exit" or ":quit"
+// Nothing is written anywhere but out, so the REPL can be driven by tests or embedded in a web terminal
+// Input with unbalanced brackets is continued on the following lines, so a function body can span several lines
 func Start(in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
+	// The environment is shared by every chunk, so bindings survive from one chunk to the next
+	// The built-in functions print to out as well, e.g. puts
+	env := object.NewEnvironment()
+	env.SetOutput(out)
+	// The lines of the chunk read so far
+	var chunk []string
 
 	for {
-		fmt.Printf(PROMPT)
+		if len(chunk) == 0 {
+			io.WriteString(out, PROMPT)
+		} else {
+			io.WriteString(out, CONTINUATION_PROMPT)
+		}
+
 		scanned := scanner.Scan()
 		if !scanned {
+			// The input ended, e.g. Ctrl-D was pressed, so finish the prompt line before returning
+			// An incomplete chunk is still evaluated, so its errors are not lost
+			io.WriteString(out, "\n")
+			if len(chunk) != 0 {
+				evaluate(strings.Join(chunk, "\n"), env, out)
+			}
+			if err := scanner.Err(); err != nil {
+				io.WriteString(out, "error reading input: "+err.Error()+"\n")
+			}
 			return
 		}
 
 		line := scanner.Text()
 
-		l := lexer.New(line)
+		if len(chunk) == 0 {
+			switch strings.TrimSpace(line) {
+			case "exit", ":quit":
+				return
+			case "":
+				continue
+			}
+		}
+
+		chunk = append(chunk, line)
+		input := strings.Join(chunk, "\n")
+		if bracketDepth(input) > 0 {
+			continue
+		}
+
+		chunk = nil
+		evaluate(input, env, out)
+	}
+}
+
+func bracketDepth(input string) int {
+	// Count the (, {, [ and ${ tokens that are not closed yet
+	// For example, the depth of "let f = fn(x) {" is 1, as the parenthesis is closed but the brace is not
+	// Brackets inside strings and comments are not tokens, so they are not counted
+	depth := 0
 
-		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
-			fmt.Printf("%+v\n", tok)
+	for tok := range lexer.New(input).All() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET, token.INTERP_START:
+			depth += 1
+		case token.RPAREN, token.RBRACE, token.RBRACKET, token.INTERP_END:
+			depth -= 1
 		}
+	}
+
+	return depth
+}
+
+func evaluate(input string, env *object.Environment, out io.Writer) {
+	l := lexer.New(input)
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParserErrors(out, p.Errors())
+		return
+	}
+
+	evaluated := evaluator.Eval(program, env)
+	if evaluated != nil {
+		io.WriteString(out, evaluated.Inspect())
+		io.WriteString(out, "\n")
+	}
+}
 
+func printParserErrors(out io.Writer, errors []string) {
+	io.WriteString(out, "parser errors:\n")
+	for _, msg := range errors {
+		io.WriteString(out, "\t"+msg+"\n")
 	}
 }
@@ -0,0 +1,80 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestStart(t *testing.T) {
+	// This is a test function for the REPL driven by an in-memory reader and writer
+	// It checks everything written to out, prompts included
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"", ">> \n"},
+		{"5 + 5\n", ">> 10\n>> \n"},
+		{"5 + 5", ">> 10\n>> \n"},
+		{"let add = fn(x, y) { x + y; };\nadd(5, 10)\n", ">> >> 15\n>> \n"},
+		{"\n   \n1\n", ">> >> >> 1\n>> \n"},
+		{"let x = ;\n", ">> parser errors:\n\tno prefix parse function for ; found\n>> \n"},
+		{"foobar\n", ">> ERROR: identifier not found: foobar\n>> \n"},
+		{"let f = fn() { f() }; f()\n1\n", ">> ERROR: stack overflow\n>> 1\n>> \n"},
+		{"puts(\"hi\", 1)\n", ">> hi\n1\nnull\n>> \n"},
+		{"1\nexit\n2\n", ">> 1\n>> "},
+		{"1\n  :quit  \n2\n", ">> 1\n>> "},
+		{"exit", ">> "},
+	}
+
+	for i, tt := range tests {
+		var out bytes.Buffer
+		Start(strings.NewReader(tt.input), &out)
+
+		if out.String() != tt.expected {
+			t.Errorf("tests[%d] - output wrong. expected=%q, got=%q", i, tt.expected, out.String())
+		}
+	}
+}
+
+func TestStartMultiLine(t *testing.T) {
+	// This is a test function for input spanning several lines
+	// The continuation prompt is shown until every (, {, [ and ${ is closed, then the whole chunk is evaluated at once
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let add = fn(x, y) {\n  x + y;\n};\nadd(5, 10)\n", ">> .. .. >> 15\n>> \n"},
+		{"add(\n1,\n2\n)\n", ">> .. .. .. ERROR: identifier not found: add\n>> \n"},
+		{"if (1 < 2) {\n\n  10\n} else {\n  20\n}\n", ">> .. .. .. .. .. 10\n>> \n"},
+		{"fn() {\nexit\n}()\n1\n", ">> .. .. ERROR: identifier not found: exit\n>> 1\n>> \n"},
+		{`"{ (" + "("` + "\n", ">> { ((\n>> \n"},
+		{"1 + 1 /* { */\n", ">> 2\n>> \n"},
+		{"[1,\n{\"a\": 2}\n]\n", ">> .. .. [1, {a: 2}]\n>> \n"},
+		{"for (x in [1, 2]) {\nputs(x)\n}\n", ">> .. .. 1\n2\n>> \n"},
+		{"\"a ${\n1 + 1\n} b\"\n", ">> .. .. a 2 b\n>> \n"},
+		{"1)\n", ">> parser errors:\n\tno prefix parse function for ) found\n>> \n"},
+		{"fn(x) {\nx\n", ">> .. .. \nparser errors:\n\texpected next token to be }, got EOF instead\n"},
+	}
+
+	for i, tt := range tests {
+		var out bytes.Buffer
+		Start(strings.NewReader(tt.input), &out)
+
+		if out.String() != tt.expected {
+			t.Errorf("tests[%d] - output wrong. expected=%q, got=%q", i, tt.expected, out.String())
+		}
+	}
+}
+
+func TestStartKeepsBindings(t *testing.T) {
+	// This is a test function for bindings surviving from one line to the next
+	input := "let a = 2;\nlet double = fn(x) { x * a };\ndouble(21)\n"
+
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	if !strings.Contains(out.String(), "42\n") {
+		t.Errorf("output does not contain the result. got=%q", out.String())
+	}
+}
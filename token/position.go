@@ -0,0 +1,165 @@
+package token
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Position is a location in a source file, as it is shown to the user
+type Position struct {
+	Filename string // file name, if any
+	Offset   int    // byte offset, starting at 0
+	Line     int    // line number, starting at 1
+	Column   int    // column number in bytes, starting at 1
+}
+
+// IsValid reports whether the position was resolved from a valid Pos
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+// String returns the position as "file:line:column", or "line:column" for a source without a name
+// An invalid position is printed as "-"
+func (p Position) String() string {
+	if !p.IsValid() {
+		if p.Filename != "" {
+			return p.Filename
+		}
+		return "-"
+	}
+	if p.Filename != "" {
+		return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Pos is a compact encoding of a location in one of the files of a FileSet
+// It is turned back into a Position, with file name, line and column, by FileSet.Position or File.Position
+// The zero value NoPos is not a location at all
+//
+// Unlike go/token, a Pos does not need the size of the file up front, so files can be read as a stream:
+// the upper bits hold the index of the file in its FileSet, the lower bits the byte offset in the file
+type Pos int64
+
+const NoPos Pos = 0
+
+const (
+	offsetBits = 40
+	offsetMask = 1<<offsetBits - 1
+	// MaxFileSize is the size of the largest file a Pos can point into
+	MaxFileSize = offsetMask
+)
+
+// IsValid reports whether the Pos is a location, i.e. it is not NoPos
+func (p Pos) IsValid() bool { return p != NoPos }
+
+func (p Pos) fileIndex() int { return int(p >> offsetBits) }
+func (p Pos) offset() int    { return int(p & offsetMask) }
+
+// File is a source file added to a FileSet
+// It records where its lines start, which is what's needed to turn an offset into a line and a column
+type File struct {
+	name  string
+	index int // 1-based index in the FileSet
+
+	mu    sync.Mutex
+	lines []int // the offsets of the first character of every line, lines[0] is always 0
+}
+
+func (f *File) Name() string { return f.name }
+
+// LineCount returns the number of lines added so far
+func (f *File) LineCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.lines)
+}
+
+// AddLine records that a new line starts at the given offset, i.e. right after a newline
+// The offsets must be added in increasing order, an offset that is not larger than the last one is ignored
+func (f *File) AddLine(offset int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if offset > f.lines[len(f.lines)-1] {
+		f.lines = append(f.lines, offset)
+	}
+}
+
+// Pos returns the Pos of the byte offset in the file
+func (f *File) Pos(offset int) Pos {
+	if offset < 0 || offset > MaxFileSize {
+		panic(fmt.Sprintf("token: invalid file offset %d", offset))
+	}
+	return Pos(f.index)<<offsetBits | Pos(offset)
+}
+
+// Offset returns the byte offset of a Pos of the file
+func (f *File) Offset(p Pos) int {
+	if p.fileIndex() != f.index {
+		panic(fmt.Sprintf("token: Pos %d is not in file %s", p, f.name))
+	}
+	return p.offset()
+}
+
+// Position resolves a Pos of the file to its file name, line and column
+// For example, in a file starting with "let x\n= 5;" the offset 6 is line 2, column 1
+func (f *File) Position(p Pos) Position {
+	if !p.IsValid() || p.fileIndex() != f.index {
+		return Position{}
+	}
+
+	offset := p.offset()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	// The line is the last one starting at or before the offset
+	i := sort.SearchInts(f.lines, offset+1) - 1
+
+	return Position{
+		Filename: f.name,
+		Offset:   offset,
+		Line:     i + 1,
+		Column:   offset - f.lines[i] + 1,
+	}
+}
+
+// FileSet is a set of source files, e.g. all the files of a program
+// Every Pos from one of its files can be resolved by the FileSet alone
+type FileSet struct {
+	mu    sync.RWMutex
+	files []*File
+}
+
+func NewFileSet() *FileSet {
+	return &FileSet{}
+}
+
+// AddFile adds a new source file with the given name and returns it
+// The name is only used to show positions, it does not have to be unique
+func (s *FileSet) AddFile(filename string) *File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f := &File{name: filename, index: len(s.files) + 1, lines: []int{0}}
+	s.files = append(s.files, f)
+	return f
+}
+
+// File returns the file containing the Pos, or nil if there is none
+func (s *FileSet) File(p Pos) *File {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := p.fileIndex()
+	if !p.IsValid() || i < 1 || i > len(s.files) {
+		return nil
+	}
+	return s.files[i-1]
+}
+
+// Position resolves a Pos from any file of the set to its file name, line and column
+func (s *FileSet) Position(p Pos) Position {
+	if f := s.File(p); f != nil {
+		return f.Position(p)
+	}
+	return Position{}
+}
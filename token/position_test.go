@@ -0,0 +1,74 @@
+package token
+
+import "testing"
+
+func TestFileSetPosition(t *testing.T) {
+	// This is a test function for resolving a Pos to a file, a line and a column
+	// Two files are added to the same FileSet, and the positions of both must resolve through it
+	fset := NewFileSet()
+
+	// "let x = 5;\nlet y = x;\n"
+	main := fset.AddFile("main.mk")
+	main.AddLine(11)
+	main.AddLine(22)
+
+	// "\n\nputs(1)"
+	lib := fset.AddFile("lib.mk")
+	lib.AddLine(1)
+	lib.AddLine(2)
+
+	tests := []struct {
+		pos      Pos
+		expected string
+	}{
+		{main.Pos(0), "main.mk:1:1"},
+		{main.Pos(4), "main.mk:1:5"},
+		{main.Pos(10), "main.mk:1:11"},
+		{main.Pos(11), "main.mk:2:1"},
+		{main.Pos(15), "main.mk:2:5"},
+		{main.Pos(22), "main.mk:3:1"},
+		{lib.Pos(0), "lib.mk:1:1"},
+		{lib.Pos(1), "lib.mk:2:1"},
+		{lib.Pos(6), "lib.mk:3:5"},
+		{NoPos, "-"},
+		{Pos(3) << offsetBits, "-"},
+	}
+
+	for i, tt := range tests {
+		if got := fset.Position(tt.pos).String(); got != tt.expected {
+			t.Errorf("tests[%d] - position wrong. expected=%q, got=%q", i, tt.expected, got)
+		}
+	}
+
+	if fset.File(lib.Pos(3)) != lib {
+		t.Errorf("File returned the wrong file for a Pos of lib.mk")
+	}
+
+	if main.Offset(main.Pos(15)) != 15 {
+		t.Errorf("Offset wrong. expected=15, got=%d", main.Offset(main.Pos(15)))
+	}
+
+	if main.Pos(0) >= main.Pos(1) || main.Pos(100) >= lib.Pos(0) {
+		t.Errorf("positions are not ordered by file and offset")
+	}
+}
+
+func TestFilePositionWithoutName(t *testing.T) {
+	// This is a test function for a file without a name, as the REPL creates
+	// Its positions are printed as "line:column" only
+	f := NewFileSet().AddFile("")
+	f.AddLine(3)
+
+	pos := f.Position(f.Pos(5))
+	expected := Position{Offset: 5, Line: 2, Column: 3}
+
+	if pos != expected {
+		t.Errorf("position wrong. expected=%+v, got=%+v", expected, pos)
+	}
+	if pos.String() != "2:3" {
+		t.Errorf("string wrong. expected=%q, got=%q", "2:3", pos.String())
+	}
+	if f.LineCount() != 2 {
+		t.Errorf("line count wrong. expected=2, got=%d", f.LineCount())
+	}
+}
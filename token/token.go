@@ -1,60 +1,178 @@
 package token
 
-type TokenType string
+import (
+	"strconv"
+	"strings"
+)
+
+// TokenType is the kind of a token
+// It is a small integer, so comparing token types is cheap, and it prints as the old string names
+// For example, fmt.Sprint(ASSIGN) is "=" and fmt.Sprint(IDENT) is "IDENT"
+type TokenType int
+
+//go:generate stringer -type=TokenType -linecomment
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	// Pos is where the token starts and End is just past its last character
+	// They are resolved to a file, line and column by the FileSet the source file was added to
+	// For example, the "let" at the very beginning of main.mk starts at main.mk:1:1 and ends at main.mk:1:4
+	Pos Pos
+	End Pos
 }
 
+// The token types are grouped into literals, operators and keywords
+// The unexported constants mark the beginning and the end of every group, they are not token types themselves
+// The line comments are the names String returns
 const (
-	ILLEGAL = "ILLEGAL"
-	EOF     = "EOF"
+	ILLEGAL TokenType = iota
+	EOF
+	// Only emitted when the lexer is asked to keep comments
+	COMMENT
 
+	literal_beg
 	// Identifiers + literals
-	IDENT = "IDENT" // add, foobar, x, y, ...
-	INT   = "INT"   // 1343456
+	// add, foobar, x, y, ...
+	IDENT
+	// 1343456, 0xFF, 0o17, 0b1010, 1_000_000
+	INT
+	// 3.14, 1.5e-3
+	FLOAT
+	// "foo bar"
+	STRING
+	// The text of an interpolated string up to a "${", e.g. "Hello " in "Hello ${name}!"
+	// The text after the last interpolation is a STRING, so an interpolated string always ends with one
+	STRING_PART
+	literal_end
 
+	operator_beg
 	// Operators
-	ASSIGN   = "="
-	PLUS     = "+"
-	MINUS    = "-"
-	BANG     = "!"
-	ASTERISK = "*"
-	SLASH    = "/"
-	EQ       = "=="
-	NOT_EQ   = "!="
-
-	LT = "<"
-	GT = ">"
+	ASSIGN   // =
+	PLUS     // +
+	MINUS    // -
+	BANG     // !
+	ASTERISK // *
+	SLASH    // /
+	PERCENT  // %
+	POWER    // **
+	EQ       // ==
+	NOT_EQ   // !=
+
+	LT    // <
+	GT    // >
+	LT_EQ // <=
+	GT_EQ // >=
+
+	AND // &&
+	OR  // ||
+
+	PLUS_ASSIGN     // +=
+	MINUS_ASSIGN    // -=
+	ASTERISK_ASSIGN // *=
+	SLASH_ASSIGN    // /=
 
 	// Delimiters
-	COMMA     = ","
-	SEMICOLON = ";"
+	COMMA     // ,
+	SEMICOLON // ;
+	COLON     // :
 
-	LPAREN = "("
-	RPAREN = ")"
-	LBRACE = "{"
-	RBRACE = "}"
+	LPAREN   // (
+	RPAREN   // )
+	LBRACE   // {
+	RBRACE   // }
+	LBRACKET // [
+	RBRACKET // ]
 
+	// The delimiters of an expression embedded in a string, e.g. "${" and "}" in "Hello ${name}!"
+	INTERP_START // ${
+	INTERP_END   // }
+	operator_end
+
+	keyword_beg
 	// Keywords
-	FUNCTION = "FUNCTION"
-	LET      = "LET"
-	TRUE     = "TRUE"
-	FALSE    = "FALSE"
-	IF       = "IF"
-	ELSE     = "ELSE"
-	RETURN   = "RETURN"
+	FUNCTION
+	LET
+	TRUE
+	FALSE
+	IF
+	ELSE
+	RETURN
+	WHILE
+	FOR
+	IN
+	BREAK
+	CONTINUE
+	keyword_end
 )
 
+// IsLiteral reports whether the token type is an identifier or a literal, e.g. IDENT or STRING
+func (t TokenType) IsLiteral() bool { return literal_beg < t && t < literal_end }
+
+// IsOperator reports whether the token type is an operator or a delimiter, e.g. PLUS or LPAREN
+func (t TokenType) IsOperator() bool { return operator_beg < t && t < operator_end }
+
+// IsKeyword reports whether the token type is a keyword, e.g. LET or RETURN
+func (t TokenType) IsKeyword() bool { return keyword_beg < t && t < keyword_end }
+
+// The precedences of the operators, from the lowest to the highest
+// For example, "5 + 5 * 10" is parsed as "(5 + (5 * 10))" because ProductPrec is higher than SumPrec
+const (
+	_ int = iota
+	LowestPrec
+	OrPrec          // ||
+	AndPrec         // &&
+	EqualsPrec      // ==
+	LessGreaterPrec // > or <
+	SumPrec         // +
+	ProductPrec     // *
+	PrefixPrec      // -X or !X
+	PowerPrec       // **
+	CallPrec        // myFunction(X)
+	IndexPrec       // array[index]
+)
+
+var precedences = [...]int{
+	OR:       OrPrec,
+	AND:      AndPrec,
+	EQ:       EqualsPrec,
+	NOT_EQ:   EqualsPrec,
+	LT:       LessGreaterPrec,
+	GT:       LessGreaterPrec,
+	LT_EQ:    LessGreaterPrec,
+	GT_EQ:    LessGreaterPrec,
+	PLUS:     SumPrec,
+	MINUS:    SumPrec,
+	SLASH:    ProductPrec,
+	ASTERISK: ProductPrec,
+	PERCENT:  ProductPrec,
+	POWER:    PowerPrec,
+	LPAREN:   CallPrec,
+	LBRACKET: IndexPrec,
+}
+
+// Precedence returns how tightly the token binds as an infix operator
+// Tokens that are not infix operators have the LowestPrec
+func (t TokenType) Precedence() int {
+	if 0 <= t && int(t) < len(precedences) && precedences[t] != 0 {
+		return precedences[t]
+	}
+	return LowestPrec
+}
+
 var keyword = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
 }
 
 func LookupIdent(ident string) TokenType {
@@ -64,3 +182,18 @@ func LookupIdent(ident string) TokenType {
 	}
 	return IDENT
 }
+
+// ParseInt parses the literal of an INT token
+// A "0x", "0o" or "0b" prefix gives the base, everything else is decimal with the underscores between the digits left out
+// A leading zero alone does not mean octal, so "010" and "0_10" are both ten
+func ParseInt(literal string) (int64, error) {
+	digits := strings.TrimLeft(literal, "+-")
+	if len(digits) > 2 && digits[0] == '0' && strings.IndexByte("xXoObB", digits[1]) >= 0 {
+		return strconv.ParseInt(literal, 0, 64)
+	}
+
+	if strings.HasPrefix(digits, "_") || strings.HasSuffix(digits, "_") || strings.Contains(digits, "__") {
+		return 0, &strconv.NumError{Func: "ParseInt", Num: literal, Err: strconv.ErrSyntax}
+	}
+	return strconv.ParseInt(strings.ReplaceAll(literal, "_", ""), 10, 64)
+}
@@ -0,0 +1,160 @@
+package token
+
+import "testing"
+
+func TestTokenTypeString(t *testing.T) {
+	// This is a test function for the names of the token types
+	// They must stay the same as when TokenType was a string, as they appear in error messages
+	tests := []struct {
+		tokenType TokenType
+		expected  string
+	}{
+		{ILLEGAL, "ILLEGAL"},
+		{EOF, "EOF"},
+		{IDENT, "IDENT"},
+		{STRING, "STRING"},
+		{ASSIGN, "="},
+		{POWER, "**"},
+		{NOT_EQ, "!="},
+		{SLASH_ASSIGN, "/="},
+		{RBRACE, "}"},
+		{LBRACKET, "["},
+		{FUNCTION, "FUNCTION"},
+		{RETURN, "RETURN"},
+		{TokenType(-1), "TokenType(-1)"},
+	}
+
+	for i, tt := range tests {
+		if tt.tokenType.String() != tt.expected {
+			t.Errorf("tests[%d] - name wrong. expected=%q, got=%q", i, tt.expected, tt.tokenType.String())
+		}
+	}
+}
+
+func TestTokenTypeCategories(t *testing.T) {
+	// This is a test function for the predicates telling the category of a token type
+	tests := []struct {
+		tokenType  TokenType
+		isLiteral  bool
+		isOperator bool
+		isKeyword  bool
+	}{
+		{ILLEGAL, false, false, false},
+		{EOF, false, false, false},
+		{COMMENT, false, false, false},
+		{IDENT, true, false, false},
+		{INT, true, false, false},
+		{FLOAT, true, false, false},
+		{STRING, true, false, false},
+		{ASSIGN, false, true, false},
+		{AND, false, true, false},
+		{SEMICOLON, false, true, false},
+		{RBRACE, false, true, false},
+		{FUNCTION, false, false, true},
+		{RETURN, false, false, true},
+		{CONTINUE, false, false, true},
+	}
+
+	for i, tt := range tests {
+		if tt.tokenType.IsLiteral() != tt.isLiteral {
+			t.Errorf("tests[%d] - %s.IsLiteral() wrong. expected=%t", i, tt.tokenType, tt.isLiteral)
+		}
+		if tt.tokenType.IsOperator() != tt.isOperator {
+			t.Errorf("tests[%d] - %s.IsOperator() wrong. expected=%t", i, tt.tokenType, tt.isOperator)
+		}
+		if tt.tokenType.IsKeyword() != tt.isKeyword {
+			t.Errorf("tests[%d] - %s.IsKeyword() wrong. expected=%t", i, tt.tokenType, tt.isKeyword)
+		}
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	// This is a test function for the precedence table
+	tests := []struct {
+		tokenType TokenType
+		expected  int
+	}{
+		{OR, OrPrec},
+		{AND, AndPrec},
+		{EQ, EqualsPrec},
+		{GT_EQ, LessGreaterPrec},
+		{MINUS, SumPrec},
+		{PERCENT, ProductPrec},
+		{POWER, PowerPrec},
+		{LPAREN, CallPrec},
+		{LBRACKET, IndexPrec},
+		{ASSIGN, LowestPrec},
+		{IDENT, LowestPrec},
+		{RETURN, LowestPrec},
+		{TokenType(-1), LowestPrec},
+	}
+
+	for i, tt := range tests {
+		if tt.tokenType.Precedence() != tt.expected {
+			t.Errorf("tests[%d] - %s.Precedence() wrong. expected=%d, got=%d",
+				i, tt.tokenType, tt.expected, tt.tokenType.Precedence())
+		}
+	}
+}
+
+func TestLookupIdent(t *testing.T) {
+	// This is a test function for telling keywords apart from identifiers
+	tests := []struct {
+		ident    string
+		expected TokenType
+	}{
+		{"fn", FUNCTION},
+		{"let", LET},
+		{"return", RETURN},
+		{"while", WHILE},
+		{"for", FOR},
+		{"in", IN},
+		{"break", BREAK},
+		{"continue", CONTINUE},
+		{"foobar", IDENT},
+		{"inside", IDENT},
+		{"Let", IDENT},
+	}
+
+	for i, tt := range tests {
+		if got := LookupIdent(tt.ident); got != tt.expected {
+			t.Errorf("tests[%d] - LookupIdent(%q) wrong. expected=%s, got=%s", i, tt.ident, tt.expected, got)
+		}
+	}
+}
+
+func TestParseInt(t *testing.T) {
+	// This is a test function for parsing integer literals, with an error expected when ok is false
+	tests := []struct {
+		literal  string
+		expected int64
+		ok       bool
+	}{
+		{"10", 10, true},
+		{"010", 10, true},
+		{"0_10", 10, true},
+		{"0_0", 0, true},
+		{"1_000", 1000, true},
+		{"-42", -42, true},
+		{"0xFF", 255, true},
+		{"0o17", 15, true},
+		{"0b1010", 10, true},
+		{"-0x10", -16, true},
+		{"_1", 0, false},
+		{"1_", 0, false},
+		{"1__0", 0, false},
+		{"0x", 0, false},
+		{"abc", 0, false},
+	}
+
+	for i, tt := range tests {
+		got, err := ParseInt(tt.literal)
+		if (err == nil) != tt.ok {
+			t.Errorf("tests[%d] - ParseInt(%q) wrong error. got=%v", i, tt.literal, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("tests[%d] - ParseInt(%q) wrong. expected=%d, got=%d", i, tt.literal, tt.expected, got)
+		}
+	}
+}
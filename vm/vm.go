@@ -0,0 +1,843 @@
+package vm
+
+import (
+	"fmt"
+	"interpreter/code"
+	"interpreter/compiler"
+	"interpreter/object"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+const StackSize = 2048
+const GlobalsSize = 65536
+const MaxFrames = 1024
+
+// There is only ever one true, one false and one null, like in the evaluator
+var (
+	True  = &object.Boolean{Value: true}
+	False = &object.Boolean{Value: false}
+	Null  = &object.Null{}
+)
+
+// VM runs the bytecode produced by the compiler on a stack
+// For example, running "1 + 2" pushes 1 and 2, and OpAdd replaces them with 3
+type VM struct {
+	constants []object.Object
+
+	stack []object.Object
+	sp    int // Always points to the next free slot, so the top of the stack is stack[sp-1]
+
+	globals []object.Object
+	// The names of the globals, for the error of a global read before it is set
+	globalNames []string
+
+	frames      []*Frame
+	framesIndex int
+
+	out io.Writer
+
+	// How many times every opcode was run, to compare programs and backends
+	counts [256]int
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	// The main program is run as the body of a closure without parameters
+	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions}
+	mainClosure := &object.Closure{Fn: mainFn}
+	mainFrame := NewFrame(mainClosure, 0)
+
+	frames := make([]*Frame, MaxFrames)
+	frames[0] = mainFrame
+
+	return &VM{
+		constants: bytecode.Constants,
+
+		stack: make([]object.Object, StackSize),
+		sp:    0,
+
+		globals:     make([]object.Object, GlobalsSize),
+		globalNames: bytecode.GlobalNames,
+
+		frames:      frames,
+		framesIndex: 1,
+
+		out: os.Stdout,
+	}
+}
+
+// NewWithGlobalsStore creates a VM that uses the globals of a previous one
+// It goes together with compiler.NewWithState
+func NewWithGlobalsStore(bytecode *compiler.Bytecode, s []object.Object) *VM {
+	vm := New(bytecode)
+	vm.globals = s
+	return vm
+}
+
+// SetOutput sets where the built-in functions print to, os.Stdout by default
+func (vm *VM) SetOutput(w io.Writer) {
+	vm.out = w
+}
+
+// LastPoppedStackElem returns the value last popped off the stack
+// After Run, it is the value of the last expression statement of the program
+// It is nil when Run stopped with a stack overflow, as the stack pointer is then beyond the stack
+func (vm *VM) LastPoppedStackElem() object.Object {
+	if vm.sp >= StackSize {
+		return nil
+	}
+	return vm.stack[vm.sp]
+}
+
+// OpcodeCounts returns how many times every opcode was run, by the name of the opcode
+// Opcodes that were never run are left out
+func (vm *VM) OpcodeCounts() map[string]int {
+	counts := map[string]int{}
+	for op, n := range vm.counts {
+		if n == 0 {
+			continue
+		}
+		if def, err := code.Lookup(byte(op)); err == nil {
+			counts[def.Name] = n
+		}
+	}
+	return counts
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) error {
+	if vm.framesIndex >= MaxFrames {
+		return fmt.Errorf("stack overflow")
+	}
+	vm.frames[vm.framesIndex] = f
+	vm.framesIndex++
+	return nil
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+// Run runs the program until its end or the first runtime error
+// The errors have the same messages as the errors of the evaluator
+func (vm *VM) Run() error {
+	var ip int
+	var ins code.Instructions
+	var op code.Opcode
+
+	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.currentFrame().ip++
+
+		ip = vm.currentFrame().ip
+		ins = vm.currentFrame().Instructions()
+		op = code.Opcode(ins[ip])
+		vm.counts[op]++
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			if err := vm.push(vm.constants[constIndex]); err != nil {
+				return err
+			}
+
+		case code.OpPop:
+			vm.pop()
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod, code.OpPow,
+			code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpGreaterEqual, code.OpLessThan, code.OpLessEqual:
+			if err := vm.executeBinaryOperation(op); err != nil {
+				return err
+			}
+
+		case code.OpTrue:
+			if err := vm.push(True); err != nil {
+				return err
+			}
+
+		case code.OpFalse:
+			if err := vm.push(False); err != nil {
+				return err
+			}
+
+		case code.OpNull:
+			if err := vm.push(Null); err != nil {
+				return err
+			}
+
+		case code.OpBang:
+			if err := vm.push(nativeBoolToBooleanObject(!IsTruthy(vm.pop()))); err != nil {
+				return err
+			}
+
+		case code.OpMinus:
+			if err := vm.executeMinusOperator(); err != nil {
+				return err
+			}
+
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			// The loop increments ip before the next instruction is read
+			vm.currentFrame().ip = pos - 1
+
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			if !IsTruthy(vm.pop()) {
+				vm.currentFrame().ip = pos - 1
+			}
+
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			vm.globals[globalIndex] = vm.pop()
+
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			// A global is only unset when the statement that binds it didn't run, like "let" in a loop that never ran
+			value := vm.globals[globalIndex]
+			if value == nil {
+				return NotFound(vm.globalNames, int(globalIndex))
+			}
+			if err := vm.push(value); err != nil {
+				return err
+			}
+
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			vm.stack[frame.basePointer+int(localIndex)] = vm.pop()
+
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			value := vm.stack[frame.basePointer+int(localIndex)]
+			if value == nil {
+				return NotFound(frame.cl.Fn.LocalNames, int(localIndex))
+			}
+			if err := vm.push(value); err != nil {
+				return err
+			}
+
+		case code.OpGetBuiltin:
+			builtinIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			if err := vm.push(object.Builtins[builtinIndex].Builtin); err != nil {
+				return err
+			}
+
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			value, err := GetFree(vm.currentFrame().cl.Free[freeIndex])
+			if err != nil {
+				return err
+			}
+			if err := vm.push(value); err != nil {
+				return err
+			}
+
+		case code.OpCurrentClosure:
+			if err := vm.push(vm.currentFrame().cl); err != nil {
+				return err
+			}
+
+		case code.OpGetCell:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			value, err := GetCell(vm.stack[frame.basePointer+int(localIndex)], frame.cl.Fn.LocalNames, int(localIndex))
+			if err != nil {
+				return err
+			}
+			if err := vm.push(value); err != nil {
+				return err
+			}
+
+		case code.OpSetCell:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			SetCell(&vm.stack[frame.basePointer+int(localIndex)], vm.pop())
+
+		case code.OpCaptureLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			cell := Capture(&vm.stack[frame.basePointer+int(localIndex)], frame.cl.Fn.LocalNames, int(localIndex))
+			if err := vm.push(cell); err != nil {
+				return err
+			}
+
+		case code.OpCaptureFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			if err := vm.push(vm.currentFrame().cl.Free[freeIndex]); err != nil {
+				return err
+			}
+
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			elements := make([]object.Object, numElements)
+			copy(elements, vm.stack[vm.sp-numElements:vm.sp])
+			vm.sp = vm.sp - numElements
+
+			if err := vm.push(&object.Array{Elements: elements}); err != nil {
+				return err
+			}
+
+		case code.OpHash:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			hash, err := BuildHash(vm.stack[vm.sp-numElements : vm.sp])
+			if err != nil {
+				return err
+			}
+			vm.sp = vm.sp - numElements
+
+			if err := vm.push(hash); err != nil {
+				return err
+			}
+
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+
+			result, err := Index(left, index)
+			if err != nil {
+				return err
+			}
+
+			if err := vm.push(result); err != nil {
+				return err
+			}
+
+		case code.OpInterpolate:
+			numParts := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			str := Interpolate(vm.stack[vm.sp-numParts : vm.sp])
+			vm.sp = vm.sp - numParts
+
+			if err := vm.push(str); err != nil {
+				return err
+			}
+
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			if err := vm.executeCall(int(numArgs)); err != nil {
+				return err
+			}
+
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+
+			// A return in the main program stops it, with the value as the last popped one
+			if vm.framesIndex == 1 {
+				return nil
+			}
+
+			frame := vm.popFrame()
+			// Drop the locals and the closure itself
+			vm.sp = frame.basePointer - 1
+
+			if err := vm.push(returnValue); err != nil {
+				return err
+			}
+
+		case code.OpReturn:
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+
+			if err := vm.push(Null); err != nil {
+				return err
+			}
+
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			numFree := code.ReadUint8(ins[ip+3:])
+			vm.currentFrame().ip += 3
+
+			if err := vm.pushClosure(int(constIndex), int(numFree)); err != nil {
+				return err
+			}
+
+		case code.OpIter:
+			iterable := vm.pop()
+
+			iterator, ok := object.NewIterator(iterable)
+			if !ok {
+				return fmt.Errorf("cannot iterate over %s", iterable.Type())
+			}
+
+			if err := vm.push(iterator); err != nil {
+				return err
+			}
+
+		case code.OpIterNext:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			iterator := vm.stack[vm.sp-1].(*object.Iterator)
+			element, ok := iterator.Next()
+			if !ok {
+				vm.pop()
+				vm.currentFrame().ip = pos - 1
+				continue
+			}
+
+			if err := vm.push(element); err != nil {
+				return err
+			}
+
+		default:
+			def, err := code.Lookup(byte(op))
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("opcode %s not supported", def.Name)
+		}
+	}
+
+	return nil
+}
+
+func (vm *VM) push(o object.Object) error {
+	if vm.sp >= StackSize {
+		return fmt.Errorf("stack overflow")
+	}
+
+	vm.stack[vm.sp] = o
+	vm.sp++
+
+	return nil
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+// The operators of the binary opcodes, for the error messages
+var operators = map[code.Opcode]string{
+	code.OpAdd:          "+",
+	code.OpSub:          "-",
+	code.OpMul:          "*",
+	code.OpDiv:          "/",
+	code.OpMod:          "%",
+	code.OpPow:          "**",
+	code.OpEqual:        "==",
+	code.OpNotEqual:     "!=",
+	code.OpGreaterThan:  ">",
+	code.OpGreaterEqual: ">=",
+	code.OpLessThan:     "<",
+	code.OpLessEqual:    "<=",
+}
+
+func (vm *VM) executeBinaryOperation(op code.Opcode) error {
+	right := vm.pop()
+	left := vm.pop()
+
+	result, err := BinaryOperation(op, left, right)
+	if err != nil {
+		return err
+	}
+
+	return vm.push(result)
+}
+
+// BinaryOperation applies the operator of a binary opcode to two values
+// The same operands give the same result as in the evaluator
+func BinaryOperation(op code.Opcode, left, right object.Object) (object.Object, error) {
+	switch {
+	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
+		return integerOperation(op, left.(*object.Integer).Value, right.(*object.Integer).Value)
+	case isNumber(left) && isNumber(right):
+		return floatOperation(op, toFloat(left), toFloat(right))
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return stringOperation(op, left.(*object.String).Value, right.(*object.String).Value)
+	// Booleans and null are shared, so comparing the pointers is enough
+	case op == code.OpEqual:
+		return nativeBoolToBooleanObject(left == right), nil
+	case op == code.OpNotEqual:
+		return nativeBoolToBooleanObject(left != right), nil
+	case left.Type() != right.Type():
+		return nil, fmt.Errorf("type mismatch: %s %s %s", left.Type(), operators[op], right.Type())
+	default:
+		return nil, fmt.Errorf("unknown operator: %s %s %s", left.Type(), operators[op], right.Type())
+	}
+}
+
+func integerOperation(op code.Opcode, left, right int64) (object.Object, error) {
+	switch op {
+	case code.OpAdd:
+		return &object.Integer{Value: left + right}, nil
+	case code.OpSub:
+		return &object.Integer{Value: left - right}, nil
+	case code.OpMul:
+		return &object.Integer{Value: left * right}, nil
+	case code.OpDiv:
+		if right == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return &object.Integer{Value: left / right}, nil
+	case code.OpMod:
+		if right == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return &object.Integer{Value: left % right}, nil
+	case code.OpPow:
+		if right < 0 {
+			// A negative exponent gives a fraction, so the result is a float
+			return &object.Float{Value: math.Pow(float64(left), float64(right))}, nil
+		}
+		return &object.Integer{Value: integerPower(left, right)}, nil
+	case code.OpEqual:
+		return nativeBoolToBooleanObject(left == right), nil
+	case code.OpNotEqual:
+		return nativeBoolToBooleanObject(left != right), nil
+	case code.OpGreaterThan:
+		return nativeBoolToBooleanObject(left > right), nil
+	case code.OpGreaterEqual:
+		return nativeBoolToBooleanObject(left >= right), nil
+	case code.OpLessThan:
+		return nativeBoolToBooleanObject(left < right), nil
+	case code.OpLessEqual:
+		return nativeBoolToBooleanObject(left <= right), nil
+	default:
+		return nil, fmt.Errorf("unknown operator: INTEGER %s INTEGER", operators[op])
+	}
+}
+
+func integerPower(base, exponent int64) int64 {
+	// Exponentiation by squaring, e.g. 3 ** 5 is 3 * (3 ** 2) ** 2
+	result := int64(1)
+	for exponent > 0 {
+		if exponent&1 == 1 {
+			result *= base
+		}
+		base *= base
+		exponent >>= 1
+	}
+	return result
+}
+
+func floatOperation(op code.Opcode, left, right float64) (object.Object, error) {
+	switch op {
+	case code.OpAdd:
+		return &object.Float{Value: left + right}, nil
+	case code.OpSub:
+		return &object.Float{Value: left - right}, nil
+	case code.OpMul:
+		return &object.Float{Value: left * right}, nil
+	case code.OpDiv:
+		if right == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return &object.Float{Value: left / right}, nil
+	case code.OpMod:
+		if right == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return &object.Float{Value: math.Mod(left, right)}, nil
+	case code.OpPow:
+		return &object.Float{Value: math.Pow(left, right)}, nil
+	case code.OpEqual:
+		return nativeBoolToBooleanObject(left == right), nil
+	case code.OpNotEqual:
+		return nativeBoolToBooleanObject(left != right), nil
+	case code.OpGreaterThan:
+		return nativeBoolToBooleanObject(left > right), nil
+	case code.OpGreaterEqual:
+		return nativeBoolToBooleanObject(left >= right), nil
+	case code.OpLessThan:
+		return nativeBoolToBooleanObject(left < right), nil
+	case code.OpLessEqual:
+		return nativeBoolToBooleanObject(left <= right), nil
+	default:
+		return nil, fmt.Errorf("unknown operator: FLOAT %s FLOAT", operators[op])
+	}
+}
+
+func stringOperation(op code.Opcode, left, right string) (object.Object, error) {
+	switch op {
+	case code.OpAdd:
+		return &object.String{Value: left + right}, nil
+	case code.OpEqual:
+		return nativeBoolToBooleanObject(left == right), nil
+	case code.OpNotEqual:
+		return nativeBoolToBooleanObject(left != right), nil
+	default:
+		return nil, fmt.Errorf("unknown operator: STRING %s STRING", operators[op])
+	}
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+func toFloat(obj object.Object) float64 {
+	if integer, ok := obj.(*object.Integer); ok {
+		return float64(integer.Value)
+	}
+	return obj.(*object.Float).Value
+}
+
+func (vm *VM) executeMinusOperator() error {
+	result, err := Minus(vm.pop())
+	if err != nil {
+		return err
+	}
+
+	return vm.push(result)
+}
+
+// Minus negates a number
+func Minus(operand object.Object) (object.Object, error) {
+	switch operand := operand.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: -operand.Value}, nil
+	case *object.Float:
+		return &object.Float{Value: -operand.Value}, nil
+	default:
+		return nil, fmt.Errorf("unknown operator: -%s", operand.Type())
+	}
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return True
+	}
+	return False
+}
+
+// IsTruthy tells if a value counts as true in a condition, only false and null are falsy
+func IsTruthy(obj object.Object) bool {
+	switch obj := obj.(type) {
+	case *object.Boolean:
+		return obj.Value
+	case *object.Null:
+		return false
+	default:
+		return true
+	}
+}
+
+// BuildHash builds a hash from keys and values that alternate, in the order the pairs are written
+func BuildHash(elements []object.Object) (object.Object, error) {
+	hash := object.NewHash()
+
+	for i := 0; i < len(elements); i += 2 {
+		key := elements[i]
+		value := elements[i+1]
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+		}
+
+		hash.Set(hashKey, value)
+	}
+
+	return hash, nil
+}
+
+// Index returns the element of an array or the value of a hash, null for a key that is not in the hash
+func Index(left, index object.Object) (object.Object, error) {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		elements := left.(*object.Array).Elements
+		i := index.(*object.Integer).Value
+		if i < 0 || i >= int64(len(elements)) {
+			return nil, fmt.Errorf("index out of range: %d (length %d)", i, len(elements))
+		}
+		return elements[i], nil
+
+	case left.Type() == object.HASH_OBJ:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return nil, fmt.Errorf("unusable as hash key: %s", index.Type())
+		}
+
+		value, ok := left.(*object.Hash).Get(key)
+		if !ok {
+			return Null, nil
+		}
+		return value, nil
+
+	default:
+		return nil, fmt.Errorf("index operator not supported: %s[%s]", left.Type(), index.Type())
+	}
+}
+
+// Interpolate joins values into a string, a string is inserted as it is and any other value as it is inspected
+func Interpolate(parts []object.Object) *object.String {
+	var out strings.Builder
+
+	for _, part := range parts {
+		if str, ok := part.(*object.String); ok {
+			out.WriteString(str.Value)
+		} else {
+			out.WriteString(part.Inspect())
+		}
+	}
+
+	return &object.String{Value: out.String()}
+}
+
+func (vm *VM) executeCall(numArgs int) error {
+	// The function is below its arguments on the stack
+	callee := vm.stack[vm.sp-1-numArgs]
+
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs)
+	case *object.Builtin:
+		return vm.callBuiltin(callee, numArgs)
+	default:
+		return fmt.Errorf("not a function: %s", callee.Type())
+	}
+}
+
+func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
+	if numArgs != cl.Fn.NumParameters {
+		return fmt.Errorf("wrong number of arguments: want=%d, got=%d", cl.Fn.NumParameters, numArgs)
+	}
+
+	// The arguments become the first locals, the other locals are reserved above them
+	frame := NewFrame(cl, vm.sp-numArgs)
+	if err := vm.pushFrame(frame); err != nil {
+		return err
+	}
+
+	vm.sp = frame.basePointer + cl.Fn.NumLocals
+	if vm.sp >= StackSize {
+		return fmt.Errorf("stack overflow")
+	}
+
+	// The other locals are cleared, so a local that isn't set yet doesn't read what a previous call left there,
+	// nor share the cell of a local of a previous call
+	for i := frame.basePointer + numArgs; i < vm.sp; i++ {
+		vm.stack[i] = nil
+	}
+
+	return nil
+}
+
+// NotFound returns the error of a variable read before it is set, with the message of the evaluator
+// The names are the names of the globals or the locals, by index
+func NotFound(names []string, index int) error {
+	if index >= len(names) {
+		return fmt.Errorf("identifier not found")
+	}
+	return fmt.Errorf("identifier not found: %s", names[index])
+}
+
+// GetCell returns the value of a local that may be kept in a cell, or the error of reading it before it is set
+func GetCell(local object.Object, names []string, index int) (object.Object, error) {
+	if cell, ok := local.(*object.Cell); ok {
+		local = cell.Value
+	}
+	if local == nil {
+		return nil, NotFound(names, index)
+	}
+	return local, nil
+}
+
+// SetCell stores the value in a local, in its cell if a closure captured it
+func SetCell(local *object.Object, value object.Object) {
+	if cell, ok := (*local).(*object.Cell); ok {
+		cell.Value = value
+		return
+	}
+	*local = value
+}
+
+// Capture returns the cell of a local for a closure, and puts the local in a new cell first if it is not in one
+// The local may not be set yet, e.g. when a function calls a function bound after it
+func Capture(local *object.Object, names []string, index int) *object.Cell {
+	if cell, ok := (*local).(*object.Cell); ok {
+		return cell
+	}
+
+	cell := &object.Cell{Value: *local}
+	if index < len(names) {
+		cell.Name = names[index]
+	}
+	*local = cell
+	return cell
+}
+
+// GetFree returns the value of a free variable of a closure, or the error of reading it before it is set
+// A free variable is the cell of a captured local, or the closure itself when it captured its own name
+func GetFree(free object.Object) (object.Object, error) {
+	cell, ok := free.(*object.Cell)
+	if !ok {
+		return free, nil
+	}
+	if cell.Value == nil {
+		return nil, fmt.Errorf("identifier not found: %s", cell.Name)
+	}
+	return cell.Value, nil
+}
+
+func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
+	args := vm.stack[vm.sp-numArgs : vm.sp]
+
+	result := builtin.Fn(vm.out, args...)
+	vm.sp = vm.sp - numArgs - 1
+
+	// An error of a built-in function stops the program like any other runtime error
+	if err, ok := result.(*object.Error); ok {
+		return fmt.Errorf("%s", err.Message)
+	}
+
+	if result != nil {
+		return vm.push(result)
+	}
+	return vm.push(Null)
+}
+
+func (vm *VM) pushClosure(constIndex int, numFree int) error {
+	constant := vm.constants[constIndex]
+	function, ok := constant.(*object.CompiledFunction)
+	if !ok {
+		return fmt.Errorf("not a function: %+v", constant)
+	}
+
+	// The free variables are on top of the stack, in the order of the FreeSymbols of the function
+	// They are the cells of the captured locals, so the closure sees the later changes of the locals
+	free := make([]object.Object, numFree)
+	copy(free, vm.stack[vm.sp-numFree:vm.sp])
+	vm.sp = vm.sp - numFree
+
+	closure := &object.Closure{Fn: function, Free: free}
+	return vm.push(closure)
+}
@@ -0,0 +1,370 @@
+package vm
+
+import (
+	"bytes"
+	"interpreter/ast"
+	"interpreter/compiler"
+	"interpreter/evaluator"
+	"interpreter/lexer"
+	"interpreter/object"
+	"interpreter/parser"
+	"testing"
+)
+
+type vmTestCase struct {
+	input    string
+	expected interface{}
+}
+
+func TestArithmetic(t *testing.T) {
+	// This is a test function for running operators
+	tests := []vmTestCase{
+		{"1", 1},
+		{"1 + 2", 3},
+		{"50 / 2 * 2 + 10 - 5", 55},
+		{"5 * (2 + 10)", 60},
+		{"-50 + 100 + -50", 0},
+		{"-7 % 3", -1},
+		{"2 ** 3 ** 2", 512},
+		{"1 + 0.5", 1.5},
+		{"2 ** -1", 0.5},
+		{"7.5 % 2", 1.5},
+		{"1 < 2", true},
+		{"1 >= 2", false},
+		{"2.5 > 2", true},
+		{"(1 < 2) == true", true},
+		{"!5", false},
+		{"!!false", false},
+		{"!(if (false) { 5; })", true},
+		{`"a" + "b" == "ab"`, true},
+		{"true && 0", true},
+		{"false || 1 > 2", false},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	// This is a test function for running if expressions
+	tests := []vmTestCase{
+		{"if (true) { 10 }", 10},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if (1 > 2) { 10 }", nil},
+		{"if (true) { let a = 1; }", nil},
+		{"if ((if (false) { 10 })) { 10 } else { 20 }", 20},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestGlobalsAndCollections(t *testing.T) {
+	// This is a test function for globals, strings, arrays, hashes and index expressions
+	tests := []vmTestCase{
+		{"let one = 1; let two = one + one; one + two", 3},
+		{"let i = 1; let i = i + 1; i", 2},
+		{"let i = 1; i += 2; i *= 3; i", 9},
+		{`let name = "Monkey"; "Hello, ${name}! ${1 + 1}"`, "Hello, Monkey! 2"},
+		{`"${[1, "a"]}"`, "[1, a]"},
+		{"[1, 2 * 2, 3][1]", 4},
+		{"[[1, 2]][0][1]", 2},
+		{`{"a": 1, 2: "b"}[2]`, "b"},
+		{`{"a": 1}["b"]`, nil},
+		{"[1, 2, 3][3]", "ERROR: index out of range: 3 (length 3)"},
+		{"{[1]: 2}", "ERROR: unusable as hash key: ARRAY"},
+		{"1[0]", "ERROR: index operator not supported: INTEGER[INTEGER]"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestFunctions(t *testing.T) {
+	// This is a test function for calling functions, closures and built-in functions
+	tests := []vmTestCase{
+		{"let f = fn() { 5 + 10 }; f()", 15},
+		{"let f = fn(a, b) { let c = a + b; c * 2 }; f(1, 2)", 6},
+		{"let f = fn() { return 1; 2 }; f()", 1},
+		{"let f = fn() { }; f()", nil},
+		{"let f = fn() { let a = 1; }; f()", nil},
+		{"let g = 5; let f = fn() { let g = 1; g }; f() + g", 6},
+		{"let adder = fn(a) { fn(b) { a + b } }; adder(2)(3)", 5},
+		{"let f = fn(a) { fn(b) { fn(c) { a + b + c } } }; f(1)(2)(3)", 6},
+		{"let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(15)", 610},
+		{"let f = fn() { let count = fn(n) { if (n == 0) { 0 } else { count(n - 1) } }; count(3) }; f()", 0},
+		{`len("größe") + len([1, 2])`, 7},
+		{"let len = fn(x) { 1 }; len([1, 2])", 1},
+		{"rest([1, 2, 3])[0]", 2},
+		{"first([])", nil},
+		{"return 3; 4", 3},
+		{"fn(a) { a }()", "ERROR: wrong number of arguments: want=1, got=0"},
+		{"1()", "ERROR: not a function: INTEGER"},
+		{"len(1)", "ERROR: argument to `len` not supported, got INTEGER"},
+		{"let f = fn() { f() }; f()", "ERROR: stack overflow"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestLoops(t *testing.T) {
+	// This is a test function for while and for loops with break, continue and return
+	tests := []vmTestCase{
+		{"let i = 0; while (i < 10) { let i = i + 1; } i", 10},
+		{"let i = 0; while (true) { let i = i + 1; if (i == 5) { break; } } i", 5},
+		{"let i = 0; let sum = 0; while (i < 10) { let i = i + 1; if (i % 2 == 0) { continue; } let sum = sum + i; } sum", 25},
+		{"let sum = 0; for (x in [1, 2, 3, 4]) { if (x == 3) { break } let sum = sum + x; } sum", 3},
+		{`let n = 0; for (c in "größe") { if (c == "ö") { continue } let n = n + 1 } n`, 4},
+		{`let sum = 0; for (k in {1: "a", 2: "b", 3: "c"}) { let sum = sum + k } sum`, 6},
+		{"let sum = 0; for (x in [[1, 2], [3, 4]]) { for (y in x) { if (y == 2) { break } let sum = sum + y } } sum", 8},
+		{"let find = fn(xs, v) { let i = 0; for (x in xs) { if (x == v) { return i; } let i = i + 1; } -1 }; find([5, 6, 7], 7)", 2},
+		{"let f = fn() { while (true) { while (true) { return 42; } } }; f() + 1", 43},
+		{"let f = fn() { let i = 0; while (i < 3) { let i = i + 1 } }; f()", nil},
+		{"let i = 0; while (i < 100000) { for (x in [1]) { let i = i + x } } i", 100000},
+		// A program ending with a loop gives null, not the last value the loop popped
+		{"for (x in [1]) { x }", nil},
+		{"while (false) {}", nil},
+		{"5; for (x in [1, 2]) { if (x == 2) { break } }", nil},
+		{"for (x in 5) { x }", "ERROR: cannot iterate over INTEGER"},
+		{"for (x in [1, 2]) { x + true }", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	// This is a test function for the errors that stop a program, with the messages of the evaluator
+	tests := []vmTestCase{
+		{"5 + true", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{"-true", "ERROR: unknown operator: -BOOLEAN"},
+		{"true + false", "ERROR: unknown operator: BOOLEAN + BOOLEAN"},
+		{`"a" - "b"`, "ERROR: unknown operator: STRING - STRING"},
+		{"1 / 0", "ERROR: division by zero"},
+		{"1.5 % 0", "ERROR: division by zero"},
+		// A variable whose "let" didn't run is not found, even where a previous call left a value in its slot
+		{"while (false) { let x = 1 }; puts(x + 1)", "ERROR: identifier not found: x"},
+		{"if (false) { let x = 1 }; len(x)", "ERROR: identifier not found: x"},
+		{"let f = fn() { if (false) { let x = 1 }; -x }; f()", "ERROR: identifier not found: x"},
+		{"let g = fn() { let a = 99; a }; let f = fn() { if (false) { let x = 1 }; x }; g(); f()", "ERROR: identifier not found: x"},
+		// A function bound later can only be called once it is bound
+		{"let f = fn() { g() }; f(); let g = fn() { 1 };", "ERROR: identifier not found: g"},
+		{"let f = fn() { let h = fn() { g() }; h(); let g = fn() { 1 }; }; f()", "ERROR: identifier not found: g"},
+		{"let a = a + 1", "ERROR: identifier not found: a"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestLastPoppedAfterStackOverflow(t *testing.T) {
+	// This is a test function for the last popped value of a program stopped by a stack overflow
+	// The locals of the calls fill the stack before the frames run out, leaving the stack pointer beyond the stack
+	comp := compiler.New()
+	if err := comp.Compile(parse("let f = fn() { let a = 1; let b = 2; let c = 3; f() }; f()")); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	machine := New(comp.Bytecode())
+	if err := machine.Run(); err == nil || err.Error() != "stack overflow" {
+		t.Fatalf("expected a stack overflow, got=%v", err)
+	}
+
+	if result := machine.LastPoppedStackElem(); result != nil {
+		t.Errorf("expected no last popped value, got=%+v", result)
+	}
+}
+
+func TestMatchesEvaluator(t *testing.T) {
+	// This is a test function for running the same programs with the evaluator and the vm
+	// Both must print the same output and give the same value
+	inputs := []string{
+		`let greet = fn(name) { puts("Hello, ${name}!") }; greet("Monkey"); greet("VM")`,
+		`let map = fn(arr, f) { let out = []; for (x in arr) { let out = push(out, f(x)) } out }; map([1, 2, 3], fn(x) { x * x })`,
+		`let h = {"one": 1, "two": 2}; let total = 0; for (k in h) { let total = total + h[k] } total`,
+		`let counter = fn() { let n = 0; fn() { n + 1 } }; counter()()`,
+		`let fact = fn(n) { if (n <= 1) { return 1 } n * fact(n - 1) }; fact(10)`,
+		`puts(1, 2.5, "a", [true], {1: false})`,
+		`type(1.5) + str(2) + str(int("0x10"))`,
+		`for (x in [1]) { x }`,
+		`while (false) {}`,
+		// A closure shares the locals it captures with the function, and sees them bound again after it was created
+		`let f = fn() { let x = 0; let g = fn() { x }; x += 1; g() }; f()`,
+		`let f = fn() { let a = 1; let g = fn() { a }; let a = 2; g() }; f()`,
+		`let f = fn() { let fs = []; for (i in [1, 2, 3]) { let fs = push(fs, fn() { i }) } fs[0]() + fs[2]() }; f()`,
+		`let counter = fn() { let n = 0; [fn() { let n = n + 1; n }, fn() { n }] }; let c = counter(); c[0](); c[0]() + c[1]()`,
+		// A function can call a function bound after it
+		`let f = fn() { g() }; let g = fn() { 1 }; f()`,
+		`let isEven = fn(n) { if (n == 0) { true } else { isOdd(n - 1) } }; let isOdd = fn(n) { if (n == 0) { false } else { isEven(n - 1) } }; isEven(10)`,
+		`let f = fn() { let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } }; let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } }; odd(7) }; f()`,
+	}
+
+	for i, input := range inputs {
+		var evalOut bytes.Buffer
+		env := object.NewEnvironment()
+		env.SetOutput(&evalOut)
+		expected := evaluator.Eval(parse(input), env)
+		if expected == nil {
+			// The evaluator gives no value for a program ending with a loop, the vm gives null
+			expected = evaluator.NULL
+		}
+
+		var vmOut bytes.Buffer
+		result, err := run(input, &vmOut)
+		if err != nil {
+			t.Errorf("tests[%d] - vm error: %s", i, err)
+			continue
+		}
+
+		if result.Inspect() != expected.Inspect() {
+			t.Errorf("tests[%d] - wrong result. evaluator=%s, vm=%s", i, expected.Inspect(), result.Inspect())
+		}
+		if vmOut.String() != evalOut.String() {
+			t.Errorf("tests[%d] - wrong output. evaluator=%q, vm=%q", i, evalOut.String(), vmOut.String())
+		}
+	}
+}
+
+const fibonacci = `
+let fibonacci = fn(x) {
+	if (x < 2) {
+		return x;
+	}
+	fibonacci(x - 1) + fibonacci(x - 2);
+};
+fibonacci(20);
+`
+
+func BenchmarkFibonacci(b *testing.B) {
+	// This is a benchmark function for the vm against the evaluator, on recursive fibonacci
+	// For example, "go test ./vm -bench Fibonacci"
+	program := parse(fibonacci)
+
+	b.Run("evaluator", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			evaluator.Eval(program, object.NewEnvironment())
+		}
+	})
+
+	b.Run("vm", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			comp := compiler.New()
+			if err := comp.Compile(program); err != nil {
+				b.Fatalf("compiler error: %s", err)
+			}
+
+			machine := New(comp.Bytecode())
+			if err := machine.Run(); err != nil {
+				b.Fatalf("vm error: %s", err)
+			}
+		}
+	})
+}
+
+func parse(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
+
+func run(input string, out *bytes.Buffer) (object.Object, error) {
+	comp := compiler.New()
+	if err := comp.Compile(parse(input)); err != nil {
+		return nil, err
+	}
+
+	machine := New(comp.Bytecode())
+	machine.SetOutput(out)
+	if err := machine.Run(); err != nil {
+		return nil, err
+	}
+
+	return machine.LastPoppedStackElem(), nil
+}
+
+func runVmTests(t *testing.T, tests []vmTestCase) {
+	t.Helper()
+
+	for i, tt := range tests {
+		var out bytes.Buffer
+		result, err := run(tt.input, &out)
+
+		// An expected value starting with "ERROR: " is an expected error message
+		if expected, ok := tt.expected.(string); ok && len(expected) > 7 && expected[:7] == "ERROR: " {
+			if err == nil {
+				t.Errorf("tests[%d] - expected error %q, got=%+v", i, expected[7:], result)
+			} else if err.Error() != expected[7:] {
+				t.Errorf("tests[%d] - wrong error. expected=%q, got=%q", i, expected[7:], err.Error())
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("tests[%d] - vm error: %s", i, err)
+			continue
+		}
+
+		testExpectedObject(t, i, tt.expected, result)
+	}
+}
+
+func testExpectedObject(t *testing.T, i int, expected interface{}, actual object.Object) {
+	t.Helper()
+
+	switch expected := expected.(type) {
+	case int:
+		result, ok := actual.(*object.Integer)
+		if !ok || result.Value != int64(expected) {
+			t.Errorf("tests[%d] - object is not Integer %d. got=%T (%+v)", i, expected, actual, actual)
+		}
+	case float64:
+		result, ok := actual.(*object.Float)
+		if !ok || result.Value != expected {
+			t.Errorf("tests[%d] - object is not Float %g. got=%T (%+v)", i, expected, actual, actual)
+		}
+	case bool:
+		result, ok := actual.(*object.Boolean)
+		if !ok || result.Value != expected {
+			t.Errorf("tests[%d] - object is not Boolean %t. got=%T (%+v)", i, expected, actual, actual)
+		}
+	case string:
+		result, ok := actual.(*object.String)
+		if !ok || result.Value != expected {
+			t.Errorf("tests[%d] - object is not String %q. got=%T (%+v)", i, expected, actual, actual)
+		}
+	case nil:
+		if actual != Null {
+			t.Errorf("tests[%d] - object is not Null. got=%T (%+v)", i, actual, actual)
+		}
+	}
+}
+
+func TestOpcodeCounts(t *testing.T) {
+	// This is a test function for counting the instructions that are run
+	comp := compiler.New()
+	if err := comp.Compile(parse("let i = 0; while (i < 3) { let i = i + 1; }")); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	machine := New(comp.Bytecode())
+	if err := machine.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	counts := machine.OpcodeCounts()
+	expected := map[string]int{
+		"OpConstant":      8,
+		"OpSetGlobal":     4,
+		"OpGetGlobal":     7,
+		"OpLessThan":      4,
+		"OpJumpNotTruthy": 4,
+		"OpAdd":           3,
+		"OpJump":          3,
+		"OpNull":          1,
+		"OpPop":           1,
+	}
+
+	if len(counts) != len(expected) {
+		t.Errorf("wrong opcodes counted. got=%v", counts)
+	}
+	for name, want := range expected {
+		if counts[name] != want {
+			t.Errorf("wrong count of %s. want=%d, got=%d", name, want, counts[name])
+		}
+	}
+}